@@ -3,11 +3,13 @@ package client
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"strings"
 	"time"
 )
 
@@ -18,19 +20,29 @@ const (
 
 // Client is an HTTP client for the Manuals API.
 type Client struct {
-	baseURL    string
-	apiKey     string
-	httpClient *http.Client
+	baseURL        string
+	apiKey         string
+	httpClient     *http.Client
+	downloadClient *http.Client
 }
 
 // New creates a new API client.
 func New(baseURL, apiKey string) *Client {
+	// Downloads can run far longer than any fixed request timeout, so they
+	// only bound the wait for response headers and rely on the request
+	// context for cancellation.
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.ResponseHeaderTimeout = 30 * time.Second
+
 	return &Client{
 		baseURL: baseURL,
 		apiKey:  apiKey,
 		httpClient: &http.Client{
 			Timeout: 30 * time.Second,
 		},
+		downloadClient: &http.Client{
+			Transport: transport,
+		},
 	}
 }
 
@@ -153,6 +165,28 @@ type ErrorResponse struct {
 	Error string `json:"error"`
 }
 
+// APIError is returned when the API responds with an unexpected status.
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+// Error implements the error interface.
+func (e *APIError) Error() string {
+	return fmt.Sprintf("API error (%d): %s", e.StatusCode, e.Message)
+}
+
+// newAPIError builds an APIError from a response, using the JSON error
+// message when present and the raw body otherwise.
+func newAPIError(resp *http.Response) *APIError {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	var errResp ErrorResponse
+	if err := json.Unmarshal(body, &errResp); err == nil && errResp.Error != "" {
+		return &APIError{StatusCode: resp.StatusCode, Message: errResp.Error}
+	}
+	return &APIError{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(body))}
+}
+
 // Search searches for devices using keyword/FTS5 search.
 func (c *Client) Search(query string, limit int, domain, deviceType string) (*SearchResponse, error) {
 	params := url.Values{}
@@ -334,6 +368,42 @@ func (c *Client) GetDocumentDownloadURL(id string) string {
 	return c.baseURL + "/api/" + APIVersion + "/documents/" + id + "/download"
 }
 
+// downloadHeaders are the request headers forwarded to the download endpoint.
+var downloadHeaders = []string{"Range", "If-Range", "If-None-Match", "If-Modified-Since"}
+
+// DownloadDocument starts streaming a document's content. Range and
+// conditional headers in header are forwarded. Successful (200, 206),
+// not-modified (304) and range-not-satisfiable (416) responses are
+// returned for the caller to relay, and the caller must close the body.
+// Any other status is returned as an *APIError.
+func (c *Client) DownloadDocument(ctx context.Context, id string, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.GetDocumentDownloadURL(id), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	if c.apiKey != "" {
+		req.Header.Set("X-API-Key", c.apiKey)
+	}
+	for _, name := range downloadHeaders {
+		if v := header.Get(name); v != "" {
+			req.Header.Set(name, v)
+		}
+	}
+
+	resp, err := c.downloadClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusPartialContent, http.StatusNotModified, http.StatusRequestedRangeNotSatisfiable:
+		return resp, nil
+	default:
+		defer resp.Body.Close()
+		return nil, newAPIError(resp)
+	}
+}
+
 // GetStatus gets the API status.
 func (c *Client) GetStatus() (*StatusResponse, error) {
 	var resp StatusResponse
@@ -387,12 +457,7 @@ func (c *Client) get(path string, result interface{}) error {
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		var errResp ErrorResponse
-		if err := json.NewDecoder(resp.Body).Decode(&errResp); err != nil {
-			body, _ := io.ReadAll(resp.Body)
-			return fmt.Errorf("API error (%d): %s", resp.StatusCode, string(body))
-		}
-		return fmt.Errorf("API error (%d): %s", resp.StatusCode, errResp.Error)
+		return newAPIError(resp)
 	}
 
 	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
@@ -432,12 +497,7 @@ func (c *Client) post(path string, body interface{}, result interface{}) error {
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
-		var errResp ErrorResponse
-		if err := json.NewDecoder(resp.Body).Decode(&errResp); err != nil {
-			respBody, _ := io.ReadAll(resp.Body)
-			return fmt.Errorf("API error (%d): %s", resp.StatusCode, string(respBody))
-		}
-		return fmt.Errorf("API error (%d): %s", resp.StatusCode, errResp.Error)
+		return newAPIError(resp)
 	}
 
 	if result != nil {
@@ -473,12 +533,7 @@ func (c *Client) put(path string, body interface{}) error {
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
-		var errResp ErrorResponse
-		if err := json.NewDecoder(resp.Body).Decode(&errResp); err != nil {
-			respBody, _ := io.ReadAll(resp.Body)
-			return fmt.Errorf("API error (%d): %s", resp.StatusCode, string(respBody))
-		}
-		return fmt.Errorf("API error (%d): %s", resp.StatusCode, errResp.Error)
+		return newAPIError(resp)
 	}
 
 	return nil
@@ -502,12 +557,7 @@ func (c *Client) delete(path string) error {
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
-		var errResp ErrorResponse
-		if err := json.NewDecoder(resp.Body).Decode(&errResp); err != nil {
-			respBody, _ := io.ReadAll(resp.Body)
-			return fmt.Errorf("API error (%d): %s", resp.StatusCode, string(respBody))
-		}
-		return fmt.Errorf("API error (%d): %s", resp.StatusCode, errResp.Error)
+		return newAPIError(resp)
 	}
 
 	return nil
@@ -671,3 +721,8 @@ func (c *Client) GetReindexStatus() (*ReindexStatus, error) {
 func (c *Client) APIKey() string {
 	return c.apiKey
 }
+
+// BaseURL returns the API base URL the client was created with.
+func (c *Client) BaseURL() string {
+	return c.baseURL
+}
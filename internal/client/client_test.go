@@ -1,7 +1,10 @@
 package client
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -396,7 +399,7 @@ func TestGetStatus(t *testing.T) {
 			Status:     "ok",
 			APIVersion: APIVersion,
 			Version:    "1.0.0",
-			DBPath:     "/data/manuals.db",
+			DocsPath:   "/data/docs",
 		}
 		resp.Counts.Devices = 100
 		resp.Counts.Documents = 50
@@ -466,8 +469,8 @@ func TestListUsers(t *testing.T) {
 		}
 		json.NewEncoder(w).Encode(UsersResponse{
 			Users: []User{
-				{ID: "user-1", Name: "admin", Role: "admin", IsActive: true},
-				{ID: "user-2", Name: "reader", Role: "ro", IsActive: true},
+				{ID: "user-1", Name: "admin", Capabilities: []string{"*"}, IsActive: true},
+				{ID: "user-2", Name: "reader", Capabilities: []string{"read:devices"}, IsActive: true},
 			},
 		})
 	}))
@@ -497,20 +500,20 @@ func TestCreateUser(t *testing.T) {
 		if req.Name != "newuser" {
 			t.Errorf("expected name newuser, got %s", req.Name)
 		}
-		if req.Role != "rw" {
-			t.Errorf("expected role rw, got %s", req.Role)
+		if req.Preset != "contributor" {
+			t.Errorf("expected preset contributor, got %s", req.Preset)
 		}
 
 		w.WriteHeader(http.StatusCreated)
 		json.NewEncoder(w).Encode(CreateUserResponse{
-			User:   User{ID: "user-new", Name: "newuser", Role: "rw", IsActive: true},
+			User:   User{ID: "user-new", Name: "newuser", Capabilities: []string{"write:reindex"}, IsActive: true},
 			APIKey: "mapi_newkey123",
 		})
 	}))
 	defer server.Close()
 
 	client := New(server.URL, "admin-key")
-	resp, err := client.CreateUser("newuser", "rw")
+	resp, err := client.CreateUser("newuser", "contributor")
 	if err != nil {
 		t.Fatalf("CreateUser failed: %v", err)
 	}
@@ -637,7 +640,7 @@ func TestTriggerReindex(t *testing.T) {
 		if r.Method != "POST" {
 			t.Errorf("expected POST, got %s", r.Method)
 		}
-		if !strings.Contains(r.URL.Path, "/admin/reindex") {
+		if !strings.HasSuffix(r.URL.Path, "/rw/reindex") {
 			t.Errorf("unexpected path: %s", r.URL.Path)
 		}
 		w.WriteHeader(http.StatusOK)
@@ -653,7 +656,7 @@ func TestTriggerReindex(t *testing.T) {
 
 func TestGetReindexStatus(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if !strings.Contains(r.URL.Path, "/admin/reindex/status") {
+		if !strings.HasSuffix(r.URL.Path, "/rw/reindex/status") {
 			t.Errorf("unexpected path: %s", r.URL.Path)
 		}
 		json.NewEncoder(w).Encode(ReindexStatus{
@@ -940,19 +943,19 @@ func TestGetGuideNotFound(t *testing.T) {
 	}
 }
 
-func TestUpdateUserRole(t *testing.T) {
+func TestUpdateUserCapabilities(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != "PUT" {
 			t.Errorf("expected PUT, got %s", r.Method)
 		}
-		if !strings.Contains(r.URL.Path, "/admin/users/user-123/role") {
+		if !strings.Contains(r.URL.Path, "/admin/users/user-123/capabilities") {
 			t.Errorf("unexpected path: %s", r.URL.Path)
 		}
 
 		var body map[string]string
 		json.NewDecoder(r.Body).Decode(&body)
-		if body["role"] != "rw" {
-			t.Errorf("expected role rw, got %s", body["role"])
+		if body["preset"] != "contributor" {
+			t.Errorf("expected preset contributor, got %s", body["preset"])
 		}
 
 		w.WriteHeader(http.StatusOK)
@@ -960,13 +963,13 @@ func TestUpdateUserRole(t *testing.T) {
 	defer server.Close()
 
 	client := New(server.URL, "admin-key")
-	err := client.UpdateUserRole("user-123", "rw")
+	err := client.UpdateUserCapabilities("user-123", "contributor")
 	if err != nil {
-		t.Fatalf("UpdateUserRole failed: %v", err)
+		t.Fatalf("UpdateUserCapabilities failed: %v", err)
 	}
 }
 
-func TestUpdateUserRoleError(t *testing.T) {
+func TestUpdateUserCapabilitiesError(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusForbidden)
 		json.NewEncoder(w).Encode(ErrorResponse{Error: "insufficient permissions"})
@@ -974,7 +977,7 @@ func TestUpdateUserRoleError(t *testing.T) {
 	defer server.Close()
 
 	client := New(server.URL, "test-key")
-	err := client.UpdateUserRole("user-123", "admin")
+	err := client.UpdateUserCapabilities("user-123", "admin")
 	if err == nil {
 		t.Fatal("expected error")
 	}
@@ -982,3 +985,102 @@ func TestUpdateUserRoleError(t *testing.T) {
 		t.Errorf("expected 'insufficient permissions' error, got: %v", err)
 	}
 }
+
+func TestBaseURL(t *testing.T) {
+	client := New("http://localhost:8080", "")
+	if got := client.BaseURL(); got != "http://localhost:8080" {
+		t.Errorf("expected BaseURL http://localhost:8080, got %s", got)
+	}
+}
+
+func TestAPIErrorStatus(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		body     string
+		expected string
+	}{
+		{"json error", http.StatusNotFound, `{"error":"device not found"}`, "device not found"},
+		{"plain body", http.StatusBadGateway, "upstream down\n", "upstream down"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tc.status)
+				w.Write([]byte(tc.body))
+			}))
+			defer server.Close()
+
+			_, err := New(server.URL, "").GetDevice("missing", false)
+
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("expected *APIError, got %T: %v", err, err)
+			}
+			if apiErr.StatusCode != tc.status {
+				t.Errorf("expected status %d, got %d", tc.status, apiErr.StatusCode)
+			}
+			if apiErr.Message != tc.expected {
+				t.Errorf("expected message %q, got %q", tc.expected, apiErr.Message)
+			}
+		})
+	}
+}
+
+func TestDownloadDocument(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, "/documents/doc-1/download") {
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+		if r.Header.Get("X-API-Key") != "test-key" {
+			t.Errorf("expected API key header, got %q", r.Header.Get("X-API-Key"))
+		}
+		if r.Header.Get("Range") != "bytes=0-3" {
+			t.Errorf("expected Range to be forwarded, got %q", r.Header.Get("Range"))
+		}
+		if r.Header.Get("Cookie") != "" {
+			t.Error("expected unrelated headers not to be forwarded")
+		}
+		w.Header().Set("Content-Range", "bytes 0-3/10")
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write([]byte("%PDF"))
+	}))
+	defer server.Close()
+
+	header := http.Header{}
+	header.Set("Range", "bytes=0-3")
+	header.Set("Cookie", "manuals_session=secret")
+
+	resp, err := New(server.URL, "test-key").DownloadDocument(context.Background(), "doc-1", header)
+	if err != nil {
+		t.Fatalf("DownloadDocument failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusPartialContent {
+		t.Errorf("expected status 206, got %d", resp.StatusCode)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != "%PDF" {
+		t.Errorf("expected body %%PDF, got %q", body)
+	}
+}
+
+func TestDownloadDocumentError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(ErrorResponse{Error: "file missing"})
+	}))
+	defer server.Close()
+
+	_, err := New(server.URL, "").DownloadDocument(context.Background(), "doc-1", nil)
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404 APIError, got %v", err)
+	}
+	if apiErr.Message != "file missing" {
+		t.Errorf("expected message 'file missing', got %q", apiErr.Message)
+	}
+}
@@ -1,9 +1,15 @@
 package cmd
 
 import (
+	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
 
+	"github.com/rmrfslashbin/manuals-webui/internal/server"
+	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 )
 
@@ -75,3 +81,150 @@ func TestExecuteReturnsNilOnHelp(t *testing.T) {
 		t.Error("expected version subcommand to exist")
 	}
 }
+
+func TestWriteConfigFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "manuals-webui.yaml")
+
+	err := writeConfigFile(path, server.WizardResult{
+		APIURL: "http://manuals.local:8080",
+		APIKey: "mapi_secret",
+		Host:   "127.0.0.1",
+		Port:   3001,
+	})
+	if err != nil {
+		t.Fatalf("writeConfigFile failed: %v", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("stat failed: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o600 {
+		t.Errorf("expected permissions 0600, got %o", perm)
+	}
+
+	// Read it back the way initConfig does
+	v := viper.New()
+	v.SetConfigFile(path)
+	if err := v.ReadInConfig(); err != nil {
+		t.Fatalf("ReadInConfig failed: %v", err)
+	}
+	if got := v.GetString("api.url"); got != "http://manuals.local:8080" {
+		t.Errorf("expected api.url http://manuals.local:8080, got %s", got)
+	}
+	if got := v.GetString("api.key"); got != "mapi_secret" {
+		t.Errorf("expected api.key mapi_secret, got %s", got)
+	}
+	if got := v.GetString("server.host"); got != "127.0.0.1" {
+		t.Errorf("expected server.host 127.0.0.1, got %s", got)
+	}
+	if got := v.GetInt("server.port"); got != 3001 {
+		t.Errorf("expected server.port 3001, got %d", got)
+	}
+}
+
+func TestUpdateConfigFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "manuals-webui.yaml")
+	if err := writeConfigFile(path, server.WizardResult{APIURL: "http://manuals.local:8080", APIKey: "mapi_secret", Port: 3001}); err != nil {
+		t.Fatalf("writeConfigFile failed: %v", err)
+	}
+
+	if err := updateConfigFile(path, "metadata.promoted_keys", []string{"vendor", "power.vin"}); err != nil {
+		t.Fatalf("updateConfigFile failed: %v", err)
+	}
+
+	v := viper.New()
+	v.SetConfigFile(path)
+	if err := v.ReadInConfig(); err != nil {
+		t.Fatalf("ReadInConfig failed: %v", err)
+	}
+	if got := v.GetStringSlice("metadata.promoted_keys"); strings.Join(got, ",") != "vendor,power.vin" {
+		t.Errorf("expected promoted keys vendor,power.vin, got %v", got)
+	}
+	if got := v.GetString("api.key"); got != "mapi_secret" {
+		t.Errorf("expected api.key to be kept, got %s", got)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("expected the file to stay owner-only, got %v %v", info.Mode(), err)
+	}
+
+	// Concurrent saves each replace the whole file and leave nothing behind
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := updateConfigFile(path, "metadata.promoted_keys", []string{fmt.Sprintf("key%d", i)}); err != nil {
+				t.Errorf("updateConfigFile failed: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	v = viper.New()
+	v.SetConfigFile(path)
+	if err := v.ReadInConfig(); err != nil {
+		t.Fatalf("ReadInConfig failed: %v", err)
+	}
+	if got := v.GetStringSlice("metadata.promoted_keys"); len(got) != 1 || v.GetString("api.key") != "mapi_secret" {
+		t.Errorf("expected one save to win intact, got %v", got)
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(path)); len(entries) != 1 {
+		t.Errorf("expected no temporary files, got %d entries", len(entries))
+	}
+
+	if err := updateConfigFile(filepath.Join(t.TempDir(), "missing.yaml"), "metadata.promoted_keys", nil); err == nil {
+		t.Error("expected an error for a missing config file")
+	}
+}
+
+func TestConfigFilePath(t *testing.T) {
+	old := cfgFile
+	defer func() { cfgFile = old }()
+
+	cfgFile = "/tmp/custom.yaml"
+	path, err := configFilePath()
+	if err != nil {
+		t.Fatalf("configFilePath failed: %v", err)
+	}
+	if path != "/tmp/custom.yaml" {
+		t.Errorf("expected /tmp/custom.yaml, got %s", path)
+	}
+
+	cfgFile = ""
+	path, err = configFilePath()
+	if err != nil {
+		t.Fatalf("configFilePath failed: %v", err)
+	}
+	if filepath.Base(path) != ".manuals-webui.yaml" {
+		t.Errorf("expected .manuals-webui.yaml, got %s", path)
+	}
+}
+
+func TestAPISettingsSupplied(t *testing.T) {
+	newViper := func(args ...string) *viper.Viper {
+		flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+		flags.String("api-url", "http://localhost:8080", "")
+		flags.String("api-key", "", "")
+		if err := flags.Parse(args); err != nil {
+			t.Fatalf("parse failed: %v", err)
+		}
+		v := viper.New()
+		_ = v.BindPFlag("api.url", flags.Lookup("api-url"))
+		_ = v.BindPFlag("api.key", flags.Lookup("api-key"))
+		_ = v.BindEnv("api.url", "MANUALS_API_URL")
+		_ = v.BindEnv("api.key", "MANUALS_API_KEY")
+		return v
+	}
+
+	if apiSettingsSupplied(newViper()) {
+		t.Error("expected flag defaults not to count as supplied settings")
+	}
+	if !apiSettingsSupplied(newViper("--api-url", "http://manuals:8080")) {
+		t.Error("expected an API URL flag to count")
+	}
+
+	t.Setenv("MANUALS_API_KEY", "mapi_secret")
+	if !apiSettingsSupplied(newViper()) {
+		t.Error("expected an API key from the environment to count")
+	}
+}
@@ -83,6 +83,7 @@ func initConfig() {
 	// Explicit env bindings (AutomaticEnv only works for known keys)
 	_ = viper.BindEnv("api.url", "MANUALS_API_URL")
 	_ = viper.BindEnv("api.key", "MANUALS_API_KEY")
+	_ = viper.BindEnv("api.allowed_urls", "MANUALS_API_ALLOWED_URLS")
 	_ = viper.BindEnv("server.host", "MANUALS_SERVER_HOST")
 	_ = viper.BindEnv("server.port", "MANUALS_SERVER_PORT")
 	_ = viper.BindEnv("log.level", "MANUALS_LOG_LEVEL")
@@ -3,6 +3,7 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"html/template"
 	"net/http"
 	"os"
 	"os/signal"
@@ -27,9 +28,37 @@ func init() {
 
 	serveCmd.Flags().String("host", "0.0.0.0", "Host to bind to")
 	serveCmd.Flags().Int("port", 3000, "Port to listen on")
+	serveCmd.Flags().StringSlice("allowed-api-urls", nil, "Additional API URLs browsers may select in the setup flow")
+	serveCmd.Flags().Bool("dev", false, "Load templates from disk and reload them on change")
+	serveCmd.Flags().String("dev-templates", "internal/server/templates", "Template directory used in dev mode")
+	serveCmd.Flags().String("templates-dir", "", "Directory of templates that override the built-in ones")
+	serveCmd.Flags().String("site-title", "Manuals", "Site title shown in the navigation bar and page titles")
+	serveCmd.Flags().String("logo-url", "", "Logo image URL shown in the navigation bar")
+	serveCmd.Flags().String("accent-color", "", "Navigation bar color as #rgb or #rrggbb")
+	serveCmd.Flags().String("footer-html", "", "Trusted HTML rendered in the page footer")
+	serveCmd.Flags().String("cache-dir", "", "Directory for cached document downloads (disabled if empty)")
+	serveCmd.Flags().Int64("cache-max-mb", 1024, "Maximum size of the document cache in megabytes")
+	serveCmd.Flags().Duration("link-check-interval", 0, "How often to check reference links in the background (disabled if 0)")
+	serveCmd.Flags().Bool("link-check-allow-private", false, "Let the link checker request private, loopback and link-local addresses")
+	serveCmd.Flags().Duration("reindex-poll-interval", time.Minute, "How often to check the API for finished reindexes (disabled if 0)")
+	serveCmd.Flags().StringSlice("metadata-keys", nil, "Device metadata keys shown in the device page header")
 
 	_ = viper.BindPFlag("server.host", serveCmd.Flags().Lookup("host"))
 	_ = viper.BindPFlag("server.port", serveCmd.Flags().Lookup("port"))
+	_ = viper.BindPFlag("api.allowed_urls", serveCmd.Flags().Lookup("allowed-api-urls"))
+	_ = viper.BindPFlag("server.dev", serveCmd.Flags().Lookup("dev"))
+	_ = viper.BindPFlag("server.dev_templates", serveCmd.Flags().Lookup("dev-templates"))
+	_ = viper.BindPFlag("server.templates_dir", serveCmd.Flags().Lookup("templates-dir"))
+	_ = viper.BindPFlag("branding.site_title", serveCmd.Flags().Lookup("site-title"))
+	_ = viper.BindPFlag("branding.logo_url", serveCmd.Flags().Lookup("logo-url"))
+	_ = viper.BindPFlag("branding.accent_color", serveCmd.Flags().Lookup("accent-color"))
+	_ = viper.BindPFlag("branding.footer_html", serveCmd.Flags().Lookup("footer-html"))
+	_ = viper.BindPFlag("cache.dir", serveCmd.Flags().Lookup("cache-dir"))
+	_ = viper.BindPFlag("cache.max_mb", serveCmd.Flags().Lookup("cache-max-mb"))
+	_ = viper.BindPFlag("linkcheck.interval", serveCmd.Flags().Lookup("link-check-interval"))
+	_ = viper.BindPFlag("linkcheck.allow_private", serveCmd.Flags().Lookup("link-check-allow-private"))
+	_ = viper.BindPFlag("reindex.poll_interval", serveCmd.Flags().Lookup("reindex-poll-interval"))
+	_ = viper.BindPFlag("metadata.promoted_keys", serveCmd.Flags().Lookup("metadata-keys"))
 }
 
 func runServe(cmd *cobra.Command, args []string) error {
@@ -57,7 +86,24 @@ func runServe(cmd *cobra.Command, args []string) error {
 	// Verify API connection
 	status, err := apiClient.GetStatus()
 	if err != nil {
-		return fmt.Errorf("failed to connect to API: %w", err)
+		// A configured deployment whose API is down should fail, not wait
+		// for someone to fill in the wizard
+		if configFileExists() || apiSettingsSupplied(viper.GetViper()) {
+			return fmt.Errorf("failed to connect to API: %w", err)
+		}
+
+		// No configuration at all and no reachable API: collect settings interactively
+		logger.Warn("API unreachable and no configuration found, starting first-run setup wizard", "error", err)
+		result, werr := runSetupWizard(logger, host, port, apiURL, apiKey)
+		if werr != nil {
+			return werr
+		}
+
+		apiURL, apiKey = result.APIURL, result.APIKey
+		host, port = result.Host, result.Port
+		anonymousMode = apiKey == ""
+		apiClient = client.New(apiURL, apiKey)
+		status = result.Status
 	}
 
 	logger.Info("connected to Manuals API",
@@ -68,10 +114,34 @@ func runServe(cmd *cobra.Command, args []string) error {
 	)
 
 	// Create server
-	srv := server.New(server.Config{
-		Client: apiClient,
-		Logger: logger,
+	var devTemplatesDir string
+	if viper.GetBool("server.dev") {
+		devTemplatesDir = viper.GetString("server.dev_templates")
+	}
+
+	srv, err := server.New(server.Config{
+		Client:          apiClient,
+		Logger:          logger,
+		AllowedAPIURLs:  viper.GetStringSlice("api.allowed_urls"),
+		DevTemplatesDir: devTemplatesDir,
+		TemplatesDir:    viper.GetString("server.templates_dir"),
+		Branding: server.Branding{
+			SiteTitle:   viper.GetString("branding.site_title"),
+			LogoURL:     viper.GetString("branding.logo_url"),
+			AccentColor: viper.GetString("branding.accent_color"),
+			FooterHTML:  template.HTML(viper.GetString("branding.footer_html")),
+		},
+		CacheDir:              viper.GetString("cache.dir"),
+		CacheMaxBytes:         viper.GetInt64("cache.max_mb") << 20,
+		LinkCheckInterval:     viper.GetDuration("linkcheck.interval"),
+		LinkCheckAllowPrivate: viper.GetBool("linkcheck.allow_private"),
+		ReindexPollInterval:   viper.GetDuration("reindex.poll_interval"),
+		MetadataKeys:          viper.GetStringSlice("metadata.promoted_keys"),
+		SaveMetadataKeys:      saveMetadataKeys(),
 	})
+	if err != nil {
+		return fmt.Errorf("failed to initialize server: %w", err)
+	}
 
 	// Create HTTP server
 	addr := fmt.Sprintf("%s:%d", host, port)
@@ -104,6 +174,7 @@ func runServe(cmd *cobra.Command, args []string) error {
 	if err := httpServer.Shutdown(ctx); err != nil {
 		return fmt.Errorf("server shutdown error: %w", err)
 	}
+	srv.Close()
 
 	logger.Info("server stopped")
 	return nil
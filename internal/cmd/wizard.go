@@ -0,0 +1,195 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/rmrfslashbin/manuals-webui/internal/server"
+	"github.com/spf13/viper"
+)
+
+// configFileExists reports whether a config file was found by initConfig.
+func configFileExists() bool {
+	path := viper.ConfigFileUsed()
+	if path == "" {
+		return false
+	}
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// apiSettingsSupplied reports whether the API URL or key was given by a
+// flag, the environment or a config file. Defaults do not count.
+func apiSettingsSupplied(v *viper.Viper) bool {
+	return v.IsSet("api.url") || v.IsSet("api.key")
+}
+
+// configFilePath returns the file the first-run wizard writes: the
+// --config path if given, otherwise $HOME/.manuals-webui.yaml.
+func configFilePath() (string, error) {
+	if cfgFile != "" {
+		return cfgFile, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find home directory: %w", err)
+	}
+	return filepath.Join(home, ".manuals-webui.yaml"), nil
+}
+
+// writeConfigFile writes the wizard result in the format initConfig reads.
+// The file holds the API key, so it is only readable by the owner.
+func writeConfigFile(path string, result server.WizardResult) error {
+	v := viper.New()
+	v.SetConfigType("yaml")
+	v.Set("api.url", result.APIURL)
+	v.Set("api.key", result.APIKey)
+	v.Set("server.host", result.Host)
+	v.Set("server.port", result.Port)
+	v.Set("log.level", viper.GetString("log.level"))
+
+	if err := v.WriteConfigAs(path); err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	if err := os.Chmod(path, 0o600); err != nil {
+		return fmt.Errorf("failed to restrict config file permissions: %w", err)
+	}
+	return nil
+}
+
+// configFileMu serialises updates to the config file.
+var configFileMu sync.Mutex
+
+// updateConfigFile sets one key in the config file at path, keeping the
+// rest of the file. Values from flags and the environment are not copied
+// into it. The file is rewritten by renaming a complete copy over it, so
+// a failed write never leaves it truncated.
+func updateConfigFile(path, key string, value interface{}) error {
+	configFileMu.Lock()
+	defer configFileMu.Unlock()
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+	v := viper.New()
+	v.SetConfigFile(path)
+	if err := v.ReadInConfig(); err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+	v.Set(key, value)
+
+	// Keep the extension, which tells viper the format to write
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".*"+filepath.Base(path))
+	if err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+
+	if err := v.WriteConfigAs(tmp.Name()); err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), info.Mode().Perm()); err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	return nil
+}
+
+// saveMetadataKeys persists metadata keys changed on the admin page to the
+// config file in use. It returns nil if there is none, leaving changes in
+// memory only.
+func saveMetadataKeys() func([]string) error {
+	if !configFileExists() {
+		return nil
+	}
+	path := viper.ConfigFileUsed()
+	return func(keys []string) error {
+		return updateConfigFile(path, "metadata.promoted_keys", keys)
+	}
+}
+
+// runSetupWizard serves the first-run wizard on the loopback interface
+// until the user saves a working configuration, then applies it to viper
+// so serving can continue without a restart.
+func runSetupWizard(logger *slog.Logger, host string, port int, apiURL, apiKey string) (server.WizardResult, error) {
+	path, err := configFilePath()
+	if err != nil {
+		return server.WizardResult{}, err
+	}
+
+	addr := net.JoinHostPort("127.0.0.1", strconv.Itoa(port))
+	wizard := server.NewWizard(server.WizardConfig{
+		Logger: logger,
+		Defaults: server.WizardResult{
+			APIURL: apiURL,
+			APIKey: apiKey,
+			Host:   host,
+			Port:   port,
+		},
+		ConfigPath: path,
+		Addr:       addr,
+		Save: func(result server.WizardResult) error {
+			return writeConfigFile(path, result)
+		},
+	})
+
+	wizardServer := &http.Server{
+		Addr:         addr,
+		Handler:      wizard.Handler(),
+		ReadTimeout:  15 * time.Second,
+		WriteTimeout: 30 * time.Second,
+		IdleTimeout:  60 * time.Second,
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		logger.Info("first-run setup wizard listening", "url", "http://"+addr+"/")
+		if err := wizardServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serveErr <- err
+		}
+	}()
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(quit)
+
+	var result server.WizardResult
+	select {
+	case result = <-wizard.Done():
+	case err := <-serveErr:
+		return server.WizardResult{}, fmt.Errorf("setup wizard error: %w", err)
+	case <-quit:
+		_ = wizardServer.Close()
+		return server.WizardResult{}, fmt.Errorf("setup wizard cancelled")
+	}
+
+	// Let the confirmation page finish before releasing the port
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := wizardServer.Shutdown(ctx); err != nil {
+		return server.WizardResult{}, fmt.Errorf("setup wizard shutdown error: %w", err)
+	}
+
+	viper.Set("api.url", result.APIURL)
+	viper.Set("api.key", result.APIKey)
+	viper.Set("server.host", result.Host)
+	viper.Set("server.port", result.Port)
+
+	logger.Info("first-run setup complete", "config", path)
+	return result, nil
+}
@@ -0,0 +1,223 @@
+package server
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/rmrfslashbin/manuals-webui/internal/client"
+)
+
+// storedMimeTypes are already compressed, so deflating them only costs CPU.
+var storedMimeTypes = map[string]bool{
+	"application/pdf":  true,
+	"application/zip":  true,
+	"application/gzip": true,
+	"image/png":        true,
+	"image/jpeg":       true,
+	"image/gif":        true,
+	"image/webp":       true,
+}
+
+// archiveManifest is written to manifest.json in a device archive.
+type archiveManifest struct {
+	Device      archiveDevice     `json:"device"`
+	GeneratedAt time.Time         `json:"generated_at"`
+	Documents   []archiveDocument `json:"documents"`
+}
+
+type archiveDevice struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Domain string `json:"domain"`
+	Type   string `json:"type"`
+}
+
+type archiveDocument struct {
+	ID        string `json:"id"`
+	Filename  string `json:"filename"`
+	Path      string `json:"path"`
+	MimeType  string `json:"mime_type"`
+	SizeBytes int64  `json:"size_bytes"`
+	Checksum  string `json:"checksum,omitempty"`
+	SourceURL string `json:"source_url"` // relative to the web UI, never the API
+
+	// Status is "verified", "unverified" (no usable checksum) or an error.
+	Status string `json:"status"`
+}
+
+// handleDeviceArchive streams every document of a device as a ZIP archive,
+// together with the device documentation as README.md and a manifest.
+// Entries are written as they are downloaded, so once the response has
+// started a failed document is recorded in the manifest instead.
+func (s *Server) handleDeviceArchive(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	apiClient := s.apiClient(r)
+
+	device, err := apiClient.GetDevice(id, true)
+	if err != nil {
+		s.renderError(w, r, "Failed to get device", err)
+		return
+	}
+
+	var docs []client.Document
+	err = listAllDocuments(r.Context(), apiClient, id, func(total int, page []client.Document) error {
+		docs = append(docs, page...)
+		return nil
+	})
+	if err != nil {
+		s.renderError(w, r, "Failed to list documents", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": id + "-documents.zip"}))
+	w.WriteHeader(http.StatusOK)
+
+	zw := zip.NewWriter(&deadlineWriter{w: w, rc: http.NewResponseController(w), timeout: downloadWriteTimeout})
+	if err := s.writeDeviceArchive(r.Context(), zw, apiClient, device, docs); err != nil {
+		if r.Context().Err() == nil {
+			s.logger.Error("device archive interrupted", "device", id, "error", err)
+		}
+		return
+	}
+	if err := zw.Close(); err != nil {
+		s.logger.Error("failed to finish device archive", "device", id, "error", err)
+	}
+}
+
+// writeDeviceArchive adds README.md, each document and manifest.json to zw.
+// It only returns an error if the archive itself can no longer be written.
+func (s *Server) writeDeviceArchive(ctx context.Context, zw *zip.Writer, apiClient *client.Client, device *client.Device, docs []client.Document) error {
+	now := time.Now()
+
+	readme := device.Content
+	if strings.TrimSpace(readme) == "" {
+		readme = "# " + device.Name + "\n"
+	}
+	if err := writeArchiveBytes(zw, "README.md", now, []byte(readme)); err != nil {
+		return err
+	}
+
+	manifest := archiveManifest{
+		Device: archiveDevice{
+			ID:     device.ID,
+			Name:   device.Name,
+			Domain: device.Domain,
+			Type:   device.Type,
+		},
+		GeneratedAt: now.UTC(),
+		Documents:   make([]archiveDocument, 0, len(docs)),
+	}
+
+	names := map[string]bool{"README.md": true, "manifest.json": true}
+	for i := range docs {
+		doc := &docs[i]
+		entry := archiveDocument{
+			ID:        doc.ID,
+			Filename:  uniqueArchiveName(names, doc.Filename),
+			Path:      doc.Path,
+			MimeType:  doc.MimeType,
+			SizeBytes: doc.SizeBytes,
+			Checksum:  doc.Checksum,
+			SourceURL: "/download/" + url.PathEscape(doc.ID),
+		}
+
+		status, err := s.writeArchiveDocument(ctx, zw, apiClient, doc, entry.Filename, now)
+		if err != nil {
+			return err
+		}
+		entry.Status = status
+		manifest.Documents = append(manifest.Documents, entry)
+	}
+
+	body, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode manifest: %w", err)
+	}
+	return writeArchiveBytes(zw, "manifest.json", now, body)
+}
+
+// writeArchiveDocument copies one document into the archive, from the
+// cache when possible, and returns its manifest status. Download failures
+// before any content is written are reported as a status so the rest of
+// the archive can still be produced.
+func (s *Server) writeArchiveDocument(ctx context.Context, zw *zip.Writer, apiClient *client.Client, doc *client.Document, name string, modified time.Time) (string, error) {
+	var src io.Reader
+	if s.docCache != nil {
+		if f, _, ok := s.docCache.open(doc); ok {
+			defer f.Close()
+			src = f
+		}
+	}
+	if src == nil {
+		resp, err := apiClient.DownloadDocument(ctx, doc.ID, nil)
+		if err != nil {
+			s.logger.Warn("skipping document in archive", "id", doc.ID, "error", err)
+			return "error: " + err.Error(), nil
+		}
+		defer resp.Body.Close()
+		src = resp.Body
+	}
+
+	method := zip.Deflate
+	if mediaType, _, err := mime.ParseMediaType(doc.MimeType); err == nil && storedMimeTypes[mediaType] {
+		method = zip.Store
+	}
+
+	fw, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: method, Modified: modified})
+	if err != nil {
+		return "", err
+	}
+
+	verifier := newChecksumVerifier(doc)
+	if _, err := io.Copy(io.MultiWriter(fw, verifier), src); err != nil {
+		// The entry is truncated; a broken archive beats a silently short file
+		return "", fmt.Errorf("failed to copy %s: %w", doc.ID, err)
+	}
+
+	if err := verifier.verify(); err != nil {
+		s.logger.Error("document failed verification", "id", doc.ID, "path", doc.Path, "error", err)
+		s.integrity.recordMismatch(*doc, err)
+		return "error: " + err.Error(), nil
+	}
+	if !verifier.hasChecksum() {
+		return "unverified", nil
+	}
+	return "verified", nil
+}
+
+func writeArchiveBytes(zw *zip.Writer, name string, modified time.Time, data []byte) error {
+	fw, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modified})
+	if err != nil {
+		return err
+	}
+	_, err = fw.Write(data)
+	return err
+}
+
+// uniqueArchiveName returns a flat, unique entry name for a filename,
+// appending " (2)", " (3)", ... before the extension on collisions.
+func uniqueArchiveName(used map[string]bool, filename string) string {
+	base := path.Base(strings.ReplaceAll(filename, "\\", "/"))
+	if base == "." || base == "/" || base == ".." {
+		base = "document"
+	}
+
+	name := base
+	ext := path.Ext(base)
+	stem := strings.TrimSuffix(base, ext)
+	for n := 2; used[name]; n++ {
+		name = fmt.Sprintf("%s (%d)%s", stem, n, ext)
+	}
+	used[name] = true
+	return name
+}
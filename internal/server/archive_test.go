@@ -0,0 +1,143 @@
+package server
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rmrfslashbin/manuals-webui/internal/client"
+)
+
+func TestUniqueArchiveName(t *testing.T) {
+	used := map[string]bool{"README.md": true}
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"datasheet.pdf", "datasheet.pdf"},
+		{"datasheet.pdf", "datasheet (2).pdf"},
+		{"other/datasheet.pdf", "datasheet (3).pdf"},
+		{"..\\..\\evil.pdf", "evil.pdf"},
+		{"README.md", "README (2).md"},
+		{"..", "document"},
+	}
+
+	for _, tc := range tests {
+		if got := uniqueArchiveName(used, tc.input); got != tc.expected {
+			t.Errorf("uniqueArchiveName(%q) = %q, want %q", tc.input, got, tc.expected)
+		}
+	}
+}
+
+func TestHandleDeviceArchive(t *testing.T) {
+	first := testDocument("doc-1", "%PDF first")
+	first.Filename = "datasheet.pdf"
+	second := testDocument("doc-2", "notes text")
+	second.Filename = "datasheet.pdf"
+	second.MimeType = "text/plain"
+	missing := testDocument("doc-3", "never served")
+
+	contents := map[string]string{"doc-1": "%PDF first", "doc-2": "notes text"}
+
+	apiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/download"):
+			id := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/"+client.APIVersion+"/documents/"), "/")[0]
+			body, ok := contents[id]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				json.NewEncoder(w).Encode(client.ErrorResponse{Error: "file not found"})
+				return
+			}
+			w.Write([]byte(body))
+		case strings.HasSuffix(r.URL.Path, "/documents"):
+			if r.URL.Query().Get("device_id") != "esp32" {
+				t.Errorf("expected documents to be filtered by device, got %q", r.URL.RawQuery)
+			}
+			json.NewEncoder(w).Encode(client.DocumentsResponse{Data: []client.Document{*first, *second, *missing}, Total: 3})
+		case strings.HasSuffix(r.URL.Path, "/devices/esp32"):
+			json.NewEncoder(w).Encode(client.Device{ID: "esp32", Name: "ESP32", Domain: "hardware", Content: "# ESP32\n\nA microcontroller."})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer apiServer.Close()
+
+	s := testServer(t, apiServer)
+
+	req := httptest.NewRequest("GET", "/devices/esp32/documents.zip", nil)
+	w := httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if w.Header().Get("Content-Type") != "application/zip" {
+		t.Errorf("unexpected Content-Type %q", w.Header().Get("Content-Type"))
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	if err != nil {
+		t.Fatalf("invalid zip: %v", err)
+	}
+
+	files := map[string]string{}
+	for _, f := range zr.File {
+		rc, _ := f.Open()
+		data, _ := io.ReadAll(rc)
+		rc.Close()
+		files[f.Name] = string(data)
+	}
+
+	if files["README.md"] != "# ESP32\n\nA microcontroller." {
+		t.Errorf("unexpected README.md %q", files["README.md"])
+	}
+	if files["datasheet.pdf"] != "%PDF first" || files["datasheet (2).pdf"] != "notes text" {
+		t.Errorf("unexpected document entries %v", files)
+	}
+
+	var manifest archiveManifest
+	if err := json.Unmarshal([]byte(files["manifest.json"]), &manifest); err != nil {
+		t.Fatalf("invalid manifest: %v", err)
+	}
+	if manifest.Device.ID != "esp32" || len(manifest.Documents) != 3 {
+		t.Fatalf("unexpected manifest %+v", manifest)
+	}
+	if strings.Contains(files["manifest.json"], apiServer.URL) {
+		t.Error("expected the manifest not to reveal the API URL")
+	}
+
+	statuses := map[string]string{}
+	for _, d := range manifest.Documents {
+		statuses[d.ID] = d.Status
+		if d.SourceURL != "/download/"+d.ID {
+			t.Errorf("unexpected source URL %q", d.SourceURL)
+		}
+	}
+	if statuses["doc-1"] != "verified" || statuses["doc-2"] != "verified" {
+		t.Errorf("expected served documents to verify, got %v", statuses)
+	}
+	if !strings.HasPrefix(statuses["doc-3"], "error:") {
+		t.Errorf("expected missing document to be reported, got %q", statuses["doc-3"])
+	}
+}
+
+func TestHandleDeviceArchiveNotFound(t *testing.T) {
+	apiServer := mockAPIServer(t, nil)
+	defer apiServer.Close()
+
+	s := testServer(t, apiServer)
+
+	req := httptest.NewRequest("GET", "/devices/missing/documents.zip", nil)
+	w := httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404, got %d", w.Code)
+	}
+}
@@ -0,0 +1,124 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/rmrfslashbin/manuals-webui/internal/client"
+)
+
+// arduinoRoles are the pin constants an Arduino core expects a variant to
+// define, with the functions that identify them, best match first.
+var arduinoRoles = []struct {
+	Name      string
+	Functions []string
+}{
+	{"TX", []string{"UART_TX", "TXD", "TX"}},
+	{"RX", []string{"UART_RX", "RXD", "RX"}},
+	{"SDA", []string{"I2C_SDA", "SDA"}},
+	{"SCL", []string{"I2C_SCL", "SCL"}},
+	{"SS", []string{"SPI_SS", "SPI_CS", "SS"}},
+	{"MOSI", []string{"SPI_MOSI", "MOSI"}},
+	{"MISO", []string{"SPI_MISO", "MISO"}},
+	{"SCK", []string{"SPI_SCK", "SPI_CLK", "SCK"}},
+	{"LED_BUILTIN", []string{"LED_BUILTIN", "LED"}},
+}
+
+// arduinoRolePins returns the GPIOs offering a role, trying its functions
+// in order and stopping at the first that any pin offers.
+func arduinoRolePins(pins []client.PinoutPin, functions []string) []int {
+	for _, f := range functions {
+		query := functionTokens(f)
+		var gpios []int
+		for _, pin := range pins {
+			for _, tokens := range pinFunctions(pin) {
+				if matchFunction(query, tokens) {
+					gpios = append(gpios, *pin.GPIONum)
+					break
+				}
+			}
+		}
+		if len(gpios) > 0 {
+			return gpios
+		}
+	}
+	return nil
+}
+
+// isAnalogPin reports whether a pin has an ADC function such as
+// "ADC1_CH5" or "A0".
+func isAnalogPin(pin client.PinoutPin) bool {
+	for _, tokens := range pinFunctions(pin) {
+		if trimIndex(tokens[0]) == "ADC" || (len(tokens) == 1 && trimIndex(tokens[0]) == "A" && tokens[0] != "A") {
+			return true
+		}
+	}
+	return false
+}
+
+// writePinoutArduino writes a pins_arduino.h for an Arduino core variant.
+// Pins are numbered by GPIO, as the ESP32 and RP2040 cores do. The
+// standard bus pins and LED_BUILTIN are taken from the first pin offering
+// the function, with any others listed in a comment; analog inputs are
+// numbered A0, A1, ... in GPIO order.
+func writePinoutArduino(w io.Writer, device *client.Device, pinout *client.PinoutResponse) error {
+	var gpios []client.PinoutPin
+	maxGPIO := -1
+	for _, pin := range pinout.Pins {
+		if pin.GPIONum != nil {
+			gpios = append(gpios, pin)
+			maxGPIO = max(maxGPIO, *pin.GPIONum)
+		}
+	}
+	var analog []int
+	for _, pin := range sortedByGPIO(gpios) {
+		if isAnalogPin(pin) {
+			analog = append(analog, *pin.GPIONum)
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "/*\n * %s variant pins\n *\n * Generated by manuals-webui from device %q.\n */\n\n", commentText(device.Name), commentText(device.ID))
+	b.WriteString("#ifndef Pins_Arduino_h\n#define Pins_Arduino_h\n\n#include <stdint.h>\n\n")
+	fmt.Fprintf(&b, "#define NUM_DIGITAL_PINS  %d\n", maxGPIO+1)
+	fmt.Fprintf(&b, "#define NUM_ANALOG_INPUTS %d\n", len(analog))
+
+	var roles strings.Builder
+	for _, role := range arduinoRoles {
+		pins := arduinoRolePins(gpios, role.Functions)
+		if len(pins) == 0 {
+			continue
+		}
+		fmt.Fprintf(&roles, "static const uint8_t %s = %d;\n", role.Name, pins[0])
+		for _, other := range pins[1:] {
+			if other != pins[0] {
+				fmt.Fprintf(&roles, "/* %s is also on GPIO %d */\n", role.Name, other)
+			}
+		}
+	}
+	if roles.Len() > 0 {
+		b.WriteString("\n")
+		b.WriteString(roles.String())
+	}
+
+	if len(analog) > 0 {
+		b.WriteString("\n")
+		for i, gpio := range analog {
+			fmt.Fprintf(&b, "static const uint8_t A%d = %d;\n", i, gpio)
+		}
+	}
+
+	b.WriteString("\n#endif /* Pins_Arduino_h */\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// sortedByGPIO returns a copy of pins ordered by GPIO number. Every pin
+// must have one.
+func sortedByGPIO(pins []client.PinoutPin) []client.PinoutPin {
+	sorted := append([]client.PinoutPin(nil), pins...)
+	sort.SliceStable(sorted, func(i, j int) bool { return *sorted[i].GPIONum < *sorted[j].GPIONum })
+	return sorted
+}
@@ -0,0 +1,104 @@
+package server
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/rmrfslashbin/manuals-webui/internal/client"
+)
+
+// catalogIndex builds an index over the whole catalog in a background job
+// and keeps the latest one for each API and key, so a session never sees
+// an index built from another API or with another key.
+type catalogIndex[T any] struct {
+	job   *job
+	ttl   time.Duration // 0 keeps an index until it is rebuilt explicitly
+	build func(ctx context.Context, c *client.Client, p *jobProgress) (T, error)
+
+	mu      sync.Mutex
+	entries map[string]*catalogEntry[T] // by apiIdentity
+}
+
+// catalogEntry is a published index. It is never modified afterwards.
+type catalogEntry[T any] struct {
+	built time.Time
+	value T
+}
+
+// catalogState tells a page waiting on an index how its build is going.
+type catalogState struct {
+	Pending     bool // the index is being built
+	Unavailable string
+	Status      jobStatus
+}
+
+func newCatalogIndex[T any](s *Server, name string, ttl time.Duration, build func(context.Context, *client.Client, *jobProgress) (T, error)) *catalogIndex[T] {
+	return &catalogIndex[T]{
+		job:     newJob(s, name),
+		ttl:     ttl,
+		build:   build,
+		entries: make(map[string]*catalogEntry[T]),
+	}
+}
+
+// start builds a new index using the given API client. The previous index
+// for its API and key stays available until the new one is complete. It
+// returns false if a build is already running.
+func (ci *catalogIndex[T]) start(c *client.Client) bool {
+	return ci.job.start(func(ctx context.Context, p *jobProgress) error {
+		value, err := ci.build(ctx, c, p)
+		if err != nil {
+			return err
+		}
+		ci.mu.Lock()
+		ci.entries[apiIdentity(c)] = &catalogEntry[T]{built: time.Now(), value: value}
+		ci.mu.Unlock()
+		return nil
+	})
+}
+
+// indexFor returns the latest index built with c's API and key, or the
+// zero value.
+func (ci *catalogIndex[T]) indexFor(c *client.Client) T {
+	if e := ci.entry(c); e != nil {
+		return e.value
+	}
+	var zero T
+	return zero
+}
+
+func (ci *catalogIndex[T]) entry(c *client.Client) *catalogEntry[T] {
+	ci.mu.Lock()
+	defer ci.mu.Unlock()
+	return ci.entries[apiIdentity(c)]
+}
+
+// indexOrStart returns the index for c's API and key, starting a build if
+// there is none yet or it is older than the TTL; a stale index is still
+// returned while its replacement is built. Without retry, e.g. for polls, a build
+// that failed is reported instead of restarted.
+func (ci *catalogIndex[T]) indexOrStart(c *client.Client, retry bool) (T, bool, catalogState) {
+	var state catalogState
+	e := ci.entry(c)
+
+	st := ci.job.snapshot()
+	switch {
+	case e != nil:
+		if ci.ttl > 0 && time.Since(e.built) > ci.ttl {
+			ci.start(c)
+		}
+	case !retry && !st.Running && st.Error != "" && st.Error != "cancelled":
+		state.Unavailable = st.Error
+	default:
+		ci.start(c)
+		state.Pending = true
+	}
+	state.Status = ci.job.snapshot()
+
+	if e == nil {
+		var zero T
+		return zero, false, state
+	}
+	return e.value, true, state
+}
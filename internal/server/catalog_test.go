@@ -0,0 +1,128 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rmrfslashbin/manuals-webui/internal/client"
+)
+
+// deviceAPIServer serves a device listing, each device and per-device
+// resources such as "specs" or "pinout", answering with
+// resources[resource][id]; "documents" answers the document listing for
+// ?device_id=id. An int response is sent as that status code, and anything
+// missing answers 404. It counts the resource requests.
+func deviceAPIServer(t *testing.T, devices []client.Device, resources map[string]map[string]any) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		respond := func(resp any, ok bool) {
+			if status, isStatus := resp.(int); isStatus {
+				w.WriteHeader(status)
+				return
+			}
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				json.NewEncoder(w).Encode(map[string]string{"error": "not found"})
+				return
+			}
+			json.NewEncoder(w).Encode(resp)
+		}
+
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/settings"):
+			// Lets requireAdmin pass
+			json.NewEncoder(w).Encode(client.SettingsResponse{})
+			return
+		case strings.HasSuffix(r.URL.Path, "/devices"):
+			json.NewEncoder(w).Encode(client.DevicesResponse{Data: devices, Total: len(devices)})
+			return
+		case strings.HasSuffix(r.URL.Path, "/documents"):
+			requests.Add(1)
+			resp, ok := resources["documents"][r.URL.Query().Get("device_id")]
+			respond(resp, ok)
+			return
+		}
+
+		_, path, _ := strings.Cut(r.URL.Path, "/devices/")
+		id, rest, _ := strings.Cut(path, "/")
+		if rest != "" {
+			requests.Add(1)
+			resp, ok := resources[rest][id]
+			respond(resp, ok)
+			return
+		}
+		for _, d := range devices {
+			if d.ID == id {
+				json.NewEncoder(w).Encode(d)
+				return
+			}
+		}
+		respond(nil, false)
+	}))
+	return server, &requests
+}
+
+func TestCatalogIndex(t *testing.T) {
+	api := mockAPIServer(t, nil)
+	defer api.Close()
+	s := testServer(t, api)
+	c := client.New(api.URL, "test-key")
+
+	var builds atomic.Int32
+	fail := false
+	ci := newCatalogIndex(s, "test index", time.Hour, func(ctx context.Context, c *client.Client, p *jobProgress) (*int, error) {
+		if fail {
+			return nil, errors.New("boom")
+		}
+		n := int(builds.Add(1))
+		return &n, nil
+	})
+
+	// The first request starts a build and waits for it
+	if _, ok, state := ci.indexOrStart(c, true); ok || !state.Pending {
+		t.Fatalf("expected a pending build, got %+v", state)
+	}
+	waitForJob(t, ci.job)
+	idx, ok, state := ci.indexOrStart(c, true)
+	if !ok || *idx != 1 || state.Pending {
+		t.Fatalf("expected the built index, got %v %+v", ok, state)
+	}
+	if ci.indexFor(client.New("http://other.example", "test-key")) != nil || ci.indexFor(client.New(api.URL, "other-key")) != nil {
+		t.Error("expected the index to be kept for its own API and key only")
+	}
+
+	// A stale index is still served while it is rebuilt
+	ci.mu.Lock()
+	ci.entries[apiIdentity(c)].built = time.Now().Add(-2 * time.Hour)
+	ci.mu.Unlock()
+	if idx, ok, _ := ci.indexOrStart(c, true); !ok || *idx != 1 {
+		t.Error("expected the stale index while rebuilding")
+	}
+	waitForJob(t, ci.job)
+	if *ci.indexFor(c) != 2 {
+		t.Error("expected the stale index to be rebuilt")
+	}
+
+	// Without retry a failed build is reported instead of restarted
+	fail = true
+	ci.mu.Lock()
+	delete(ci.entries, apiIdentity(c))
+	ci.mu.Unlock()
+	ci.start(c)
+	waitForJob(t, ci.job)
+	started := ci.job.snapshot().Started
+	if _, ok, state := ci.indexOrStart(c, false); ok || state.Pending || state.Unavailable != "boom" {
+		t.Errorf("expected the failure to be reported, got %+v", state)
+	}
+	if ci.job.snapshot().Started != started {
+		t.Error("expected no new build without retry")
+	}
+}
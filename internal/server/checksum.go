@@ -0,0 +1,113 @@
+package server
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"strings"
+
+	"github.com/rmrfslashbin/manuals-webui/internal/client"
+)
+
+var (
+	// errChecksumMismatch means the content does not hash to the document's checksum.
+	errChecksumMismatch = errors.New("checksum mismatch")
+
+	// errSizeMismatch means the content length differs from the document's SizeBytes.
+	errSizeMismatch = errors.New("size mismatch")
+)
+
+// parseChecksum splits a document checksum into a hash constructor and the
+// lowercase hex digest. Both "sha256:<hex>" and bare hex digests are
+// accepted; a bare digest's algorithm is inferred from its length.
+func parseChecksum(checksum string) (newHash func() hash.Hash, digest string, ok bool) {
+	algo, digest, found := strings.Cut(strings.TrimSpace(checksum), ":")
+	if !found {
+		algo, digest = "", algo
+	}
+	digest = strings.ToLower(digest)
+	if _, err := hex.DecodeString(digest); err != nil {
+		return nil, "", false
+	}
+
+	if algo == "" {
+		switch len(digest) {
+		case sha256.Size * 2:
+			algo = "sha256"
+		case sha1.Size * 2:
+			algo = "sha1"
+		case md5.Size * 2:
+			algo = "md5"
+		case sha512.Size * 2:
+			algo = "sha512"
+		}
+	}
+
+	switch strings.ToLower(algo) {
+	case "sha256":
+		newHash = sha256.New
+	case "sha1":
+		newHash = sha1.New
+	case "md5":
+		newHash = md5.New
+	case "sha512":
+		newHash = sha512.New
+	default:
+		return nil, "", false
+	}
+
+	if len(digest) != newHash().Size()*2 {
+		return nil, "", false
+	}
+	return newHash, digest, true
+}
+
+// checksumVerifier hashes content as it is written so it can be checked
+// against a document's size and checksum once the copy completes.
+type checksumVerifier struct {
+	hash    hash.Hash // nil if the checksum format is not recognized
+	digest  string
+	want    int64
+	written int64
+}
+
+func newChecksumVerifier(doc *client.Document) *checksumVerifier {
+	v := &checksumVerifier{want: doc.SizeBytes}
+	if newHash, digest, ok := parseChecksum(doc.Checksum); ok {
+		v.hash = newHash()
+		v.digest = digest
+	}
+	return v
+}
+
+// hasChecksum reports whether the content hash can be verified.
+func (v *checksumVerifier) hasChecksum() bool {
+	return v.hash != nil
+}
+
+// Write implements io.Writer.
+func (v *checksumVerifier) Write(p []byte) (int, error) {
+	if v.hash != nil {
+		v.hash.Write(p)
+	}
+	v.written += int64(len(p))
+	return len(p), nil
+}
+
+// verify compares what was written with the expected size and checksum.
+func (v *checksumVerifier) verify() error {
+	if v.want > 0 && v.written != v.want {
+		return fmt.Errorf("%w: expected %d bytes, got %d", errSizeMismatch, v.want, v.written)
+	}
+	if v.hash != nil {
+		if got := hex.EncodeToString(v.hash.Sum(nil)); got != v.digest {
+			return fmt.Errorf("%w: expected %s, got %s", errChecksumMismatch, v.digest, got)
+		}
+	}
+	return nil
+}
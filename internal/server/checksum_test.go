@@ -0,0 +1,48 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+)
+
+func TestParseChecksum(t *testing.T) {
+	sum := sha256.Sum256([]byte("hello"))
+	digest := hex.EncodeToString(sum[:])
+
+	tests := []struct {
+		name     string
+		checksum string
+		digest   string
+		ok       bool
+	}{
+		{"prefixed", "sha256:" + digest, digest, true},
+		{"bare sha256", digest, digest, true},
+		{"uppercase", "SHA256:" + "2CF24DBA5FB0A30E26E83B2AC5B9E29E1B161E5C1FA7425E73043362938B9824", digest, true},
+		{"bare md5", "5d41402abc4b2a76b9719d911017c592", "5d41402abc4b2a76b9719d911017c592", true},
+		{"wrong length for algorithm", "sha256:5d41402abc4b2a76b9719d911017c592", "", false},
+		{"unknown algorithm", "crc32:3610a686", "", false},
+		{"not hex", "sha256:xyz", "", false},
+		{"empty", "", "", false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			newHash, got, ok := parseChecksum(tc.checksum)
+			if ok != tc.ok {
+				t.Fatalf("parseChecksum(%q) ok = %v, want %v", tc.checksum, ok, tc.ok)
+			}
+			if !ok {
+				return
+			}
+			if got != tc.digest {
+				t.Errorf("digest = %q, want %q", got, tc.digest)
+			}
+			h := newHash()
+			h.Write([]byte("hello"))
+			if hex.EncodeToString(h.Sum(nil)) != tc.digest {
+				t.Error("hash constructor does not match the digest")
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package server
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rmrfslashbin/manuals-webui/internal/client"
+)
+
+const (
+	// deviceDirectoryTTL is how long a device listing is reused.
+	deviceDirectoryTTL = 5 * time.Minute
+
+	// devicePageSize is how many devices are listed per API call.
+	devicePageSize = 100
+)
+
+// deviceDirectory caches every device of an API, keyed by the API's base
+// URL since sessions may point at different servers. It lets pages show
+// device names and domains next to documents without a lookup per row.
+type deviceDirectory struct {
+	cache *listingCache[*deviceIndex]
+}
+
+// deviceIndex is one cached device listing.
+type deviceIndex struct {
+	Devices []client.Device // sorted by name
+	byID    map[string]*client.Device
+}
+
+func newDeviceDirectory() *deviceDirectory {
+	return &deviceDirectory{cache: newListingCache[*deviceIndex](deviceDirectoryTTL)}
+}
+
+// get returns the devices c's API key can see, listing them if the cached
+// copy is missing or older than deviceDirectoryTTL. Concurrent callers for
+// the same API and key wait for a single listing instead of each paging
+// through it.
+func (d *deviceDirectory) get(ctx context.Context, c *client.Client) (*deviceIndex, error) {
+	return d.cache.get(ctx, apiIdentity(c), func(ctx context.Context) (*deviceIndex, error) {
+		var devices []client.Device
+		err := listAllDevices(ctx, c, func(total int, page []client.Device) error {
+			devices = append(devices, page...)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		return newDeviceIndex(devices), nil
+	})
+}
+
+// invalidate drops every cached listing, e.g. after a reindex.
+func (d *deviceDirectory) invalidate() {
+	d.cache.invalidate()
+}
+
+func newDeviceIndex(devices []client.Device) *deviceIndex {
+	sort.Slice(devices, func(i, j int) bool {
+		return strings.ToLower(devices[i].Name) < strings.ToLower(devices[j].Name)
+	})
+	idx := &deviceIndex{
+		Devices: devices,
+		byID:    make(map[string]*client.Device, len(devices)),
+	}
+	for i := range devices {
+		idx.byID[devices[i].ID] = &devices[i]
+	}
+	return idx
+}
+
+// lookup returns the device with the given ID, or nil.
+func (idx *deviceIndex) lookup(id string) *client.Device {
+	if idx == nil {
+		return nil
+	}
+	return idx.byID[id]
+}
+
+// Domains returns the distinct device domains, sorted.
+func (idx *deviceIndex) Domains() []string {
+	seen := make(map[string]bool)
+	var domains []string
+	for _, d := range idx.Devices {
+		if d.Domain != "" && !seen[d.Domain] {
+			seen[d.Domain] = true
+			domains = append(domains, d.Domain)
+		}
+	}
+	sort.Strings(domains)
+	return domains
+}
+
+// listAllDevices pages through ListDevices, calling fn for each page.
+func listAllDevices(ctx context.Context, c *client.Client, fn func(total int, page []client.Device) error) error {
+	for offset := 0; ; {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		resp, err := c.ListDevices(devicePageSize, offset, "", "")
+		if err != nil {
+			return err
+		}
+		if err := fn(resp.Total, resp.Data); err != nil {
+			return err
+		}
+		offset += len(resp.Data)
+		if len(resp.Data) == 0 || offset >= resp.Total {
+			return nil
+		}
+	}
+}
+
+// eachDevice calls fn for every device from a bounded number of
+// goroutines, stopping early if ctx is cancelled.
+func eachDevice(ctx context.Context, devices []client.Device, workers int, fn func(client.Device)) error {
+	queue := make(chan client.Device)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for device := range queue {
+				fn(device)
+			}
+		}()
+	}
+
+feed:
+	for _, device := range devices {
+		select {
+		case queue <- device:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(queue)
+	wg.Wait()
+	return ctx.Err()
+}
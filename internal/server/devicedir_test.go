@@ -0,0 +1,75 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/rmrfslashbin/manuals-webui/internal/client"
+)
+
+func TestDeviceDirectory(t *testing.T) {
+	var devices []client.Device
+	for _, id := range []string{"c", "a", "b"} {
+		devices = append(devices, client.Device{ID: id, Name: "Device " + id, Domain: "hardware"})
+	}
+	devices[2].Domain = "protocol"
+
+	// More devices than fit in one page
+	for i := 0; i < devicePageSize; i++ {
+		devices = append(devices, client.Device{ID: fmt.Sprintf("extra-%03d", i), Name: "zz", Domain: "hardware"})
+	}
+
+	api := catalogAPIServer(t, devices, nil)
+	defer api.Close()
+	c := client.New(api.URL, "test-key")
+
+	dir := newDeviceDirectory()
+	idx, err := dir.get(context.Background(), c)
+	if err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	if len(idx.Devices) != len(devices) {
+		t.Fatalf("expected %d devices, got %d", len(devices), len(idx.Devices))
+	}
+	if idx.Devices[0].ID != "a" {
+		t.Errorf("expected devices sorted by name, got %s first", idx.Devices[0].ID)
+	}
+	if d := idx.lookup("b"); d == nil || d.Name != "Device b" {
+		t.Errorf("lookup(b) = %+v", d)
+	}
+	if got := idx.Domains(); len(got) != 2 || got[0] != "hardware" || got[1] != "protocol" {
+		t.Errorf("Domains = %v", got)
+	}
+	if n := api.count("devices"); n != 2 {
+		t.Errorf("expected 2 pages, got %d requests", n)
+	}
+
+	if _, err := dir.get(context.Background(), c); err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	if n := api.count("devices"); n != 2 {
+		t.Errorf("expected a cached listing, got %d requests", n)
+	}
+
+	// Another key for the same API may see other devices
+	if _, err := dir.get(context.Background(), client.New(api.URL, "other-key")); err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	if n := api.count("devices"); n != 4 {
+		t.Errorf("expected a separate listing per API key, got %d requests", n)
+	}
+
+	dir.invalidate()
+	if _, err := dir.get(context.Background(), c); err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	if n := api.count("devices"); n != 6 {
+		t.Errorf("expected a fresh listing after invalidate, got %d requests", n)
+	}
+
+	var missing *deviceIndex
+	if missing.lookup("a") != nil {
+		t.Error("expected lookup on a nil index to return nil")
+	}
+}
@@ -0,0 +1,217 @@
+package server
+
+import (
+	"context"
+	"html/template"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rmrfslashbin/manuals-webui/internal/client"
+)
+
+const (
+	// specIndexConcurrency is how many spec requests run at once while
+	// building the index.
+	specIndexConcurrency = 4
+
+	// specIndexTTL is how long an index is served before it is rebuilt
+	// in the background.
+	specIndexTTL = time.Hour
+
+	// maxFindResults caps the ranked results shown for a query.
+	maxFindResults = 50
+)
+
+// specExamples are offered on the find page to show the query syntax.
+var specExamples = []string{
+	"flash >= 4MB, voltage = 3.3V, has wifi",
+	"clock >= 100 MHz, sram >= 256 KB",
+	"current <= 1 mA, has i2c",
+}
+
+// specDevice is a device with its parsed specs.
+type specDevice struct {
+	Device client.Device
+	Specs  []specEntry
+}
+
+// specIndex holds the specs of every device of one API.
+type specIndex struct {
+	Devices []specDevice // only devices with specs
+	Failed  int          // devices whose specs could not be loaded
+}
+
+// newSpecCatalog loads every device's specs in the background so queries
+// can compare them without an API request per device.
+func newSpecCatalog(s *Server) *catalogIndex[*specIndex] {
+	return newCatalogIndex(s, "spec index", specIndexTTL, s.buildSpecIndex)
+}
+
+// buildSpecIndex fetches the specs of every device with a bounded number
+// of concurrent requests. Devices without specs are left out.
+func (s *Server) buildSpecIndex(ctx context.Context, c *client.Client, p *jobProgress) (*specIndex, error) {
+	devices, err := s.devices.get(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	p.setTotal(int64(len(devices.Devices)))
+
+	idx := &specIndex{}
+	var mu sync.Mutex
+	var lastErr error
+	err = eachDevice(ctx, devices.Devices, specIndexConcurrency, func(device client.Device) {
+		resp, err := c.GetDeviceSpecs(device.ID)
+		p.add(1)
+
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case isNotFound(err):
+		case err != nil:
+			idx.Failed++
+			lastErr = err
+		case len(resp.Specs) > 0:
+			sd := specDevice{Device: device}
+			for key, value := range resp.Specs {
+				sd.Specs = append(sd.Specs, newSpecEntry(key, value))
+			}
+			sort.Slice(sd.Specs, func(i, j int) bool { return sd.Specs[i].Key < sd.Specs[j].Key })
+			idx.Devices = append(idx.Devices, sd)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	if idx.Failed > 0 && idx.Devices == nil {
+		return nil, lastErr
+	}
+	if idx.Failed > 0 {
+		s.logger.Warn("failed to load some device specs", "failed", idx.Failed, "error", lastErr)
+	}
+	return idx, nil
+}
+
+// deviceFindResult is a device ranked against a spec query.
+type deviceFindResult struct {
+	Device  client.Device
+	Matches []specMatch
+	Matched int     // criteria fully satisfied
+	Score   float64 // mean criterion score, 0 to 1
+}
+
+// Percent is the score as a percentage, for the match bar.
+func (r deviceFindResult) Percent() int {
+	return int(r.Score*100 + 0.5)
+}
+
+// find ranks every device against the criteria: most criteria satisfied
+// first, then by closeness of the near misses. Devices matching nothing
+// are left out. It returns at most limit results and the number found.
+func (idx *specIndex) find(criteria []specCriterion, limit int) ([]deviceFindResult, int) {
+	if len(criteria) == 0 {
+		return nil, 0
+	}
+	var results []deviceFindResult
+	for _, sd := range idx.Devices {
+		r := deviceFindResult{Device: sd.Device}
+		var total float64
+		for i := range criteria {
+			m := criteria[i].match(sd.Specs)
+			if m.Matched {
+				r.Matched++
+			}
+			total += m.Score
+			r.Matches = append(r.Matches, m)
+		}
+		r.Score = total / float64(len(criteria))
+		if r.Score > 0 {
+			results = append(results, r)
+		}
+	}
+	sort.Slice(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if a.Matched != b.Matched {
+			return a.Matched > b.Matched
+		}
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		return strings.ToLower(a.Device.Name) < strings.ToLower(b.Device.Name)
+	})
+	found := len(results)
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results, found
+}
+
+// deviceFindData is passed to devices-find.html and its results partial.
+type deviceFindData struct {
+	Query    string
+	Criteria []specCriterion
+	Problem  string // query clauses that could not be understood
+	Examples []string
+
+	catalogState
+	Index   *specIndex
+	Results []deviceFindResult
+	Found   int
+}
+
+// URL is the find page for this query.
+func (d deviceFindData) URL() template.URL {
+	if d.Query == "" {
+		return "/devices/find"
+	}
+	return template.URL("/devices/find?" + url.Values{"q": {d.Query}}.Encode())
+}
+
+// PollURL is the results partial polled while the index is built.
+func (d deviceFindData) PollURL() template.URL {
+	return template.URL("/partials/devices/find?" + url.Values{"q": {d.Query}, "poll": {"1"}}.Encode())
+}
+
+// findDevices runs the query in the request against the spec index,
+// starting a build if there is none yet or the index is stale. Polls
+// from the results partial don't restart a build that failed.
+func (s *Server) findDevices(r *http.Request, retry bool) deviceFindData {
+	data := deviceFindData{
+		Query:    strings.TrimSpace(r.URL.Query().Get("q")),
+		Examples: specExamples,
+	}
+	criteria, err := parseSpecQuery(data.Query)
+	data.Criteria = criteria
+	if err != nil {
+		data.Problem = err.Error()
+	}
+
+	idx, ok, state := s.specs.indexOrStart(s.apiClient(r), retry)
+	data.catalogState = state
+	if ok {
+		data.Index = idx
+		data.Results, data.Found = idx.find(criteria, maxFindResults)
+	}
+	return data
+}
+
+func (s *Server) handleDeviceFind(w http.ResponseWriter, r *http.Request) {
+	s.render(w, "devices-find.html", pageData{
+		Title:   "Find Devices",
+		Content: s.findDevices(r, true),
+	})
+}
+
+// handleDeviceFindPartial refreshes the results, both when the query is
+// submitted and while the index is being built.
+func (s *Server) handleDeviceFindPartial(w http.ResponseWriter, r *http.Request) {
+	poll := r.URL.Query().Get("poll") != ""
+	data := s.findDevices(r, !poll)
+	if !poll {
+		w.Header().Set("HX-Push-Url", string(data.URL()))
+	}
+	s.renderPartial(w, "partials/device-find-results.html", data)
+}
@@ -0,0 +1,159 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rmrfslashbin/manuals-webui/internal/client"
+)
+
+// specResponses wraps specs for deviceAPIServer.
+func specResponses(specs map[string]map[string]string) map[string]any {
+	responses := make(map[string]any, len(specs))
+	for id, s := range specs {
+		responses[id] = client.SpecsResponse{DeviceID: id, Specs: s}
+	}
+	return responses
+}
+
+func specCatalogFixture() ([]client.Device, map[string]map[string]string) {
+	devices := []client.Device{
+		{ID: "esp32", Name: "ESP32", Domain: "hardware", Type: "mcu"},
+		{ID: "esp8266", Name: "ESP8266", Domain: "hardware", Type: "mcu"},
+		{ID: "rp2040", Name: "RP2040", Domain: "hardware", Type: "mcu"},
+		{ID: "bme280", Name: "BME280", Domain: "hardware", Type: "sensors"},
+		{ID: "mqtt", Name: "MQTT", Domain: "protocol", Type: "messaging"},
+	}
+	specs := map[string]map[string]string{
+		"esp32":   {"flash_size": "4 MB", "operating_voltage": "3.0-3.6V", "wifi": "802.11 b/g/n"},
+		"esp8266": {"flash_size": "1MB", "operating_voltage": "3.3V", "wifi": "yes"},
+		"rp2040":  {"flash_size": "2 MB", "supply_voltage": "1.8-3.3 V", "wifi": "no"},
+		"bme280":  {"supply_voltage": "1.71 - 3.6 V", "interface": "I2C, SPI"},
+	}
+	return devices, specs
+}
+
+func TestSpecIndexFind(t *testing.T) {
+	devices, specs := specCatalogFixture()
+	api, requests := deviceAPIServer(t, devices, map[string]map[string]any{"specs": specResponses(specs)})
+	defer api.Close()
+	s := testServer(t, api)
+	c := client.New(api.URL, "test-key")
+
+	if !s.specs.start(c) {
+		t.Fatal("expected the build to start")
+	}
+	if st := waitForJob(t, s.specs.job); st.Error != "" || st.Done != 5 {
+		t.Fatalf("unexpected build status %+v", st)
+	}
+	if n := requests.Load(); n != 5 {
+		t.Errorf("expected one spec request per device, got %d", n)
+	}
+	idx := s.specs.indexFor(c)
+	if idx == nil || len(idx.Devices) != 4 || idx.Failed != 0 {
+		t.Fatalf("expected 4 devices with specs and no failures, got %+v", idx)
+	}
+
+	criteria, err := parseSpecQuery("flash >= 4MB, voltage = 3.3V, has wifi")
+	if err != nil {
+		t.Fatalf("parseSpecQuery failed: %v", err)
+	}
+	results, found := idx.find(criteria, 3)
+	if found != 4 {
+		t.Errorf("expected every device with specs to match something, got %d", found)
+	}
+	var ranked []string
+	for _, r := range results {
+		ranked = append(ranked, r.Device.ID)
+	}
+	// esp32 meets all three; esp8266 misses flash by 3 MB, rp2040 by 2 MB
+	// and lacks wifi
+	if strings.Join(ranked, ",") != "esp32,esp8266,rp2040" {
+		t.Errorf("ranking = %v", ranked)
+	}
+	if results[0].Matched != 3 || results[0].Percent() != 100 {
+		t.Errorf("expected a full match first, got %+v", results[0])
+	}
+}
+
+func TestHandleDeviceFind(t *testing.T) {
+	devices, specs := specCatalogFixture()
+	api, _ := deviceAPIServer(t, devices, map[string]map[string]any{"specs": specResponses(specs)})
+	defer api.Close()
+	s := testServer(t, api)
+
+	get := func(target string, htmx bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", target, nil)
+		if htmx {
+			req.Header.Set("HX-Request", "true")
+		}
+		w := httptest.NewRecorder()
+		s.Handler().ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET %s: expected status 200, got %d", target, w.Code)
+		}
+		return w
+	}
+
+	// The first visit starts building the index and polls for it
+	body := get("/devices/find?q=flash+%3E%3D+2MB", false).Body.String()
+	if !strings.Contains(body, "Loading device specs") {
+		t.Error("expected a progress message while the index builds")
+	}
+	if !strings.Contains(body, `hx-get="/partials/devices/find?poll=1&amp;q=flash&#43;%3E%3D&#43;2MB"`) {
+		t.Error("expected the results to poll with the query")
+	}
+	waitForJob(t, s.specs.job)
+
+	w := get("/partials/devices/find?q=flash+%3E%3D+2MB%2C+bogus+%3E+lots", true)
+	if got := w.Header().Get("HX-Push-Url"); got != "/devices/find?q=flash+%3E%3D+2MB%2C+bogus+%3E+lots" {
+		t.Errorf("expected HX-Push-Url to the page, got %q", got)
+	}
+	body = w.Body.String()
+	if strings.Contains(body, "<html") {
+		t.Error("expected a partial, not a full page")
+	}
+	for _, want := range []string{`href="/devices/esp32"`, `href="/devices/rp2040"`, "flash_size = 2 MB", "bogus &gt; lots", "1 / 1 met"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected results to contain %q", want)
+		}
+	}
+	if strings.Contains(body, `href="/devices/mqtt"`) {
+		t.Error("expected devices without specs to be left out")
+	}
+
+	// Polls don't push a URL
+	if got := get("/partials/devices/find?q=x&poll=1", true).Header().Get("HX-Push-Url"); got != "" {
+		t.Errorf("expected no HX-Push-Url for polls, got %q", got)
+	}
+}
+
+func TestHandleDeviceFindBuildFailure(t *testing.T) {
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/devices") {
+			json.NewEncoder(w).Encode(client.DevicesResponse{Data: []client.Device{{ID: "a"}}, Total: 1})
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer api.Close()
+	s := testServer(t, api)
+
+	s.specs.start(client.New(api.URL, "test-key"))
+	if st := waitForJob(t, s.specs.job); st.Error == "" {
+		t.Fatal("expected the build to fail")
+	}
+
+	req := httptest.NewRequest("GET", "/partials/devices/find?q=flash&poll=1", nil)
+	w := httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, req)
+	if !strings.Contains(w.Body.String(), "could not be loaded") {
+		t.Error("expected polls to report the failure instead of rebuilding")
+	}
+	if s.specs.job.snapshot().Running {
+		t.Error("expected no rebuild from a poll")
+	}
+}
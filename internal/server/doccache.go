@@ -0,0 +1,353 @@
+package server
+
+import (
+	"container/list"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rmrfslashbin/manuals-webui/internal/client"
+)
+
+// errNotCacheable is returned by newFill for documents the cache cannot
+// hold, e.g. because their checksum cannot be verified.
+var errNotCacheable = errors.New("document is not cacheable")
+
+// maxCacheIDLength bounds document IDs so cache file names stay short.
+const maxCacheIDLength = 100
+
+// documentCache is a size-capped, least-recently-used cache of document
+// content on disk. Files are named <hex(id)>.<digest> so the index can be
+// rebuilt from the directory after a restart.
+type documentCache struct {
+	dir      string
+	maxBytes int64
+	logger   *slog.Logger
+
+	mu      sync.Mutex
+	lru     *list.List // front is most recently used
+	entries map[string]*list.Element
+	size    int64
+	hits    int64
+	misses  int64
+}
+
+// cacheEntry is a single cached document.
+type cacheEntry struct {
+	ID         string
+	Digest     string
+	Size       int64
+	LastAccess time.Time
+}
+
+func (e *cacheEntry) name() string {
+	return cacheFileName(e.ID, e.Digest)
+}
+
+func cacheFileName(id, digest string) string {
+	return hex.EncodeToString([]byte(id)) + "." + digest
+}
+
+// cacheStats summarizes the cache for the admin page.
+type cacheStats struct {
+	Dir       string
+	Entries   int
+	UsedBytes int64
+	MaxBytes  int64
+	Hits      int64
+	Misses    int64
+	Recent    []cacheEntry
+}
+
+// UsedPercent is the share of the size cap in use, for the usage bar.
+func (st cacheStats) UsedPercent() int {
+	if st.MaxBytes == 0 {
+		return 0
+	}
+	return int(st.UsedBytes * 100 / st.MaxBytes)
+}
+
+// newDocumentCache opens or creates a cache in dir and indexes any files
+// left from a previous run, oldest first, evicting down to maxBytes.
+func newDocumentCache(dir string, maxBytes int64, logger *slog.Logger) (*documentCache, error) {
+	if maxBytes <= 0 {
+		return nil, fmt.Errorf("document cache size must be positive")
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory: %w", err)
+	}
+
+	c := &documentCache{
+		dir:      dir,
+		maxBytes: maxBytes,
+		logger:   logger,
+		lru:      list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cache directory: %w", err)
+	}
+
+	var existing []*cacheEntry
+	for _, f := range files {
+		if f.IsDir() {
+			continue
+		}
+		// Leftover partial fills from an interrupted run
+		if strings.HasPrefix(f.Name(), "fill-") {
+			os.Remove(filepath.Join(dir, f.Name()))
+			continue
+		}
+		hexID, digest, ok := strings.Cut(f.Name(), ".")
+		id, err := hex.DecodeString(hexID)
+		if !ok || err != nil {
+			continue
+		}
+		info, err := f.Info()
+		if err != nil {
+			continue
+		}
+		existing = append(existing, &cacheEntry{
+			ID:         string(id),
+			Digest:     digest,
+			Size:       info.Size(),
+			LastAccess: info.ModTime(),
+		})
+	}
+
+	sort.Slice(existing, func(i, j int) bool { return existing[i].LastAccess.Before(existing[j].LastAccess) })
+	c.mu.Lock()
+	for _, e := range existing {
+		c.addLocked(e)
+	}
+	c.evictLocked()
+	c.mu.Unlock()
+
+	return c, nil
+}
+
+// open returns the cached content for a document if present. The caller
+// must close the file.
+func (c *documentCache) open(doc *client.Document) (*os.File, *cacheEntry, bool) {
+	_, digest, ok := parseChecksum(doc.Checksum)
+	if !ok {
+		return nil, nil, false
+	}
+	name := cacheFileName(doc.ID, digest)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, found := c.entries[name]
+	if !found {
+		c.misses++
+		return nil, nil, false
+	}
+
+	f, err := os.Open(filepath.Join(c.dir, name))
+	if err != nil {
+		// Removed behind our back; forget it
+		c.removeLocked(el)
+		c.misses++
+		return nil, nil, false
+	}
+
+	entry := el.Value.(*cacheEntry)
+	entry.LastAccess = time.Now()
+	c.lru.MoveToFront(el)
+	c.hits++
+
+	// Persist the access time so LRU order survives restarts
+	_ = os.Chtimes(f.Name(), entry.LastAccess, entry.LastAccess)
+
+	copied := *entry
+	return f, &copied, true
+}
+
+// cacheFill stages a document in the cache while it is being streamed.
+type cacheFill struct {
+	cache    *documentCache
+	entry    *cacheEntry
+	tmp      *os.File
+	verifier *checksumVerifier
+	err      error // first failed write to tmp
+}
+
+// newFill starts caching a document. It returns errNotCacheable when the
+// document's checksum format is unknown or it could never fit.
+func (c *documentCache) newFill(doc *client.Document) (*cacheFill, error) {
+	verifier := newChecksumVerifier(doc)
+	if !verifier.hasChecksum() || len(doc.ID) > maxCacheIDLength || doc.SizeBytes > c.maxBytes {
+		return nil, errNotCacheable
+	}
+
+	tmp, err := os.CreateTemp(c.dir, "fill-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cache file: %w", err)
+	}
+
+	return &cacheFill{
+		cache:    c,
+		entry:    &cacheEntry{ID: doc.ID, Digest: verifier.digest},
+		tmp:      tmp,
+		verifier: verifier,
+	}, nil
+}
+
+// Write implements io.Writer. It never fails, so a full cache disk
+// doesn't cut the client's download short; the first write error is kept
+// and makes Commit refuse the fill instead.
+func (f *cacheFill) Write(p []byte) (int, error) {
+	if f.err == nil {
+		if _, err := f.tmp.Write(p); err != nil {
+			f.err = err
+		}
+	}
+	f.verifier.Write(p)
+	return len(p), nil
+}
+
+// Abort discards a partial fill.
+func (f *cacheFill) Abort() {
+	f.tmp.Close()
+	os.Remove(f.tmp.Name())
+}
+
+// Commit verifies the checksum and size and moves the file into the cache.
+func (f *cacheFill) Commit() error {
+	if err := errors.Join(f.err, f.tmp.Close()); err != nil {
+		os.Remove(f.tmp.Name())
+		return fmt.Errorf("failed to write cache file: %w", err)
+	}
+
+	if err := f.verifier.verify(); err != nil {
+		os.Remove(f.tmp.Name())
+		return err
+	}
+
+	c := f.cache
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := os.Rename(f.tmp.Name(), filepath.Join(c.dir, f.entry.name())); err != nil {
+		os.Remove(f.tmp.Name())
+		return fmt.Errorf("failed to store cache file: %w", err)
+	}
+
+	f.entry.Size = f.verifier.written
+	f.entry.LastAccess = time.Now()
+	if el, ok := c.entries[f.entry.name()]; ok {
+		c.size -= el.Value.(*cacheEntry).Size
+		c.lru.Remove(el)
+		delete(c.entries, f.entry.name())
+	}
+	c.addLocked(f.entry)
+	c.evictLocked()
+	return nil
+}
+
+// purge removes every cached document.
+func (c *documentCache) purge() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var errs []error
+	for el := c.lru.Front(); el != nil; {
+		next := el.Next()
+		if err := os.Remove(filepath.Join(c.dir, el.Value.(*cacheEntry).name())); err != nil && !errors.Is(err, os.ErrNotExist) {
+			errs = append(errs, err)
+		}
+		c.removeLocked(el)
+		el = next
+	}
+	c.hits, c.misses = 0, 0
+	return errors.Join(errs...)
+}
+
+// stats returns usage figures and the most recently used entries.
+func (c *documentCache) stats(recent int) cacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	st := cacheStats{
+		Dir:       c.dir,
+		Entries:   c.lru.Len(),
+		UsedBytes: c.size,
+		MaxBytes:  c.maxBytes,
+		Hits:      c.hits,
+		Misses:    c.misses,
+	}
+	for el := c.lru.Front(); el != nil && len(st.Recent) < recent; el = el.Next() {
+		st.Recent = append(st.Recent, *el.Value.(*cacheEntry))
+	}
+	return st
+}
+
+func (c *documentCache) addLocked(e *cacheEntry) {
+	c.entries[e.name()] = c.lru.PushFront(e)
+	c.size += e.Size
+}
+
+func (c *documentCache) removeLocked(el *list.Element) {
+	e := el.Value.(*cacheEntry)
+	c.lru.Remove(el)
+	delete(c.entries, e.name())
+	c.size -= e.Size
+}
+
+// evictLocked drops least recently used entries until the cache fits.
+func (c *documentCache) evictLocked() {
+	for c.size > c.maxBytes {
+		el := c.lru.Back()
+		if el == nil {
+			return
+		}
+		e := el.Value.(*cacheEntry)
+		if err := os.Remove(filepath.Join(c.dir, e.name())); err != nil && !errors.Is(err, os.ErrNotExist) {
+			c.logger.Warn("failed to evict cached document", "id", e.ID, "error", err)
+		}
+		c.removeLocked(el)
+	}
+}
+
+// Admin handlers
+
+func (s *Server) handleAdminCache(w http.ResponseWriter, r *http.Request) {
+	s.render(w, "admin-cache.html", pageData{
+		Title:   "Document Cache",
+		Content: s.cacheStats(),
+	})
+}
+
+func (s *Server) handleAdminCachePurge(w http.ResponseWriter, r *http.Request) {
+	if s.docCache != nil {
+		if err := s.docCache.purge(); err != nil {
+			s.logger.Error("failed to purge document cache", "error", err)
+			s.renderErrorStatus(w, r, http.StatusInternalServerError, "Failed to purge cache: "+err.Error())
+			return
+		}
+		s.logger.Info("document cache purged")
+	}
+
+	s.renderPartial(w, "partials/cache-stats.html", s.cacheStats())
+}
+
+// cacheStats returns the stats shown on the admin page; a nil pointer
+// means caching is disabled.
+func (s *Server) cacheStats() *cacheStats {
+	if s.docCache == nil {
+		return nil
+	}
+	st := s.docCache.stats(50)
+	return &st
+}
@@ -0,0 +1,289 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/rmrfslashbin/manuals-webui/internal/client"
+)
+
+func testDocument(id, content string) *client.Document {
+	sum := sha256.Sum256([]byte(content))
+	return &client.Document{
+		ID:        id,
+		Filename:  id + ".pdf",
+		MimeType:  "application/pdf",
+		SizeBytes: int64(len(content)),
+		Checksum:  "sha256:" + hex.EncodeToString(sum[:]),
+	}
+}
+
+func fillCache(t *testing.T, c *documentCache, doc *client.Document, content string) error {
+	t.Helper()
+	fill, err := c.newFill(doc)
+	if err != nil {
+		t.Fatalf("newFill failed: %v", err)
+	}
+	io.WriteString(fill, content)
+	return fill.Commit()
+}
+
+func TestDocumentCacheFillAndOpen(t *testing.T) {
+	c, err := newDocumentCache(t.TempDir(), 1<<20, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("newDocumentCache failed: %v", err)
+	}
+
+	doc := testDocument("doc-1", "datasheet")
+	if _, _, ok := c.open(doc); ok {
+		t.Fatal("expected miss on empty cache")
+	}
+	if err := fillCache(t, c, doc, "datasheet"); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+
+	f, entry, ok := c.open(doc)
+	if !ok {
+		t.Fatal("expected hit after fill")
+	}
+	defer f.Close()
+	content, _ := io.ReadAll(f)
+	if string(content) != "datasheet" {
+		t.Errorf("expected cached content, got %q", content)
+	}
+	if entry.Size != 9 {
+		t.Errorf("expected size 9, got %d", entry.Size)
+	}
+
+	// A new checksum for the same ID is a different entry
+	if _, _, ok := c.open(testDocument("doc-1", "datasheet v2")); ok {
+		t.Error("expected miss for a changed checksum")
+	}
+
+	st := c.stats(10)
+	if st.Hits != 1 || st.Misses != 2 || st.Entries != 1 {
+		t.Errorf("unexpected stats %+v", st)
+	}
+}
+
+func TestDocumentCacheRejectsMismatch(t *testing.T) {
+	dir := t.TempDir()
+	c, _ := newDocumentCache(dir, 1<<20, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	doc := testDocument("doc-1", "expected")
+	if err := fillCache(t, c, doc, "tampered"); err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Fatalf("expected checksum mismatch, got %v", err)
+	}
+	if _, _, ok := c.open(doc); ok {
+		t.Error("expected mismatched content not to be cached")
+	}
+
+	files, _ := os.ReadDir(dir)
+	if len(files) != 0 {
+		t.Errorf("expected staging file to be removed, found %d files", len(files))
+	}
+
+	if _, err := c.newFill(&client.Document{ID: "doc-2", Checksum: "unknown"}); err != errNotCacheable {
+		t.Errorf("expected errNotCacheable, got %v", err)
+	}
+}
+
+func TestDocumentCacheFillWriteError(t *testing.T) {
+	dir := t.TempDir()
+	c, _ := newDocumentCache(dir, 1<<20, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	content := "datasheet"
+	doc := testDocument("doc-1", content)
+	fill, err := c.newFill(doc)
+	if err != nil {
+		t.Fatalf("newFill failed: %v", err)
+	}
+	// Writes to the staging file now fail, as on a full disk
+	fill.tmp.Close()
+	if fill.tmp, err = os.Open(fill.tmp.Name()); err != nil {
+		t.Fatalf("failed to reopen staging file: %v", err)
+	}
+
+	// The download streams through the fill, so it must not be cut short
+	var body strings.Builder
+	if _, err := io.Copy(io.MultiWriter(&body, fill), strings.NewReader(content)); err != nil {
+		t.Fatalf("expected the copy to succeed, got %v", err)
+	}
+	if body.String() != content {
+		t.Errorf("expected the full body, got %q", body.String())
+	}
+	if err := fill.verifier.verify(); err != nil {
+		t.Errorf("expected the checksum to be verified, got %v", err)
+	}
+
+	if err := fill.Commit(); err == nil {
+		t.Error("expected Commit to refuse a failed fill")
+	}
+	if _, _, ok := c.open(doc); ok {
+		t.Error("expected a failed fill not to be cached")
+	}
+	files, _ := os.ReadDir(dir)
+	if len(files) != 0 {
+		t.Errorf("expected staging file to be removed, found %d files", len(files))
+	}
+}
+
+func TestDocumentCacheEviction(t *testing.T) {
+	dir := t.TempDir()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	c, _ := newDocumentCache(dir, 20, logger)
+
+	a := testDocument("a", strings.Repeat("a", 8))
+	b := testDocument("b", strings.Repeat("b", 8))
+	d := testDocument("d", strings.Repeat("d", 8))
+	fillCache(t, c, a, strings.Repeat("a", 8))
+	fillCache(t, c, b, strings.Repeat("b", 8))
+
+	// Touch a so that b is the least recently used
+	f, _, _ := c.open(a)
+	f.Close()
+
+	fillCache(t, c, d, strings.Repeat("d", 8))
+
+	if _, _, ok := c.open(b); ok {
+		t.Error("expected least recently used entry to be evicted")
+	}
+	for _, doc := range []*client.Document{a, d} {
+		f, _, ok := c.open(doc)
+		if !ok {
+			t.Errorf("expected %s to remain cached", doc.ID)
+			continue
+		}
+		f.Close()
+	}
+	if st := c.stats(10); st.UsedBytes != 16 {
+		t.Errorf("expected 16 bytes used, got %d", st.UsedBytes)
+	}
+
+	// The index is rebuilt from disk on restart
+	reopened, err := newDocumentCache(dir, 20, logger)
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	if st := reopened.stats(10); st.Entries != 2 || st.UsedBytes != 16 {
+		t.Errorf("unexpected stats after reopen %+v", st)
+	}
+
+	if err := reopened.purge(); err != nil {
+		t.Fatalf("purge failed: %v", err)
+	}
+	files, _ := os.ReadDir(dir)
+	if len(files) != 0 {
+		t.Errorf("expected purge to remove all files, found %d", len(files))
+	}
+}
+
+func TestHandleDownloadCached(t *testing.T) {
+	content := "%PDF-1.7 cached datasheet"
+	doc := testDocument("doc-1", content)
+	var downloads int
+
+	apiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/download"):
+			downloads++
+			w.Write([]byte(content))
+		case strings.Contains(r.URL.Path, "/admin/settings"):
+			json.NewEncoder(w).Encode(client.SettingsResponse{})
+		default:
+			json.NewEncoder(w).Encode(doc)
+		}
+	}))
+	defer apiServer.Close()
+
+	s, err := New(Config{
+		Client:        client.New(apiServer.URL, "test-key"),
+		Logger:        slog.New(slog.NewTextHandler(io.Discard, nil)),
+		CacheDir:      t.TempDir(),
+		CacheMaxBytes: 1 << 20,
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	handler := s.Handler()
+
+	get := func(header map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/download/doc-1", nil)
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	// First request streams from the API and fills the cache
+	if w := get(nil); w.Body.String() != content {
+		t.Fatalf("expected content on miss, got %q", w.Body.String())
+	}
+
+	w := get(nil)
+	if w.Code != http.StatusOK || w.Body.String() != content {
+		t.Fatalf("expected cached content, got %d %q", w.Code, w.Body.String())
+	}
+	etag := w.Header().Get("ETag")
+	if !strings.Contains(doc.Checksum, strings.Trim(etag, `"`)) || etag == "" {
+		t.Errorf("expected checksum ETag, got %q", etag)
+	}
+	if downloads != 1 {
+		t.Errorf("expected one upstream download, got %d", downloads)
+	}
+
+	if w := get(map[string]string{"If-None-Match": etag}); w.Code != http.StatusNotModified {
+		t.Errorf("expected 304 for matching ETag, got %d", w.Code)
+	}
+	if w := get(map[string]string{"Range": "bytes=0-3"}); w.Code != http.StatusPartialContent || w.Body.String() != "%PDF" {
+		t.Errorf("expected ranged hit, got %d %q", w.Code, w.Body.String())
+	}
+
+	// Admin page and purge
+	req := httptest.NewRequest("GET", "/admin/cache", nil)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "doc-1") {
+		t.Errorf("expected admin cache page listing doc-1, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest("POST", "/admin/cache/purge", nil)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "The cache is empty") {
+		t.Errorf("expected empty cache after purge, got %d", w.Code)
+	}
+
+	get(nil)
+	if downloads != 2 {
+		t.Errorf("expected download after purge to hit the API, got %d downloads", downloads)
+	}
+}
+
+func TestAdminCacheRequiresAdmin(t *testing.T) {
+	apiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(client.ErrorResponse{Error: "admin capability required"})
+	}))
+	defer apiServer.Close()
+
+	s := testServer(t, apiServer)
+
+	req := httptest.NewRequest("POST", "/admin/cache/purge", nil)
+	w := httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, req)
+
+	if w.Code != http.StatusForbidden {
+		t.Errorf("expected status 403, got %d", w.Code)
+	}
+}
@@ -0,0 +1,369 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"html/template"
+	"mime"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rmrfslashbin/manuals-webui/internal/client"
+)
+
+const (
+	// documentsPerPage is the page size of the documents listing.
+	documentsPerPage = 20
+
+	// maxListedDocuments caps how many documents are loaded to filter or
+	// sort a listing the API cannot filter itself.
+	maxListedDocuments = 5000
+
+	// documentListingTTL is how long a full listing is reused for filtering
+	// and sorting.
+	documentListingTTL = 5 * time.Minute
+)
+
+// documentTypes are the MIME type filters offered on the documents page.
+// A value ending in "/" matches every subtype.
+var documentTypes = []filterOption{
+	{Value: "application/pdf", Label: "PDF"},
+	{Value: "image/", Label: "Images"},
+	{Value: "text/markdown", Label: "Markdown"},
+	{Value: "text/", Label: "Text"},
+}
+
+// documentSorts are the orderings offered on the documents page. A "-"
+// prefix sorts in descending order.
+var documentSorts = []filterOption{
+	{Value: "filename", Label: "Filename (A–Z)"},
+	{Value: "-filename", Label: "Filename (Z–A)"},
+	{Value: "-size", Label: "Largest first"},
+	{Value: "size", Label: "Smallest first"},
+	{Value: "-indexed", Label: "Recently indexed"},
+	{Value: "indexed", Label: "Oldest indexed"},
+}
+
+// errListLimit stops a listing once maxListedDocuments have been read.
+var errListLimit = errors.New("document listing limit reached")
+
+// filterOption is a value offered by a filter or sort control.
+type filterOption struct {
+	Value string
+	Label string
+}
+
+// documentQuery is the state of the documents listing, kept in the URL.
+type documentQuery struct {
+	Device string
+	Domain string
+	Type   string
+	Q      string
+	Sort   string
+	View   string // "list" or "gallery"
+	Page   int
+}
+
+func parseDocumentQuery(v url.Values) documentQuery {
+	q := documentQuery{
+		Device: v.Get("device"),
+		Domain: v.Get("domain"),
+		Type:   v.Get("type"),
+		Q:      strings.TrimSpace(v.Get("q")),
+		Sort:   v.Get("sort"),
+		View:   v.Get("view"),
+	}
+	q.Page, _ = strconv.Atoi(v.Get("page"))
+	if q.Page < 1 {
+		q.Page = 1
+	}
+	if q.View != "gallery" {
+		q.View = "list"
+	}
+	if !validOption(documentSorts, q.Sort) {
+		q.Sort = ""
+	}
+	return q
+}
+
+func validOption(options []filterOption, value string) bool {
+	for _, o := range options {
+		if o.Value == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Filtered reports whether any filter narrows the listing.
+func (q documentQuery) Filtered() bool {
+	return q.Device != "" || q.Domain != "" || q.Type != "" || q.Q != ""
+}
+
+// needsFullListing reports whether the listing has to be filtered or
+// sorted here because the API only pages by device.
+func (q documentQuery) needsFullListing() bool {
+	return q.Domain != "" || q.Type != "" || q.Q != "" || q.Sort != ""
+}
+
+func (q documentQuery) values() url.Values {
+	v := url.Values{}
+	set := func(key, value string) {
+		if value != "" {
+			v.Set(key, value)
+		}
+	}
+	set("device", q.Device)
+	set("domain", q.Domain)
+	set("type", q.Type)
+	set("q", q.Q)
+	set("sort", q.Sort)
+	if q.View != "list" {
+		set("view", q.View)
+	}
+	if q.Page > 1 {
+		v.Set("page", strconv.Itoa(q.Page))
+	}
+	return v
+}
+
+func (q documentQuery) url(path string) template.URL {
+	if enc := q.values().Encode(); enc != "" {
+		return template.URL(path + "?" + enc)
+	}
+	return template.URL(path)
+}
+
+// URL is the documents page for this query.
+func (q documentQuery) URL() template.URL { return q.url("/documents") }
+
+// PageURL is the documents page for another page of this query.
+func (q documentQuery) PageURL(page int) template.URL {
+	q.Page = page
+	return q.url("/documents")
+}
+
+// PartialPageURL is the results partial for another page of this query.
+func (q documentQuery) PartialPageURL(page int) template.URL {
+	q.Page = page
+	return q.url("/partials/documents")
+}
+
+// ViewURL is the documents page for this query in another view.
+func (q documentQuery) ViewURL(view string) template.URL {
+	q.View = view
+	return q.url("/documents")
+}
+
+// matches reports whether a document passes the domain, type and
+// filename filters. The device filter is applied by the API.
+func (q documentQuery) matches(doc *client.Document, device *client.Device) bool {
+	if q.Domain != "" && (device == nil || device.Domain != q.Domain) {
+		return false
+	}
+	if q.Type != "" {
+		mediaType, _, _ := mime.ParseMediaType(doc.MimeType)
+		if strings.HasSuffix(q.Type, "/") {
+			if !strings.HasPrefix(mediaType, q.Type) {
+				return false
+			}
+		} else if mediaType != q.Type {
+			return false
+		}
+	}
+	if q.Q != "" && !strings.Contains(strings.ToLower(doc.Filename), strings.ToLower(q.Q)) {
+		return false
+	}
+	return true
+}
+
+// sortDocuments orders documents by one of documentSorts, breaking ties
+// by filename. An unknown order leaves the API order unchanged.
+func sortDocuments(docs []client.Document, order string) {
+	desc := strings.HasPrefix(order, "-")
+	key := strings.TrimPrefix(order, "-")
+
+	byName := func(a, b *client.Document) int {
+		return strings.Compare(strings.ToLower(a.Filename), strings.ToLower(b.Filename))
+	}
+	var compare func(a, b *client.Document) int
+	switch key {
+	case "filename":
+		compare = byName
+	case "size":
+		compare = func(a, b *client.Document) int {
+			if a.SizeBytes != b.SizeBytes {
+				if a.SizeBytes < b.SizeBytes {
+					return -1
+				}
+				return 1
+			}
+			return 0
+		}
+	case "indexed":
+		compare = func(a, b *client.Document) int {
+			return indexedTime(a.IndexedAt).Compare(indexedTime(b.IndexedAt))
+		}
+	default:
+		return
+	}
+
+	sort.SliceStable(docs, func(i, j int) bool {
+		c := compare(&docs[i], &docs[j])
+		if desc {
+			c = -c
+		}
+		if c == 0 {
+			c = byName(&docs[i], &docs[j])
+		}
+		return c < 0
+	})
+}
+
+// indexedTime parses an IndexedAt timestamp; unparseable values sort first.
+func indexedTime(s string) time.Time {
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// documentRow is a document with its owning device resolved.
+type documentRow struct {
+	client.Document
+	DeviceName   string
+	DeviceDomain string
+}
+
+type documentsData struct {
+	Documents []documentRow
+	Query     documentQuery
+	Total     int
+	HasNext   bool
+	Truncated bool // only the first maxListedDocuments were filtered
+
+	Devices []client.Device
+	Domains []string
+	Types   []filterOption
+	Sorts   []filterOption
+}
+
+// documentListing is every document of an API, or of one device, up to
+// maxListedDocuments.
+type documentListing struct {
+	Documents []client.Document
+	Truncated bool
+}
+
+// listAllDocumentsCached returns the documents of c's API, optionally for
+// one device, from a listing cached per API key and device so that paging,
+// filtering and htmx refreshes don't each list the whole catalog again.
+func (s *Server) listAllDocumentsCached(ctx context.Context, c *client.Client, deviceID string) (*documentListing, error) {
+	return s.documentList.get(ctx, apiIdentity(c)+"\x00"+deviceID, func(ctx context.Context) (*documentListing, error) {
+		listing := &documentListing{}
+		err := listAllDocuments(ctx, c, deviceID, func(total int, docs []client.Document) error {
+			listing.Documents = append(listing.Documents, docs...)
+			if len(listing.Documents) >= maxListedDocuments {
+				return errListLimit
+			}
+			return nil
+		})
+		if errors.Is(err, errListLimit) {
+			listing.Truncated = true
+		} else if err != nil {
+			return nil, err
+		}
+		return listing, nil
+	})
+}
+
+// listDocuments loads one page of the documents listing. Filters the API
+// supports are passed through; the rest are applied to the full listing.
+func (s *Server) listDocuments(ctx context.Context, apiClient *client.Client, q documentQuery) (documentsData, error) {
+	data := documentsData{Query: q, Types: documentTypes, Sorts: documentSorts}
+
+	// Names are a nicety unless the domain filter depends on them
+	devices, err := s.devices.get(ctx, apiClient)
+	if err != nil {
+		if q.Domain != "" {
+			return data, err
+		}
+		s.logger.Warn("failed to load device names", "error", err)
+	} else {
+		data.Devices = devices.Devices
+		data.Domains = devices.Domains()
+	}
+
+	var page []client.Document
+	offset := (q.Page - 1) * documentsPerPage
+	if !q.needsFullListing() {
+		resp, err := apiClient.ListDocuments(documentsPerPage, offset, q.Device)
+		if err != nil {
+			return data, err
+		}
+		page, data.Total = resp.Data, resp.Total
+	} else {
+		listing, err := s.listAllDocumentsCached(ctx, apiClient, q.Device)
+		if err != nil {
+			return data, err
+		}
+		data.Truncated = listing.Truncated
+
+		var matched []client.Document
+		for i := range listing.Documents {
+			if q.matches(&listing.Documents[i], devices.lookup(listing.Documents[i].DeviceID)) {
+				matched = append(matched, listing.Documents[i])
+			}
+		}
+
+		sortDocuments(matched, q.Sort)
+		data.Total = len(matched)
+		if offset < len(matched) {
+			page = matched[offset:min(offset+documentsPerPage, len(matched))]
+		}
+	}
+	data.HasNext = offset+len(page) < data.Total
+
+	data.Documents = make([]documentRow, len(page))
+	for i, doc := range page {
+		data.Documents[i] = documentRow{Document: doc}
+		if device := devices.lookup(doc.DeviceID); device != nil {
+			data.Documents[i].DeviceName = device.Name
+			data.Documents[i].DeviceDomain = device.Domain
+		}
+	}
+	return data, nil
+}
+
+func (s *Server) handleDocuments(w http.ResponseWriter, r *http.Request) {
+	q := parseDocumentQuery(r.URL.Query())
+	data, err := s.listDocuments(r.Context(), s.apiClient(r), q)
+	if err != nil {
+		s.renderError(w, r, "Failed to list documents", err)
+		return
+	}
+
+	s.render(w, "documents.html", pageData{
+		Title:   "Documents",
+		Content: data,
+	})
+}
+
+// handleDocumentsPartial refreshes the results when the filters change and
+// keeps the address bar in step so the view can be shared or reloaded.
+func (s *Server) handleDocumentsPartial(w http.ResponseWriter, r *http.Request) {
+	q := parseDocumentQuery(r.URL.Query())
+	data, err := s.listDocuments(r.Context(), s.apiClient(r), q)
+	if err != nil {
+		s.renderError(w, r, "Failed to list documents", err)
+		return
+	}
+
+	w.Header().Set("HX-Push-Url", string(q.URL()))
+	s.renderPartial(w, "partials/documents-results.html", data)
+}
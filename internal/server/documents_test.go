@@ -0,0 +1,279 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/rmrfslashbin/manuals-webui/internal/client"
+)
+
+// catalogAPI serves paged device and document listings, accepts admin
+// requests and counts the requests made to each endpoint.
+type catalogAPI struct {
+	*httptest.Server
+
+	mu    sync.Mutex
+	calls map[string]int
+}
+
+func (c *catalogAPI) count(endpoint string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.calls[endpoint]
+}
+
+func catalogAPIServer(t *testing.T, devices []client.Device, docs []client.Document) *catalogAPI {
+	t.Helper()
+	api := &catalogAPI{calls: make(map[string]int)}
+	page := func(r *http.Request, total int) (int, int) {
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		if limit <= 0 {
+			limit = 20
+		}
+		return min(offset, total), min(offset+limit, total)
+	}
+
+	api.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		endpoint := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+		api.mu.Lock()
+		api.calls[endpoint]++
+		api.mu.Unlock()
+
+		switch endpoint {
+		case "devices":
+			from, to := page(r, len(devices))
+			json.NewEncoder(w).Encode(client.DevicesResponse{Data: devices[from:to], Total: len(devices)})
+		case "documents":
+			matched := docs
+			if id := r.URL.Query().Get("device_id"); id != "" {
+				matched = nil
+				for _, d := range docs {
+					if d.DeviceID == id {
+						matched = append(matched, d)
+					}
+				}
+			}
+			from, to := page(r, len(matched))
+			json.NewEncoder(w).Encode(client.DocumentsResponse{Data: matched[from:to], Total: len(matched)})
+		case "settings":
+			// Lets requireAdmin pass
+			json.NewEncoder(w).Encode(client.SettingsResponse{})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	return api
+}
+
+func testCatalog() ([]client.Device, []client.Document) {
+	devices := []client.Device{
+		{ID: "esp32", Name: "ESP32 DevKit", Domain: "hardware", Type: "mcu"},
+		{ID: "bme280", Name: "BME280", Domain: "hardware", Type: "sensors"},
+		{ID: "mqtt", Name: "MQTT", Domain: "protocol", Type: "messaging"},
+	}
+	docs := []client.Document{
+		{ID: "d1", DeviceID: "esp32", Filename: "esp32-datasheet.pdf", MimeType: "application/pdf", SizeBytes: 3000, IndexedAt: "2025-01-03T00:00:00Z"},
+		{ID: "d2", DeviceID: "esp32", Filename: "pinout.png", MimeType: "image/png", SizeBytes: 500, IndexedAt: "2025-01-01T00:00:00Z"},
+		{ID: "d3", DeviceID: "bme280", Filename: "BME280-datasheet.pdf", MimeType: "application/pdf", SizeBytes: 1000, IndexedAt: "2025-01-02T00:00:00Z"},
+		{ID: "d4", DeviceID: "mqtt", Filename: "spec.md", MimeType: "text/markdown; charset=utf-8", SizeBytes: 200, IndexedAt: "2025-01-04T00:00:00Z"},
+	}
+	return devices, docs
+}
+
+func TestParseDocumentQuery(t *testing.T) {
+	v, _ := url.ParseQuery("device=esp32&q=%20data%20&sort=bogus&view=gallery&page=2")
+	q := parseDocumentQuery(v)
+
+	expected := documentQuery{Device: "esp32", Q: "data", View: "gallery", Page: 2}
+	if !reflect.DeepEqual(q, expected) {
+		t.Errorf("parseDocumentQuery = %+v, want %+v", q, expected)
+	}
+	if got := string(q.PageURL(3)); got != "/documents?device=esp32&page=3&q=data&view=gallery" {
+		t.Errorf("PageURL = %q", got)
+	}
+	if got := string(q.ViewURL("list")); got != "/documents?device=esp32&page=2&q=data" {
+		t.Errorf("ViewURL = %q", got)
+	}
+	if !q.Filtered() {
+		t.Error("expected query to be filtered")
+	}
+}
+
+func TestSortDocuments(t *testing.T) {
+	_, docs := testCatalog()
+
+	tests := []struct {
+		order    string
+		expected []string
+	}{
+		{"", []string{"d1", "d2", "d3", "d4"}},
+		{"filename", []string{"d3", "d1", "d2", "d4"}},
+		{"-filename", []string{"d4", "d2", "d1", "d3"}},
+		{"-size", []string{"d1", "d3", "d2", "d4"}},
+		{"indexed", []string{"d2", "d3", "d1", "d4"}},
+		{"-indexed", []string{"d4", "d1", "d3", "d2"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.order, func(t *testing.T) {
+			sorted := append([]client.Document(nil), docs...)
+			sortDocuments(sorted, tc.order)
+			var ids []string
+			for _, d := range sorted {
+				ids = append(ids, d.ID)
+			}
+			if !reflect.DeepEqual(ids, tc.expected) {
+				t.Errorf("order %q = %v, want %v", tc.order, ids, tc.expected)
+			}
+		})
+	}
+}
+
+func TestHandleDocumentsFilters(t *testing.T) {
+	devices, docs := testCatalog()
+	api := catalogAPIServer(t, devices, docs)
+	defer api.Close()
+	s := testServer(t, api.Server)
+
+	tests := []struct {
+		query    string
+		expected []string
+	}{
+		{"", []string{"d1", "d2", "d3", "d4"}},
+		{"device=esp32", []string{"d1", "d2"}},
+		{"domain=protocol", []string{"d4"}},
+		{"type=application%2Fpdf", []string{"d1", "d3"}},
+		{"type=text%2F", []string{"d4"}},
+		{"q=DATASHEET&sort=filename", []string{"d3", "d1"}},
+		{"domain=hardware&type=image%2F", []string{"d2"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.query, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/documents?"+tc.query, nil)
+			w := httptest.NewRecorder()
+			s.Handler().ServeHTTP(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d", w.Code)
+			}
+			body := w.Body.String()
+			for _, d := range docs {
+				want := false
+				for _, id := range tc.expected {
+					want = want || id == d.ID
+				}
+				if got := strings.Contains(body, `href="/documents/`+d.ID+`"`); got != want {
+					t.Errorf("document %s listed = %v, want %v", d.ID, got, want)
+				}
+			}
+		})
+	}
+
+	// Device names are resolved from a single cached listing
+	if n := api.count("devices"); n != 1 {
+		t.Errorf("expected devices to be listed once, got %d", n)
+	}
+}
+
+func TestHandleDocumentsShowsDeviceNames(t *testing.T) {
+	devices, docs := testCatalog()
+	api := catalogAPIServer(t, devices, docs)
+	defer api.Close()
+	s := testServer(t, api.Server)
+
+	req := httptest.NewRequest("GET", "/documents?device=bme280", nil)
+	w := httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, req)
+
+	body := w.Body.String()
+	if !strings.Contains(body, "BME280</span>") {
+		t.Error("expected the owning device name on the row")
+	}
+	if !strings.Contains(body, `<option value="bme280" selected>BME280</option>`) {
+		t.Error("expected the device filter to be selected")
+	}
+}
+
+func TestHandleDocumentsPartial(t *testing.T) {
+	devices, docs := testCatalog()
+	api := catalogAPIServer(t, devices, docs)
+	defer api.Close()
+	s := testServer(t, api.Server)
+
+	req := httptest.NewRequest("GET", "/partials/documents?q=pinout&view=gallery", nil)
+	req.Header.Set("HX-Request", "true")
+	w := httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	if got := w.Header().Get("HX-Push-Url"); got != "/documents?q=pinout&view=gallery" {
+		t.Errorf("expected HX-Push-Url to the page, got %q", got)
+	}
+	body := w.Body.String()
+	if strings.Contains(body, "<html") {
+		t.Error("expected a partial, not a full page")
+	}
+	if !strings.Contains(body, `src="/thumbnails/d2?size=medium"`) {
+		t.Error("expected the gallery view of the matching image")
+	}
+}
+
+func TestHandleDocumentsPaginatesFiltered(t *testing.T) {
+	devices := []client.Device{{ID: "dev", Name: "Dev", Domain: "hardware"}}
+	var docs []client.Document
+	for i := 0; i < 45; i++ {
+		docs = append(docs, client.Document{ID: "doc-" + strconv.Itoa(i), DeviceID: "dev", Filename: "f" + strconv.Itoa(100+i) + ".pdf", MimeType: "application/pdf"})
+	}
+	api := catalogAPIServer(t, devices, docs)
+	defer api.Close()
+	s := testServer(t, api.Server)
+
+	req := httptest.NewRequest("GET", "/documents?sort=-filename&page=3", nil)
+	w := httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, req)
+
+	body := w.Body.String()
+	for _, id := range []string{"doc-4", "doc-0"} {
+		if !strings.Contains(body, `href="/documents/`+id+`"`) {
+			t.Errorf("expected %s on the last page", id)
+		}
+	}
+	if strings.Contains(body, `href="/documents/doc-5"`) {
+		t.Error("expected doc-5 on the previous page")
+	}
+	if strings.Contains(body, "page=4") {
+		t.Error("expected no next page")
+	}
+	if !strings.Contains(body, "page=2") {
+		t.Error("expected a link to the previous page")
+	}
+
+	// Other pages and orderings reuse the cached listing until a reindex
+	listed := api.count("documents")
+	for _, query := range []string{"sort=-filename&page=1", "sort=size", "q=f12"} {
+		w = httptest.NewRecorder()
+		s.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/partials/documents?"+query, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200 for %s, got %d", query, w.Code)
+		}
+	}
+	if n := api.count("documents"); n != listed {
+		t.Errorf("expected the cached listing to be reused, got %d more requests", n-listed)
+	}
+	s.documentList.invalidate()
+	s.Handler().ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/documents?sort=size", nil))
+	if n := api.count("documents"); n == listed {
+		t.Error("expected a fresh listing after invalidation")
+	}
+}
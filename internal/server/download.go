@@ -0,0 +1,200 @@
+package server
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rmrfslashbin/manuals-webui/internal/client"
+)
+
+// downloadWriteTimeout is how long a single write to the browser may take.
+// The deadline is pushed forward on every write so a large download is not
+// cut off by the server-wide WriteTimeout while it is still making progress.
+const downloadWriteTimeout = 30 * time.Second
+
+// inlineMimeTypes are the content types that may be displayed in the
+// browser with ?inline=1. Anything that can run script (HTML, SVG) is
+// always served as an attachment.
+var inlineMimeTypes = map[string]bool{
+	"application/pdf": true,
+	"image/png":       true,
+	"image/jpeg":      true,
+	"image/gif":       true,
+	"image/webp":      true,
+	"text/plain":      true,
+	"text/markdown":   true,
+	"text/csv":        true,
+}
+
+// passthroughHeaders are copied from the upstream download response.
+var passthroughHeaders = []string{"Content-Length", "Content-Range", "Accept-Ranges", "ETag", "Last-Modified"}
+
+// Document proxy handler
+func (s *Server) handleDownload(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	apiClient := s.apiClient(r)
+
+	// Get document metadata
+	doc, err := apiClient.GetDocument(id)
+	if err != nil {
+		s.renderError(w, r, "Failed to get document", err)
+		return
+	}
+
+	inline := r.URL.Query().Get("inline") == "1"
+	setDownloadHeaders(w.Header(), doc, inline)
+
+	if s.docCache != nil {
+		if f, entry, ok := s.docCache.open(doc); ok {
+			defer f.Close()
+			s.serveCachedDocument(w, r, f, entry)
+			return
+		}
+	}
+
+	// HEAD is answered from metadata without fetching the content
+	if r.Method == http.MethodHead {
+		w.Header().Set("Content-Length", strconv.FormatInt(doc.SizeBytes, 10))
+		w.Header().Set("Accept-Ranges", "bytes")
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	resp, err := apiClient.DownloadDocument(r.Context(), id, r.Header)
+	if err != nil {
+		w.Header().Del("Content-Disposition")
+		s.renderError(w, r, "Failed to download document", err)
+		return
+	}
+	defer resp.Body.Close()
+
+	for _, name := range passthroughHeaders {
+		if v := resp.Header.Get(name); v != "" {
+			w.Header().Set(name, v)
+		}
+	}
+	w.WriteHeader(resp.StatusCode)
+
+	if resp.StatusCode == http.StatusNotModified {
+		return
+	}
+
+	var dst io.Writer = &deadlineWriter{w: w, rc: http.NewResponseController(w), timeout: downloadWriteTimeout}
+
+	// Complete responses are verified against the document's checksum
+	// and copied into the cache as they stream past
+	var fill *cacheFill
+	var verifier *checksumVerifier
+	if resp.StatusCode == http.StatusOK {
+		if s.docCache != nil {
+			fill, err = s.docCache.newFill(doc)
+			if err != nil && !errors.Is(err, errNotCacheable) {
+				s.logger.Warn("failed to start caching document", "id", id, "error", err)
+			}
+		}
+		if fill != nil {
+			verifier = fill.verifier
+			dst = io.MultiWriter(dst, fill)
+		} else {
+			verifier = newChecksumVerifier(doc)
+			dst = io.MultiWriter(dst, verifier)
+		}
+	}
+
+	if _, err := io.Copy(dst, resp.Body); err != nil {
+		if fill != nil {
+			fill.Abort()
+		}
+		if !errors.Is(err, r.Context().Err()) {
+			s.logger.Error("document download interrupted", "id", id, "error", err)
+		}
+		return
+	}
+
+	if verifier == nil {
+		return
+	}
+	if err := verifier.verify(); err != nil {
+		if fill != nil {
+			fill.Abort()
+		}
+		s.logger.Error("document failed verification", "id", id, "path", doc.Path, "error", err)
+		s.integrity.recordMismatch(*doc, err)
+		return
+	}
+	if fill != nil {
+		if err := fill.Commit(); err != nil {
+			s.logger.Warn("document not cached", "id", id, "error", err)
+		}
+	}
+}
+
+// serveCachedDocument serves a cache hit. http.ServeContent handles HEAD,
+// Range and conditional requests against the checksum-based ETag.
+func (s *Server) serveCachedDocument(w http.ResponseWriter, r *http.Request, f *os.File, entry *cacheEntry) {
+	w.Header().Set("ETag", `"`+entry.Digest+`"`)
+	w.Header().Set("Cache-Control", "private, no-cache")
+
+	dw := &deadlineResponseWriter{ResponseWriter: w, dw: deadlineWriter{w: w, rc: http.NewResponseController(w), timeout: downloadWriteTimeout}}
+	http.ServeContent(dw, r, "", time.Time{}, f)
+}
+
+// setDownloadHeaders sets the content type and disposition for a document.
+// Inline display is only honoured for allowlisted types.
+func setDownloadHeaders(h http.Header, doc *client.Document, inline bool) {
+	mediaType, _, err := mime.ParseMediaType(doc.MimeType)
+	if err != nil {
+		mediaType = "application/octet-stream"
+	}
+	mediaType = strings.ToLower(mediaType)
+
+	disposition := "attachment"
+	if inline && inlineMimeTypes[mediaType] {
+		disposition = "inline"
+	}
+
+	contentType := mediaType
+	if disposition == "attachment" {
+		contentType = doc.MimeType
+		if err != nil {
+			contentType = "application/octet-stream"
+		}
+	} else if strings.HasPrefix(mediaType, "text/") {
+		contentType = mediaType + "; charset=utf-8"
+	}
+
+	h.Set("Content-Type", contentType)
+	h.Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": doc.Filename}))
+	h.Set("X-Content-Type-Options", "nosniff")
+}
+
+// deadlineWriter extends the connection's write deadline before each write.
+type deadlineWriter struct {
+	w       io.Writer
+	rc      *http.ResponseController
+	timeout time.Duration
+}
+
+func (dw *deadlineWriter) Write(p []byte) (int, error) {
+	// Not every ResponseWriter supports deadlines (e.g. in tests); the
+	// server-wide timeout then applies as before.
+	_ = dw.rc.SetWriteDeadline(time.Now().Add(dw.timeout))
+	return dw.w.Write(p)
+}
+
+// deadlineResponseWriter applies deadlineWriter to a ResponseWriter handed
+// to code that writes the body itself, such as http.ServeContent.
+type deadlineResponseWriter struct {
+	http.ResponseWriter
+	dw deadlineWriter
+}
+
+func (w *deadlineResponseWriter) Write(p []byte) (int, error) {
+	return w.dw.Write(p)
+}
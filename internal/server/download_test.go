@@ -0,0 +1,219 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rmrfslashbin/manuals-webui/internal/client"
+)
+
+// downloadAPIServer serves metadata for one document and its content via
+// http.ServeContent, which implements Range and conditional requests.
+func downloadAPIServer(t *testing.T, doc client.Document, content string, downloads *int) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/documents/"+doc.ID+"/download"):
+			*downloads++
+			w.Header().Set("ETag", `"v1"`)
+			http.ServeContent(w, r, doc.Filename, time.Time{}, strings.NewReader(content))
+		case strings.HasSuffix(r.URL.Path, "/documents/"+doc.ID):
+			json.NewEncoder(w).Encode(doc)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(client.ErrorResponse{Error: "file missing"})
+		}
+	}))
+}
+
+func TestHandleDownloadRange(t *testing.T) {
+	var downloads int
+	doc := client.Document{ID: "doc-1", Filename: "manual.pdf", MimeType: "application/pdf", SizeBytes: 10}
+	apiServer := downloadAPIServer(t, doc, "0123456789", &downloads)
+	defer apiServer.Close()
+
+	s := testServer(t, apiServer)
+
+	tests := []struct {
+		name         string
+		header       map[string]string
+		expectedCode int
+		expectedBody string
+	}{
+		{"range", map[string]string{"Range": "bytes=2-5"}, http.StatusPartialContent, "2345"},
+		{"if-range match", map[string]string{"Range": "bytes=0-1", "If-Range": `"v1"`}, http.StatusPartialContent, "01"},
+		{"if-range stale", map[string]string{"Range": "bytes=0-1", "If-Range": `"v0"`}, http.StatusOK, "0123456789"},
+		{"not modified", map[string]string{"If-None-Match": `"v1"`}, http.StatusNotModified, ""},
+		{"unsatisfiable", map[string]string{"Range": "bytes=50-60"}, http.StatusRequestedRangeNotSatisfiable, ""},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/download/doc-1", nil)
+			req.SetPathValue("id", "doc-1")
+			for k, v := range tc.header {
+				req.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+
+			s.handleDownload(w, req)
+
+			if w.Code != tc.expectedCode {
+				t.Fatalf("expected status %d, got %d", tc.expectedCode, w.Code)
+			}
+			if tc.expectedBody != "" && w.Body.String() != tc.expectedBody {
+				t.Errorf("expected body %q, got %q", tc.expectedBody, w.Body.String())
+			}
+			if tc.expectedCode == http.StatusPartialContent && w.Header().Get("Content-Range") == "" {
+				t.Error("expected Content-Range to be passed through")
+			}
+		})
+	}
+}
+
+func TestHandleDownloadHead(t *testing.T) {
+	var downloads int
+	doc := client.Document{ID: "doc-1", Filename: "manual.pdf", MimeType: "application/pdf", SizeBytes: 10}
+	apiServer := downloadAPIServer(t, doc, "0123456789", &downloads)
+	defer apiServer.Close()
+
+	s := testServer(t, apiServer)
+
+	req := httptest.NewRequest("HEAD", "/download/doc-1", nil)
+	w := httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", w.Code)
+	}
+	if w.Header().Get("Content-Length") != "10" {
+		t.Errorf("expected Content-Length 10, got %q", w.Header().Get("Content-Length"))
+	}
+	if w.Header().Get("Accept-Ranges") != "bytes" {
+		t.Error("expected Accept-Ranges: bytes")
+	}
+	if downloads != 0 {
+		t.Errorf("expected HEAD not to fetch content, got %d downloads", downloads)
+	}
+}
+
+func TestHandleDownloadUpstreamError(t *testing.T) {
+	apiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/download") {
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(client.ErrorResponse{Error: "download not permitted"})
+			return
+		}
+		json.NewEncoder(w).Encode(client.Document{ID: "doc-1", Filename: "a.pdf", MimeType: "application/pdf", SizeBytes: 1 << 20})
+	}))
+	defer apiServer.Close()
+
+	s := testServer(t, apiServer)
+
+	req := httptest.NewRequest("GET", "/download/doc-1", nil)
+	req.SetPathValue("id", "doc-1")
+	w := httptest.NewRecorder()
+
+	s.handleDownload(w, req)
+
+	if w.Code != http.StatusForbidden {
+		t.Errorf("expected status 403, got %d", w.Code)
+	}
+	if w.Header().Get("Content-Disposition") != "" {
+		t.Error("expected error page not to be sent as an attachment")
+	}
+	if w.Header().Get("Content-Length") == "1048576" {
+		t.Error("expected Content-Length not to come from metadata")
+	}
+	if !strings.Contains(w.Body.String(), "download not permitted") {
+		t.Errorf("expected upstream error message, got %s", w.Body.String())
+	}
+}
+
+func TestSetDownloadHeaders(t *testing.T) {
+	tests := []struct {
+		name                string
+		mimeType            string
+		inline              bool
+		expectedType        string
+		expectedDisposition string
+	}{
+		{"pdf attachment", "application/pdf", false, "application/pdf", "attachment; filename=file"},
+		{"pdf inline", "application/pdf", true, "application/pdf", "inline; filename=file"},
+		{"text inline", "text/plain", true, "text/plain; charset=utf-8", "inline; filename=file"},
+		{"html never inline", "text/html", true, "text/html", "attachment; filename=file"},
+		{"svg never inline", "image/svg+xml", true, "image/svg+xml", "attachment; filename=file"},
+		{"invalid type", "not a type", true, "application/octet-stream", "attachment; filename=file"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			h := http.Header{}
+			setDownloadHeaders(h, &client.Document{Filename: "file", MimeType: tc.mimeType}, tc.inline)
+
+			if got := h.Get("Content-Type"); got != tc.expectedType {
+				t.Errorf("Content-Type = %q, want %q", got, tc.expectedType)
+			}
+			if got := h.Get("Content-Disposition"); got != tc.expectedDisposition {
+				t.Errorf("Content-Disposition = %q, want %q", got, tc.expectedDisposition)
+			}
+			if h.Get("X-Content-Type-Options") != "nosniff" {
+				t.Error("expected nosniff")
+			}
+		})
+	}
+
+	h := http.Header{}
+	setDownloadHeaders(h, &client.Document{Filename: "Datenblatt für µC.pdf", MimeType: "application/pdf"}, false)
+	if got := h.Get("Content-Disposition"); !strings.Contains(got, "filename*=utf-8''") {
+		t.Errorf("expected RFC 2231 encoded filename, got %q", got)
+	}
+}
+
+func TestHandleDownloadSurvivesWriteTimeout(t *testing.T) {
+	if testing.Short() {
+		t.Skip("slow test")
+	}
+
+	content := strings.Repeat("x", 64<<10)
+	apiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/download") {
+			// Trickle the content out for longer than the server WriteTimeout
+			flusher := w.(http.Flusher)
+			for i := 0; i < 4; i++ {
+				w.Write([]byte(content[i*(16<<10) : (i+1)*(16<<10)]))
+				flusher.Flush()
+				time.Sleep(150 * time.Millisecond)
+			}
+			return
+		}
+		json.NewEncoder(w).Encode(client.Document{ID: "doc-1", Filename: "big.bin", MimeType: "application/octet-stream"})
+	}))
+	defer apiServer.Close()
+
+	s := testServer(t, apiServer)
+
+	webServer := httptest.NewUnstartedServer(s.Handler())
+	webServer.Config.WriteTimeout = 300 * time.Millisecond
+	webServer.Start()
+	defer webServer.Close()
+
+	resp, err := http.Get(webServer.URL + "/download/doc-1")
+	if err != nil {
+		t.Fatalf("GET failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var sb strings.Builder
+	if _, err := io.Copy(&sb, resp.Body); err != nil {
+		t.Fatalf("download was cut off after %d bytes: %v", sb.Len(), err)
+	}
+	if sb.Len() != len(content) {
+		t.Errorf("expected %d bytes, got %d", len(content), sb.Len())
+	}
+}
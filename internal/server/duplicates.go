@@ -0,0 +1,213 @@
+package server
+
+import (
+	"context"
+	"encoding/csv"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/rmrfslashbin/manuals-webui/internal/client"
+)
+
+// duplicateSet is a group of documents with identical content.
+type duplicateSet struct {
+	Digest    string
+	SizeBytes int64
+	Documents []documentRow
+}
+
+// Wasted is the space taken by every copy beyond the first.
+func (d *duplicateSet) Wasted() int64 {
+	return d.SizeBytes * int64(len(d.Documents)-1)
+}
+
+// duplicateReport is the result of a duplicate scan.
+type duplicateReport struct {
+	Generated   time.Time
+	Scanned     int
+	NoChecksum  int
+	Sets        []*duplicateSet // most wasted space first
+	WastedBytes int64
+
+	byID map[string]*duplicateSet
+}
+
+// duplicatesData is passed to the duplicate admin templates.
+type duplicatesData struct {
+	Status jobStatus
+	Report *duplicateReport
+}
+
+// documentCopiesData is passed to the "also attached to" partial.
+type documentCopiesData struct {
+	DocumentID string
+	Scanned    bool // a report exists for the session's API
+	Pending    bool // no report yet, but a scan is running
+	Copies     []documentRow
+}
+
+// newDuplicateFinder groups every document by checksum to find files that
+// are indexed more than once, typically under several devices. Scans are
+// only started by an admin, so reports never expire.
+func newDuplicateFinder(s *Server) *catalogIndex[*duplicateReport] {
+	return newCatalogIndex(s, "duplicate scan", 0, s.scanDuplicates)
+}
+
+// duplicatesData returns the scan status and the report for c's API and
+// key, if one has been built.
+func (s *Server) duplicatesData(c *client.Client) duplicatesData {
+	return duplicatesData{Status: s.duplicates.job.snapshot(), Report: s.duplicates.indexFor(c)}
+}
+
+// scanDuplicates lists every document and groups those sharing a checksum.
+func (s *Server) scanDuplicates(ctx context.Context, c *client.Client, p *jobProgress) (*duplicateReport, error) {
+	devices, err := s.devices.get(ctx, c)
+	if err != nil {
+		s.logger.Warn("failed to load device names", "error", err)
+	}
+
+	report := &duplicateReport{byID: make(map[string]*duplicateSet)}
+	groups := make(map[string][]client.Document)
+	err = listAllDocuments(ctx, c, "", func(total int, page []client.Document) error {
+		p.setTotal(int64(total))
+		for _, doc := range page {
+			report.Scanned++
+			// Group by digest so "sha256:..." and a bare hex digest match
+			if _, digest, ok := parseChecksum(doc.Checksum); ok {
+				groups[digest] = append(groups[digest], doc)
+			} else {
+				report.NoChecksum++
+			}
+		}
+		p.add(int64(len(page)))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for digest, docs := range groups {
+		if len(docs) < 2 {
+			continue
+		}
+		set := &duplicateSet{Digest: digest, SizeBytes: docs[0].SizeBytes}
+		for _, doc := range docs {
+			row := documentRow{Document: doc}
+			if device := devices.lookup(doc.DeviceID); device != nil {
+				row.DeviceName = device.Name
+				row.DeviceDomain = device.Domain
+			}
+			set.Documents = append(set.Documents, row)
+			report.byID[doc.ID] = set
+		}
+		sort.Slice(set.Documents, func(i, j int) bool {
+			a, b := set.Documents[i], set.Documents[j]
+			if a.DeviceName != b.DeviceName {
+				return a.DeviceName < b.DeviceName
+			}
+			return a.Path < b.Path
+		})
+		report.Sets = append(report.Sets, set)
+		report.WastedBytes += set.Wasted()
+	}
+	sort.Slice(report.Sets, func(i, j int) bool {
+		if wi, wj := report.Sets[i].Wasted(), report.Sets[j].Wasted(); wi != wj {
+			return wi > wj
+		}
+		return report.Sets[i].Digest < report.Sets[j].Digest
+	})
+
+	report.Generated = time.Now()
+	return report, nil
+}
+
+// copiesOf returns the other documents with the same content as id.
+func (r *duplicateReport) copiesOf(id string) []documentRow {
+	set := r.byID[id]
+	if set == nil {
+		return nil
+	}
+	copies := make([]documentRow, 0, len(set.Documents)-1)
+	for _, doc := range set.Documents {
+		if doc.ID != id {
+			copies = append(copies, doc)
+		}
+	}
+	return copies
+}
+
+// Admin handlers
+
+func (s *Server) handleAdminDuplicates(w http.ResponseWriter, r *http.Request) {
+	s.render(w, "admin-duplicates.html", pageData{
+		Title:   "Duplicates",
+		Content: s.duplicatesData(s.apiClient(r)),
+	})
+}
+
+func (s *Server) handleAdminStartDuplicates(w http.ResponseWriter, r *http.Request) {
+	apiClient := s.apiClient(r)
+	if !s.duplicates.start(apiClient) {
+		s.renderErrorStatus(w, r, http.StatusConflict, "A duplicate scan is already running")
+		return
+	}
+	s.renderPartial(w, "partials/duplicates-report.html", s.duplicatesData(apiClient))
+}
+
+func (s *Server) handleAdminDuplicatesStatus(w http.ResponseWriter, r *http.Request) {
+	s.renderPartial(w, "partials/duplicates-report.html", s.duplicatesData(s.apiClient(r)))
+}
+
+// handleAdminDuplicatesCSV exports the latest report for the session's API
+// with one row per duplicated document.
+func (s *Server) handleAdminDuplicatesCSV(w http.ResponseWriter, r *http.Request) {
+	report := s.duplicates.indexFor(s.apiClient(r))
+	if report == nil {
+		s.renderErrorStatus(w, r, http.StatusNotFound, "No duplicate report yet; run a scan first")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": "duplicates.csv"}))
+
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"checksum", "size_bytes", "copies", "wasted_bytes", "document_id", "device_id", "device_name", "path"})
+	for _, set := range report.Sets {
+		for _, doc := range set.Documents {
+			cw.Write([]string{
+				set.Digest,
+				strconv.FormatInt(set.SizeBytes, 10),
+				strconv.Itoa(len(set.Documents)),
+				strconv.FormatInt(set.Wasted(), 10),
+				doc.ID,
+				doc.DeviceID,
+				doc.DeviceName,
+				doc.Path,
+			})
+		}
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		s.logger.Error("failed to write duplicates CSV", "error", err)
+	}
+}
+
+// handleDocumentCopies lists other documents with the same content. It is
+// loaded lazily by the document page and only reads the latest report;
+// scans are started from the admin page. While one is running the page
+// polls until it finishes.
+func (s *Server) handleDocumentCopies(w http.ResponseWriter, r *http.Request) {
+	data := documentCopiesData{DocumentID: r.PathValue("id")}
+
+	if report := s.duplicates.indexFor(s.apiClient(r)); report != nil {
+		data.Scanned = true
+		data.Copies = report.copiesOf(data.DocumentID)
+	} else {
+		data.Pending = s.duplicates.job.snapshot().Running
+	}
+
+	s.renderPartial(w, "partials/document-copies.html", data)
+}
@@ -0,0 +1,230 @@
+package server
+
+import (
+	"encoding/csv"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rmrfslashbin/manuals-webui/internal/client"
+)
+
+func duplicateCatalog() ([]client.Device, []client.Document) {
+	devices := []client.Device{
+		{ID: "esp32", Name: "ESP32 DevKit"},
+		{ID: "esp32-s3", Name: "ESP32-S3"},
+		{ID: "bme280", Name: "BME280"},
+	}
+
+	datasheet := testDocument("ds-1", "esp32 datasheet")
+	datasheet.DeviceID = "esp32"
+	datasheet.Path = "esp32/datasheet.pdf"
+	copy1 := *datasheet
+	copy1.ID, copy1.DeviceID, copy1.Path = "ds-2", "esp32-s3", "esp32-s3/datasheet.pdf"
+	// Same content recorded as a bare digest
+	copy2 := copy1
+	copy2.ID, copy2.Path = "ds-3", "esp32-s3/old/datasheet.pdf"
+	copy2.Checksum = strings.TrimPrefix(copy2.Checksum, "sha256:")
+
+	notes := testDocument("notes-1", "notes")
+	notes.DeviceID = "bme280"
+	notesCopy := *notes
+	notesCopy.ID, notesCopy.DeviceID = "notes-2", "esp32"
+
+	unique := testDocument("unique", "unique")
+	unique.DeviceID = "bme280"
+	unchecked := client.Document{ID: "plain", DeviceID: "bme280", SizeBytes: 5}
+
+	return devices, []client.Document{*datasheet, copy1, copy2, *notes, notesCopy, *unique, unchecked}
+}
+
+func TestDuplicateScan(t *testing.T) {
+	devices, docs := duplicateCatalog()
+	api := catalogAPIServer(t, devices, docs)
+	defer api.Close()
+	s := testServer(t, api.Server)
+
+	c := client.New(api.URL, "test-key")
+	if !s.duplicates.start(c) {
+		t.Fatal("expected scan to start")
+	}
+	if st := waitForJob(t, s.duplicates.job); st.Error != "" {
+		t.Fatalf("scan failed: %s", st.Error)
+	}
+
+	report := s.duplicates.indexFor(c)
+	if report == nil {
+		t.Fatal("expected a report for the scanned API")
+	}
+	if s.duplicates.indexFor(client.New("http://other.example", "test-key")) != nil {
+		t.Error("expected no report for another API")
+	}
+	if s.duplicates.indexFor(client.New(api.URL, "other-key")) != nil {
+		t.Error("expected no report for another API key")
+	}
+	if report.Scanned != 7 || report.NoChecksum != 1 {
+		t.Errorf("expected 7 scanned and 1 without checksum, got %d and %d", report.Scanned, report.NoChecksum)
+	}
+	if len(report.Sets) != 2 {
+		t.Fatalf("expected 2 duplicate sets, got %d", len(report.Sets))
+	}
+
+	// The three-copy datasheet wastes the most space
+	first := report.Sets[0]
+	if len(first.Documents) != 3 || first.Wasted() != 2*first.SizeBytes {
+		t.Errorf("unexpected first set: %d copies, %d wasted", len(first.Documents), first.Wasted())
+	}
+	if first.Documents[0].DeviceName != "ESP32 DevKit" {
+		t.Errorf("expected device names resolved and sorted, got %q", first.Documents[0].DeviceName)
+	}
+	if want := first.Wasted() + report.Sets[1].Wasted(); report.WastedBytes != want {
+		t.Errorf("expected %d wasted bytes, got %d", want, report.WastedBytes)
+	}
+
+	copies := report.copiesOf("ds-2")
+	if len(copies) != 2 || copies[0].ID != "ds-1" || copies[1].ID != "ds-3" {
+		t.Errorf("unexpected copies of ds-2: %+v", copies)
+	}
+	if report.copiesOf("unique") != nil {
+		t.Error("expected no copies of a unique document")
+	}
+}
+
+func TestHandleAdminDuplicatesCSV(t *testing.T) {
+	devices, docs := duplicateCatalog()
+	api := catalogAPIServer(t, devices, docs)
+	defer api.Close()
+	s := testServer(t, api.Server)
+
+	get := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		s.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/admin/duplicates.csv", nil))
+		return w
+	}
+
+	if w := get(); w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 before a scan, got %d", w.Code)
+	}
+
+	s.duplicates.start(client.New(api.URL, "test-key"))
+	waitForJob(t, s.duplicates.job)
+
+	w := get()
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/csv") {
+		t.Errorf("expected CSV, got %q", ct)
+	}
+	records, err := csv.NewReader(w.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("invalid CSV: %v", err)
+	}
+	if len(records) != 6 {
+		t.Fatalf("expected header and 5 rows, got %d records", len(records))
+	}
+	if records[0][0] != "checksum" || records[1][2] != "3" || records[1][6] != "ESP32 DevKit" {
+		t.Errorf("unexpected CSV content: %v", records[:2])
+	}
+}
+
+func TestHandleDocumentCopies(t *testing.T) {
+	devices, docs := duplicateCatalog()
+	api := catalogAPIServer(t, devices, docs)
+	defer api.Close()
+	s := testServer(t, api.Server)
+
+	get := func(id string) string {
+		w := httptest.NewRecorder()
+		s.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/partials/documents/"+id+"/copies", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", w.Code)
+		}
+		return w.Body.String()
+	}
+
+	// Visitors never start the catalog-wide scan themselves
+	if body := get("notes-1"); !strings.Contains(body, "Not scanned yet") || strings.Contains(body, "every 2s") {
+		t.Error("expected a not-scanned note without polling")
+	}
+	if st := s.duplicates.job.snapshot(); st.Running || !st.Started.IsZero() {
+		t.Fatal("expected the partial not to start a scan")
+	}
+
+	s.duplicates.start(client.New(api.URL, "test-key"))
+	waitForJob(t, s.duplicates.job)
+
+	body := get("notes-1")
+	if strings.Contains(body, "every 2s") {
+		t.Error("expected polling to stop once the report is ready")
+	}
+	if !strings.Contains(body, `href="/devices/esp32"`) || !strings.Contains(body, "ESP32 DevKit") {
+		t.Error("expected a link to the other device")
+	}
+	if body := get("unique"); !strings.Contains(body, "Not attached to any other device") {
+		t.Error("expected no copies for a unique document")
+	}
+}
+
+func TestHandleDocumentCopiesScanFailure(t *testing.T) {
+	apiServer := mockAPIServer(t, map[string]http.HandlerFunc{})
+	defer apiServer.Close()
+	s := testServer(t, apiServer)
+
+	s.duplicates.start(s.client)
+	waitForJob(t, s.duplicates.job)
+	started := s.duplicates.job.snapshot().Started
+
+	time.Sleep(time.Millisecond)
+	w := httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/partials/documents/doc-1/copies", nil))
+
+	if !strings.Contains(w.Body.String(), "Not scanned yet") {
+		t.Error("expected no copies without a report")
+	}
+	if s.duplicates.job.snapshot().Started != started {
+		t.Error("expected a failed scan not to be restarted by polling")
+	}
+}
+
+func TestHandleAdminDuplicates(t *testing.T) {
+	devices, docs := duplicateCatalog()
+	api := catalogAPIServer(t, devices, docs)
+	defer api.Close()
+	s := testServer(t, api.Server)
+
+	// A report built with another key is not shown to this session
+	s.duplicates.start(client.New(api.URL, "other-key"))
+	waitForJob(t, s.duplicates.job)
+	w := httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/admin/duplicates", nil))
+	if body := w.Body.String(); !strings.Contains(body, "No scan has been run yet") || strings.Contains(body, "3 copies of") {
+		t.Error("expected no report for the session's API key")
+	}
+	w = httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/admin/duplicates.csv", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected no CSV for the session's API key, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, httptest.NewRequest("POST", "/admin/duplicates", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	waitForJob(t, s.duplicates.job)
+
+	w = httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/admin/duplicates", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	body := w.Body.String()
+	for _, want := range []string{"3 copies of", "esp32-s3/old/datasheet.pdf", `href="/admin/duplicates.csv"`} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected page to contain %q", want)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package server
+
+import (
+	"errors"
+	"net"
+	"net/http"
+
+	"github.com/rmrfslashbin/manuals-webui/internal/client"
+)
+
+// toastTarget is the element in base.html that htmx error fragments are
+// appended to.
+const toastTarget = "#toast-area"
+
+// errorData is passed to error.html and partials/error-toast.html.
+type errorData struct {
+	Status     int
+	StatusText string
+	Message    string
+}
+
+// apiErrorStatus maps an error from the API client to the status the
+// browser should see. Client errors the user can act on are passed
+// through; anything else is reported as a gateway problem.
+func apiErrorStatus(err error) int {
+	var apiErr *client.APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.StatusCode {
+		case http.StatusNotFound, http.StatusUnauthorized, http.StatusForbidden:
+			return apiErr.StatusCode
+		case http.StatusServiceUnavailable:
+			return http.StatusServiceUnavailable
+		default:
+			return http.StatusBadGateway
+		}
+	}
+
+	// Connection refused, DNS failures and timeouts mean the API is unreachable
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return http.StatusServiceUnavailable
+	}
+
+	return http.StatusBadGateway
+}
+
+// isNotFound reports whether err is a 404 from the API.
+func isNotFound(err error) bool {
+	var apiErr *client.APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
+
+// isHTMXRequest reports whether the request was issued by htmx.
+func isHTMXRequest(r *http.Request) bool {
+	return r.Header.Get("HX-Request") == "true"
+}
+
+// renderErrorStatus writes an error with the given status. htmx requests
+// get a small toast fragment retargeted at the toast area so the element
+// that issued the request is left intact; other requests get the full
+// error page.
+func (s *Server) renderErrorStatus(w http.ResponseWriter, r *http.Request, status int, message string) {
+	data := errorData{
+		Status:     status,
+		StatusText: http.StatusText(status),
+		Message:    message,
+	}
+
+	if isHTMXRequest(r) {
+		w.Header().Set("HX-Retarget", toastTarget)
+		w.Header().Set("HX-Reswap", "beforeend")
+		s.renderPartialStatus(w, status, "partials/error-toast.html", data)
+		return
+	}
+
+	s.renderStatus(w, status, "error.html", pageData{
+		Title:   data.StatusText,
+		Content: data,
+	})
+}
@@ -0,0 +1,134 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/rmrfslashbin/manuals-webui/internal/client"
+)
+
+func TestAPIErrorStatus(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected int
+	}{
+		{"not found", &client.APIError{StatusCode: http.StatusNotFound}, http.StatusNotFound},
+		{"unauthorized", &client.APIError{StatusCode: http.StatusUnauthorized}, http.StatusUnauthorized},
+		{"forbidden", fmt.Errorf("wrapped: %w", &client.APIError{StatusCode: http.StatusForbidden}), http.StatusForbidden},
+		{"unavailable", &client.APIError{StatusCode: http.StatusServiceUnavailable}, http.StatusServiceUnavailable},
+		{"upstream 500", &client.APIError{StatusCode: http.StatusInternalServerError}, http.StatusBadGateway},
+		{"upstream 400", &client.APIError{StatusCode: http.StatusBadRequest}, http.StatusBadGateway},
+		{"other", errors.New("failed to decode response"), http.StatusBadGateway},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := apiErrorStatus(tc.err); got != tc.expected {
+				t.Errorf("apiErrorStatus() = %d, want %d", got, tc.expected)
+			}
+		})
+	}
+}
+
+func TestAPIErrorStatusUnreachable(t *testing.T) {
+	apiServer := httptest.NewServer(http.NotFoundHandler())
+	apiServer.Close()
+
+	_, err := client.New(apiServer.URL, "").GetStatus()
+	if got := apiErrorStatus(err); got != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 for unreachable API, got %d", got)
+	}
+}
+
+func TestRenderErrorPage(t *testing.T) {
+	apiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(client.ErrorResponse{Error: "device not found"})
+	}))
+	defer apiServer.Close()
+
+	s := testServer(t, apiServer)
+
+	req := httptest.NewRequest("GET", "/devices/missing", nil)
+	req.SetPathValue("id", "missing")
+	w := httptest.NewRecorder()
+
+	s.handleDevice(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404, got %d", w.Code)
+	}
+	body := w.Body.String()
+	if !strings.Contains(body, "<html") || !strings.Contains(body, "device not found") {
+		t.Errorf("expected full error page with message, got %s", body)
+	}
+	if w.Header().Get("HX-Retarget") != "" {
+		t.Error("expected no HX-Retarget on a full page")
+	}
+}
+
+func TestRenderErrorHTMXToast(t *testing.T) {
+	apiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(client.ErrorResponse{Error: "invalid API key"})
+	}))
+	defer apiServer.Close()
+
+	s := testServer(t, apiServer)
+
+	req := httptest.NewRequest("GET", "/partials/devices", nil)
+	req.Header.Set("HX-Request", "true")
+	w := httptest.NewRecorder()
+
+	s.handleDevicesPartial(w, req)
+
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expected status 401, got %d", w.Code)
+	}
+	if got := w.Header().Get("HX-Retarget"); got != toastTarget {
+		t.Errorf("expected HX-Retarget %q, got %q", toastTarget, got)
+	}
+	body := w.Body.String()
+	if strings.Contains(body, "<html") {
+		t.Error("expected a fragment, not a full page")
+	}
+	if !strings.Contains(body, "invalid API key") || !strings.Contains(body, `role="alert"`) {
+		t.Errorf("expected toast with message, got %s", body)
+	}
+}
+
+func TestRenderBuffersTemplateFailure(t *testing.T) {
+	apiServer := mockAPIServer(t, nil)
+	defer apiServer.Close()
+
+	s := testServer(t, apiServer)
+
+	// The page writes output before failing on a missing method
+	fsys := fstest.MapFS{
+		"base.html":           {Data: []byte(`{{define "base"}}<html>{{template "content" .}}</html>{{end}}`)},
+		"broken.html":         {Data: []byte(`{{template "base" .}}{{define "content"}}<h1>partial output</h1>{{.Content.Missing}}{{end}}`)},
+		"partials/empty.html": {Data: []byte(``)},
+	}
+	tr, err := newTemplateRegistry(fsys, nil, false)
+	if err != nil {
+		t.Fatalf("newTemplateRegistry failed: %v", err)
+	}
+	s.templates = tr
+
+	w := httptest.NewRecorder()
+	s.render(w, "broken.html", pageData{Content: 42})
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("expected status 500, got %d", w.Code)
+	}
+	if strings.Contains(w.Body.String(), "partial output") {
+		t.Errorf("expected no partially rendered page, got %s", w.Body.String())
+	}
+}
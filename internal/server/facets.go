@@ -0,0 +1,156 @@
+package server
+
+import (
+	"context"
+	"html/template"
+	"net/url"
+	"sort"
+	"sync"
+
+	"github.com/rmrfslashbin/manuals-webui/internal/client"
+)
+
+// facetValue is one clickable facet with the number of devices it would
+// show combined with the other selected facets.
+type facetValue struct {
+	Value    string
+	Count    int
+	Selected bool
+	URL      template.URL // toggles this value, keeping the other facets
+}
+
+// deviceFacets are the domain and type facets for a selection.
+type deviceFacets struct {
+	Domains []facetValue
+	Types   []facetValue
+	Total   int // devices in the whole catalog
+}
+
+// facetCounts is the number of devices per domain and type.
+type facetCounts map[string]map[string]int
+
+// facetService aggregates device counts by domain and type across the
+// whole catalog. Counts are derived from the cached device directory and
+// rebuilt in the background when the catalog changes.
+type facetService struct {
+	dir *deviceDirectory
+	job *job
+
+	mu     sync.Mutex
+	counts map[*deviceIndex]facetCounts
+}
+
+func newFacetService(s *Server, dir *deviceDirectory) *facetService {
+	return &facetService{
+		dir:    dir,
+		job:    newJob(s, "facet refresh"),
+		counts: make(map[*deviceIndex]facetCounts),
+	}
+}
+
+// facets returns the facets for the given selection. Domain counts are
+// narrowed by the selected type and type counts by the selected domain, so
+// every count matches what clicking the facet shows.
+func (fs *facetService) facets(ctx context.Context, c *client.Client, domain, deviceType string) (*deviceFacets, error) {
+	idx, err := fs.dir.get(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	counts := fs.countsFor(idx)
+
+	domains := make(map[string]int)
+	types := make(map[string]int)
+	for d, byType := range counts {
+		for t, n := range byType {
+			if d != "" && (deviceType == "" || t == deviceType) {
+				domains[d] += n
+			}
+			if t != "" && (domain == "" || d == domain) {
+				types[t] += n
+			}
+		}
+	}
+
+	link := func(domain, deviceType string) template.URL {
+		v := url.Values{}
+		if domain != "" {
+			v.Set("domain", domain)
+		}
+		if deviceType != "" {
+			v.Set("type", deviceType)
+		}
+		if len(v) == 0 {
+			return "/devices"
+		}
+		return template.URL("/devices?" + v.Encode())
+	}
+
+	f := &deviceFacets{Total: len(idx.Devices)}
+	f.Domains = facetValues(domains, domain, func(v string) template.URL { return link(v, deviceType) }, func() template.URL { return link("", deviceType) })
+	f.Types = facetValues(types, deviceType, func(v string) template.URL { return link(domain, v) }, func() template.URL { return link(domain, "") })
+	return f, nil
+}
+
+// facetValues sorts counts into facets, keeping the selected value even
+// when nothing matches it so it can still be cleared.
+func facetValues(counts map[string]int, selected string, selectURL func(string) template.URL, clearURL func() template.URL) []facetValue {
+	if selected != "" {
+		if _, ok := counts[selected]; !ok {
+			counts[selected] = 0
+		}
+	}
+	values := make([]facetValue, 0, len(counts))
+	for v, n := range counts {
+		f := facetValue{Value: v, Count: n, Selected: v == selected, URL: selectURL(v)}
+		if f.Selected {
+			f.URL = clearURL()
+		}
+		values = append(values, f)
+	}
+	sort.Slice(values, func(i, j int) bool {
+		if values[i].Count != values[j].Count {
+			return values[i].Count > values[j].Count
+		}
+		return values[i].Value < values[j].Value
+	})
+	return values
+}
+
+// countsFor aggregates a device listing once and remembers the result for
+// as long as the directory keeps serving that listing.
+func (fs *facetService) countsFor(idx *deviceIndex) facetCounts {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if counts, ok := fs.counts[idx]; ok {
+		return counts
+	}
+
+	counts := make(facetCounts)
+	for _, d := range idx.Devices {
+		domain, deviceType := d.Domain, d.Type
+		if counts[domain] == nil {
+			counts[domain] = make(map[string]int)
+		}
+		counts[domain][deviceType]++
+	}
+	// Listings replaced by a refresh are no longer served
+	clear(fs.counts)
+	fs.counts[idx] = counts
+	return counts
+}
+
+// refresh drops the cached listing and rebuilds it in the background so
+// the next page view doesn't wait for it. It returns false if a refresh is
+// already running.
+func (fs *facetService) refresh(c *client.Client) bool {
+	fs.dir.invalidate()
+	return fs.job.start(func(ctx context.Context, p *jobProgress) error {
+		idx, err := fs.dir.get(ctx, c)
+		if err != nil {
+			return err
+		}
+		fs.countsFor(idx)
+		return nil
+	})
+}
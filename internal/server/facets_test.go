@@ -0,0 +1,181 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rmrfslashbin/manuals-webui/internal/client"
+)
+
+func facetCatalog() []client.Device {
+	return []client.Device{
+		{ID: "bme280", Name: "BME280", Domain: "hardware", Type: "sensors"},
+		{ID: "dht22", Name: "DHT22", Domain: "hardware", Type: "sensors"},
+		{ID: "esp32", Name: "ESP32", Domain: "hardware", Type: "mcu"},
+		{ID: "mqtt", Name: "MQTT", Domain: "protocol", Type: "messaging"},
+		{ID: "ha", Name: "Home Assistant", Domain: "software", Type: "automation"},
+		{ID: "zigbee", Name: "Zigbee", Domain: "protocol", Type: ""},
+	}
+}
+
+func facetMap(values []facetValue) map[string]facetValue {
+	m := make(map[string]facetValue, len(values))
+	for _, v := range values {
+		m[v.Value] = v
+	}
+	return m
+}
+
+func TestFacets(t *testing.T) {
+	api := catalogAPIServer(t, facetCatalog(), nil)
+	defer api.Close()
+	s := testServer(t, api.Server)
+	c := client.New(api.URL, "test-key")
+
+	tests := []struct {
+		name       string
+		domain     string
+		deviceType string
+		domains    map[string]int
+		types      map[string]int
+	}{
+		{
+			name:    "no selection",
+			domains: map[string]int{"hardware": 3, "protocol": 2, "software": 1},
+			types:   map[string]int{"sensors": 2, "mcu": 1, "messaging": 1, "automation": 1},
+		},
+		{
+			name:    "domain narrows types",
+			domain:  "hardware",
+			domains: map[string]int{"hardware": 3, "protocol": 2, "software": 1},
+			types:   map[string]int{"sensors": 2, "mcu": 1},
+		},
+		{
+			name:       "type narrows domains",
+			deviceType: "sensors",
+			domains:    map[string]int{"hardware": 2},
+			types:      map[string]int{"sensors": 2, "mcu": 1, "messaging": 1, "automation": 1},
+		},
+		{
+			name:       "empty combination keeps selection",
+			domain:     "software",
+			deviceType: "sensors",
+			domains:    map[string]int{"hardware": 2, "software": 0},
+			types:      map[string]int{"automation": 1, "sensors": 0},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			f, err := s.facets.facets(context.Background(), c, tc.domain, tc.deviceType)
+			if err != nil {
+				t.Fatalf("facets failed: %v", err)
+			}
+			if f.Total != 6 {
+				t.Errorf("expected 6 devices in total, got %d", f.Total)
+			}
+			for name, got := range map[string]map[string]facetValue{"domain": facetMap(f.Domains), "type": facetMap(f.Types)} {
+				want := tc.domains
+				if name == "type" {
+					want = tc.types
+				}
+				if len(got) != len(want) {
+					t.Errorf("%s facets = %v, want %v", name, got, want)
+				}
+				for value, n := range want {
+					if got[value].Count != n {
+						t.Errorf("%s %q count = %d, want %d", name, value, got[value].Count, n)
+					}
+				}
+			}
+		})
+	}
+
+	if n := api.count("devices"); n != 1 {
+		t.Errorf("expected one cached listing, got %d requests", n)
+	}
+}
+
+func TestFacetURLs(t *testing.T) {
+	api := catalogAPIServer(t, facetCatalog(), nil)
+	defer api.Close()
+	s := testServer(t, api.Server)
+
+	f, err := s.facets.facets(context.Background(), client.New(api.URL, "test-key"), "hardware", "")
+	if err != nil {
+		t.Fatalf("facets failed: %v", err)
+	}
+	domains, types := facetMap(f.Domains), facetMap(f.Types)
+
+	if d := domains["hardware"]; !d.Selected || d.URL != "/devices" {
+		t.Errorf("expected the selected domain to clear itself, got %+v", d)
+	}
+	if d := domains["protocol"]; d.URL != "/devices?domain=protocol" {
+		t.Errorf("expected protocol to replace the domain, got %q", d.URL)
+	}
+	if ty := types["sensors"]; ty.URL != "/devices?domain=hardware&type=sensors" {
+		t.Errorf("expected sensors to combine with the domain, got %q", ty.URL)
+	}
+	if f.Domains[0].Value != "hardware" {
+		t.Errorf("expected facets ordered by count, got %s first", f.Domains[0].Value)
+	}
+}
+
+func TestFacetRefresh(t *testing.T) {
+	api := catalogAPIServer(t, facetCatalog(), nil)
+	defer api.Close()
+	s := testServer(t, api.Server)
+	c := client.New(api.URL, "test-key")
+
+	if _, err := s.facets.facets(context.Background(), c, "", ""); err != nil {
+		t.Fatalf("facets failed: %v", err)
+	}
+	if !s.facets.refresh(c) {
+		t.Fatal("expected refresh to start")
+	}
+	if st := waitForJob(t, s.facets.job); st.Error != "" {
+		t.Fatalf("refresh failed: %s", st.Error)
+	}
+	if n := api.count("devices"); n != 2 {
+		t.Errorf("expected the refresh to list devices again, got %d requests", n)
+	}
+
+	// The page view after a refresh uses the rebuilt listing
+	if _, err := s.facets.facets(context.Background(), c, "", ""); err != nil {
+		t.Fatalf("facets failed: %v", err)
+	}
+	if n := api.count("devices"); n != 2 {
+		t.Errorf("expected the refreshed listing to be reused, got %d requests", n)
+	}
+}
+
+func TestHandleDevicesFacets(t *testing.T) {
+	api := catalogAPIServer(t, facetCatalog(), nil)
+	defer api.Close()
+	s := testServer(t, api.Server)
+
+	req := httptest.NewRequest("GET", "/devices?domain=hardware", nil)
+	w := httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	body := w.Body.String()
+	for _, want := range []string{
+		`href="/devices?domain=hardware&amp;type=sensors"`,
+		`href="/devices?domain=protocol"`,
+		`aria-current="true"`,
+		"Clear filters",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected page to contain %q", want)
+		}
+	}
+	if strings.Contains(body, `name="type" value=`) {
+		t.Error("expected the free-form filter to be replaced by facets")
+	}
+}
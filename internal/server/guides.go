@@ -0,0 +1,78 @@
+package server
+
+import (
+	"html/template"
+	"net/http"
+	"strconv"
+
+	"github.com/rmrfslashbin/manuals-webui/internal/client"
+)
+
+// guidesPageSize is how many guides are listed per page.
+const guidesPageSize = 50
+
+// tocDocument is markdown rendered with its outline.
+type tocDocument struct {
+	HTML    template.HTML
+	Outline []*Heading
+}
+
+// renderTOC renders markdown for the device and guide pages.
+func (s *Server) renderTOC(content string) tocDocument {
+	html, outline := s.mdRenderer.RenderWithTOC(content)
+	return tocDocument{HTML: html, Outline: outline}
+}
+
+type guidesData struct {
+	Guides   []client.Guide
+	Page     int
+	Total    int
+	PrevPage int // 0 if on the first page
+	NextPage int // 0 if on the last page
+}
+
+type guideData struct {
+	Guide *client.Guide
+	Doc   tocDocument
+}
+
+func (s *Server) handleGuides(w http.ResponseWriter, r *http.Request) {
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page < 1 {
+		page = 1
+	}
+
+	guides, err := s.apiClient(r).ListGuides(guidesPageSize, (page-1)*guidesPageSize)
+	if err != nil {
+		s.renderError(w, r, "Failed to list guides", err)
+		return
+	}
+
+	data := guidesData{Guides: guides.Data, Page: page, Total: guides.Total}
+	if page > 1 {
+		data.PrevPage = page - 1
+	}
+	if page*guidesPageSize < guides.Total {
+		data.NextPage = page + 1
+	}
+	s.render(w, "guides.html", pageData{
+		Title:   "Guides",
+		Content: data,
+	})
+}
+
+func (s *Server) handleGuide(w http.ResponseWriter, r *http.Request) {
+	guide, err := s.apiClient(r).GetGuide(r.PathValue("id"))
+	if err != nil {
+		s.renderError(w, r, "Failed to get guide", err)
+		return
+	}
+
+	s.render(w, "guide.html", pageData{
+		Title: guide.Title,
+		Content: guideData{
+			Guide: guide,
+			Doc:   s.renderTOC(guide.Content),
+		},
+	})
+}
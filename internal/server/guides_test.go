@@ -0,0 +1,113 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rmrfslashbin/manuals-webui/internal/client"
+)
+
+const guideContent = "# Flashing\n\n## Bootloader\n\nHold BOOT.\n\n### Windows\n\n## Troubleshooting\n"
+
+func guidesAPIServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	prefix := "/api/" + client.APIVersion
+	return mockAPIServer(t, map[string]http.HandlerFunc{
+		prefix + "/guides": func(w http.ResponseWriter, r *http.Request) {
+			switch strings.TrimPrefix(r.URL.Path, prefix) {
+			case "/guides":
+				var guides []client.Guide
+				for i := 0; i < 3; i++ {
+					guides = append(guides, client.Guide{ID: fmt.Sprintf("g%d", i), Title: fmt.Sprintf("Guide %d", i)})
+				}
+				json.NewEncoder(w).Encode(client.GuidesResponse{Data: guides, Total: 120})
+			case "/guides/flashing":
+				json.NewEncoder(w).Encode(client.Guide{ID: "flashing", Title: "Flashing", Path: "guides/flashing.md", Content: guideContent})
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		},
+		prefix + "/devices/esp32": func(w http.ResponseWriter, r *http.Request) {
+			if !strings.HasSuffix(r.URL.Path, "/esp32") {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			json.NewEncoder(w).Encode(client.Device{ID: "esp32", Name: "ESP32", Content: guideContent})
+		},
+	})
+}
+
+func TestHandleGuides(t *testing.T) {
+	api := guidesAPIServer(t)
+	defer api.Close()
+	s := testServer(t, api)
+
+	tests := []struct {
+		path    string
+		want    []string
+		notWant []string
+	}{
+		{"/guides", []string{`href="/guides/g0"`, `href="/guides?page=2"`}, []string{"Previous"}},
+		{"/guides?page=2", []string{`href="/guides?page=1"`, `href="/guides?page=3"`}, nil},
+		{"/guides?page=3", []string{`href="/guides?page=2"`, "Page 3, 120 guides"}, []string{"Next"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			s.Handler().ServeHTTP(w, httptest.NewRequest("GET", tt.path, nil))
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d", w.Code)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(w.Body.String(), want) {
+					t.Errorf("expected page to contain %q", want)
+				}
+			}
+			for _, notWant := range tt.notWant {
+				if strings.Contains(w.Body.String(), notWant) {
+					t.Errorf("expected page not to contain %q", notWant)
+				}
+			}
+		})
+	}
+}
+
+func TestTOCPages(t *testing.T) {
+	api := guidesAPIServer(t)
+	defer api.Close()
+	s := testServer(t, api)
+
+	for _, path := range []string{"/guides/flashing", "/devices/esp32"} {
+		t.Run(path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			s.Handler().ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d", w.Code)
+			}
+			body := w.Body.String()
+			for _, want := range []string{
+				`aria-label="Outline"`,
+				`<a href="#bootloader" class="block truncate`,
+				`<a href="#windows" class="block truncate`,
+				`<h2 id="bootloader">Bootloader <a href="#bootloader" title="Copy link to section" class="heading-anchor"`,
+			} {
+				if !strings.Contains(body, want) {
+					t.Errorf("expected page to contain %q", want)
+				}
+			}
+			if strings.Contains(body, "max-h-96") {
+				t.Error("expected the content not to be in a fixed-height scroll box")
+			}
+		})
+	}
+
+	w := httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/guides/missing", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 for a missing guide, got %d", w.Code)
+	}
+}
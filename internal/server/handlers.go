@@ -1,9 +1,7 @@
 package server
 
 import (
-	"fmt"
-	"html/template"
-	"io"
+	"bytes"
 	"net/http"
 	"strconv"
 
@@ -27,6 +25,7 @@ type devicesData struct {
 	Type    string
 	Page    int
 	Total   int
+	Facets  *deviceFacets
 }
 
 type deviceData struct {
@@ -34,6 +33,9 @@ type deviceData struct {
 	Pinout    interface{}
 	Specs     interface{}
 	Documents interface{}
+	Exports   []*pinoutExporter
+	Metadata  *metadataView
+	Doc       tocDocument
 }
 
 // UnifiedSearchResult is a common format for both keyword and semantic search results
@@ -53,10 +55,10 @@ type searchData struct {
 	SemanticError  string // Set if semantic search fails (e.g., not enabled)
 }
 
-type documentsData struct {
-	Documents interface{}
-	Page      int
-	Total     int
+type documentData struct {
+	Document *client.Document
+	Device   *client.Device
+	Preview  documentPreview
 }
 
 type adminData struct {
@@ -77,13 +79,13 @@ type settingsData struct {
 // Configuration pages
 func (s *Server) handleSetup(w http.ResponseWriter, r *http.Request) {
 	s.render(w, "setup.html", pageData{
-		Title: "Setup - Manuals",
+		Title: "Setup",
 	})
 }
 
 func (s *Server) handleSettings(w http.ResponseWriter, r *http.Request) {
 	s.render(w, "settings.html", pageData{
-		Title: "Settings - Manuals",
+		Title: "Settings",
 	})
 }
 
@@ -93,9 +95,9 @@ func (s *Server) handleHome(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	status, err := s.client.GetStatus()
+	status, err := s.apiClient(r).GetStatus()
 	if err != nil {
-		s.renderError(w, "Failed to get status", err)
+		s.renderError(w, r, "Failed to get status", err)
 		return
 	}
 
@@ -116,12 +118,18 @@ func (s *Server) handleDevices(w http.ResponseWriter, r *http.Request) {
 	limit := 20
 	offset := (page - 1) * limit
 
-	devices, err := s.client.ListDevices(limit, offset, domain, deviceType)
+	devices, err := s.apiClient(r).ListDevices(limit, offset, domain, deviceType)
 	if err != nil {
-		s.renderError(w, "Failed to list devices", err)
+		s.renderError(w, r, "Failed to list devices", err)
 		return
 	}
 
+	// The list still works without facets
+	facets, err := s.facets.facets(r.Context(), s.apiClient(r), domain, deviceType)
+	if err != nil {
+		s.logger.Warn("failed to load device facets", "error", err)
+	}
+
 	s.render(w, "devices.html", pageData{
 		Title: "Devices",
 		Content: devicesData{
@@ -130,6 +138,7 @@ func (s *Server) handleDevices(w http.ResponseWriter, r *http.Request) {
 			Type:    deviceType,
 			Page:    page,
 			Total:   devices.Total,
+			Facets:  facets,
 		},
 	})
 }
@@ -137,15 +146,15 @@ func (s *Server) handleDevices(w http.ResponseWriter, r *http.Request) {
 func (s *Server) handleDevice(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
 
-	device, err := s.client.GetDevice(id, true)
+	device, err := s.apiClient(r).GetDevice(id, true)
 	if err != nil {
-		s.renderError(w, "Failed to get device", err)
+		s.renderError(w, r, "Failed to get device", err)
 		return
 	}
 
-	pinout, _ := s.client.GetDevicePinout(id)
-	specs, _ := s.client.GetDeviceSpecs(id)
-	docs, _ := s.client.ListDocuments(50, 0, id)
+	pinout, _ := s.apiClient(r).GetDevicePinout(id)
+	specs, _ := s.apiClient(r).GetDeviceSpecs(id)
+	docs, _ := s.apiClient(r).ListDocuments(50, 0, id)
 
 	var documents interface{}
 	if docs != nil {
@@ -159,6 +168,9 @@ func (s *Server) handleDevice(w http.ResponseWriter, r *http.Request) {
 			Pinout:    pinout,
 			Specs:     specs,
 			Documents: documents,
+			Exports:   pinoutExporters,
+			Metadata:  newMetadataView(device.Metadata, s.metadata.promoted()),
+			Doc:       s.renderTOC(device.Content),
 		},
 	})
 }
@@ -175,13 +187,13 @@ func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
 
 	if query != "" {
 		if mode == "semantic" {
-			resp, err := s.client.SemanticSearch(query, 50, "", "")
+			resp, err := s.apiClient(r).SemanticSearch(query, 50, "", "")
 			if err != nil {
 				// If semantic search fails, fall back to keyword search with a notice
 				semanticError = err.Error()
-				keywordResp, keywordErr := s.client.Search(query, 50, "", "")
+				keywordResp, keywordErr := s.apiClient(r).Search(query, 50, "", "")
 				if keywordErr != nil {
-					s.renderError(w, "Search failed", keywordErr)
+					s.renderError(w, r, "Search failed", keywordErr)
 					return
 				}
 				results = convertKeywordResults(keywordResp.Results)
@@ -190,9 +202,9 @@ func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
 				results = convertSemanticResults(resp.Results)
 			}
 		} else {
-			resp, err := s.client.Search(query, 50, "", "")
+			resp, err := s.apiClient(r).Search(query, 50, "", "")
 			if err != nil {
-				s.renderError(w, "Search failed", err)
+				s.renderError(w, r, "Search failed", err)
 				return
 			}
 			results = convertKeywordResults(resp.Results)
@@ -242,27 +254,25 @@ func convertSemanticResults(results []client.SemanticSearchResult) []UnifiedSear
 	return unified
 }
 
-func (s *Server) handleDocuments(w http.ResponseWriter, r *http.Request) {
-	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
-	if page < 1 {
-		page = 1
-	}
-
-	limit := 20
-	offset := (page - 1) * limit
+func (s *Server) handleDocument(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	apiClient := s.apiClient(r)
 
-	docs, err := s.client.ListDocuments(limit, offset, "")
+	doc, err := apiClient.GetDocument(id)
 	if err != nil {
-		s.renderError(w, "Failed to list documents", err)
+		s.renderError(w, r, "Failed to get document", err)
 		return
 	}
 
-	s.render(w, "documents.html", pageData{
-		Title: "Documents",
-		Content: documentsData{
-			Documents: docs.Data,
-			Page:      page,
-			Total:     docs.Total,
+	// The owning device is shown if it can be loaded
+	device, _ := apiClient.GetDevice(doc.DeviceID, false)
+
+	s.render(w, "document.html", pageData{
+		Title: doc.Filename,
+		Content: documentData{
+			Document: doc,
+			Device:   device,
+			Preview:  s.buildPreview(r.Context(), apiClient, doc),
 		},
 	})
 }
@@ -280,9 +290,9 @@ func (s *Server) handleDevicesPartial(w http.ResponseWriter, r *http.Request) {
 	limit := 20
 	offset := (page - 1) * limit
 
-	devices, err := s.client.ListDevices(limit, offset, domain, deviceType)
+	devices, err := s.apiClient(r).ListDevices(limit, offset, domain, deviceType)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		s.renderError(w, r, "Failed to list devices", err)
 		return
 	}
 
@@ -311,13 +321,13 @@ func (s *Server) handleSearchResultsPartial(w http.ResponseWriter, r *http.Reque
 	var semanticError string
 
 	if mode == "semantic" {
-		resp, err := s.client.SemanticSearch(query, 50, "", "")
+		resp, err := s.apiClient(r).SemanticSearch(query, 50, "", "")
 		if err != nil {
 			// Fall back to keyword search with error message
 			semanticError = err.Error()
-			keywordResp, keywordErr := s.client.Search(query, 50, "", "")
+			keywordResp, keywordErr := s.apiClient(r).Search(query, 50, "", "")
 			if keywordErr != nil {
-				http.Error(w, keywordErr.Error(), http.StatusInternalServerError)
+				s.renderError(w, r, "Search failed", keywordErr)
 				return
 			}
 			results = convertKeywordResults(keywordResp.Results)
@@ -326,9 +336,9 @@ func (s *Server) handleSearchResultsPartial(w http.ResponseWriter, r *http.Reque
 			results = convertSemanticResults(resp.Results)
 		}
 	} else {
-		resp, err := s.client.Search(query, 50, "", "")
+		resp, err := s.apiClient(r).Search(query, 50, "", "")
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			s.renderError(w, r, "Search failed", err)
 			return
 		}
 		results = convertKeywordResults(resp.Results)
@@ -342,106 +352,73 @@ func (s *Server) handleSearchResultsPartial(w http.ResponseWriter, r *http.Reque
 	})
 }
 
-// Document proxy handler
-func (s *Server) handleDownload(w http.ResponseWriter, r *http.Request) {
-	id := r.PathValue("id")
-
-	// Get document metadata
-	doc, err := s.client.GetDocument(id)
-	if err != nil {
-		http.Error(w, "Document not found", http.StatusNotFound)
-		return
-	}
-
-	// Create request to API
-	downloadURL := s.client.GetDocumentDownloadURL(id)
-	req, err := http.NewRequest("GET", downloadURL, nil)
-	if err != nil {
-		http.Error(w, "Failed to create request", http.StatusInternalServerError)
-		return
-	}
-	req.Header.Set("X-API-Key", s.client.APIKey())
-
-	// Make request
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		http.Error(w, "Failed to download document", http.StatusInternalServerError)
-		return
-	}
-	defer resp.Body.Close()
-
-	// Set headers
-	w.Header().Set("Content-Type", doc.MimeType)
-	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", doc.Filename))
-	w.Header().Set("Content-Length", strconv.FormatInt(doc.SizeBytes, 10))
-
-	// Stream response
-	io.Copy(w, resp.Body)
-}
-
 // Template rendering helpers
 
 func (s *Server) render(w http.ResponseWriter, name string, data interface{}) {
-	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	s.renderStatus(w, http.StatusOK, name, data)
+}
 
-	// Clone base template and parse the specific page template
-	tmpl, err := s.baseTemplate.Clone()
+// renderStatus renders a page into a buffer so that a template failure
+// produces a clean 500 instead of a half-written page.
+func (s *Server) renderStatus(w http.ResponseWriter, status int, name string, data interface{}) {
+	tmpl, err := s.templates.page(name)
 	if err != nil {
-		s.logger.Error("template clone error", "template", name, "error", err)
+		s.logger.Error("template load error", "template", name, "error", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
-	// Parse the specific template file
-	tmpl, err = tmpl.ParseFS(templatesFS, "templates/"+name)
-	if err != nil {
-		s.logger.Error("template parse error", "template", name, "error", err)
+	var buf bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&buf, name, data); err != nil {
+		s.logger.Error("template execute error", "template", name, "error", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
-	// Execute the template
-	if err := tmpl.ExecuteTemplate(w, name, data); err != nil {
-		s.logger.Error("template execute error", "template", name, "error", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
-	}
+	writeHTML(w, status, &buf)
 }
 
 func (s *Server) renderPartial(w http.ResponseWriter, name string, data interface{}) {
-	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-
-	// Create a new template with function map
-	tmpl := template.New("").Funcs(s.funcMap)
+	s.renderPartialStatus(w, http.StatusOK, name, data)
+}
 
-	// Parse the partial template
-	tmpl, err := tmpl.ParseFS(templatesFS, "templates/"+name)
+func (s *Server) renderPartialStatus(w http.ResponseWriter, status int, name string, data interface{}) {
+	tmpl, err := s.templates.partial(name)
 	if err != nil {
-		s.logger.Error("partial template parse error", "template", name, "error", err)
+		s.logger.Error("partial template load error", "template", name, "error", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
-	// Execute the partial template
-	if err := tmpl.ExecuteTemplate(w, name, data); err != nil {
+	var buf bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&buf, name, data); err != nil {
 		s.logger.Error("partial template execute error", "template", name, "error", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
 	}
+
+	writeHTML(w, status, &buf)
 }
 
-func (s *Server) renderError(w http.ResponseWriter, message string, err error) {
+func writeHTML(w http.ResponseWriter, status int, buf *bytes.Buffer) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.WriteHeader(status)
+	buf.WriteTo(w)
+}
+
+// renderError logs an API failure and renders it with a matching status.
+func (s *Server) renderError(w http.ResponseWriter, r *http.Request, message string, err error) {
 	s.logger.Error(message, "error", err)
-	s.render(w, "error.html", pageData{
-		Title:   "Error",
-		Content: message + ": " + err.Error(),
-	})
+	s.renderErrorStatus(w, r, apiErrorStatus(err), message+": "+err.Error())
 }
 
 // Admin handlers
 
 func (s *Server) handleAdmin(w http.ResponseWriter, r *http.Request) {
-	status, err := s.client.GetStatus()
+	status, err := s.apiClient(r).GetStatus()
 	if err != nil {
-		s.renderError(w, "Failed to get status", err)
+		s.renderError(w, r, "Failed to get status", err)
 		return
 	}
 
@@ -452,9 +429,9 @@ func (s *Server) handleAdmin(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) handleAdminUsers(w http.ResponseWriter, r *http.Request) {
-	users, err := s.client.ListUsers()
+	users, err := s.apiClient(r).ListUsers()
 	if err != nil {
-		s.renderError(w, "Failed to list users", err)
+		s.renderError(w, r, "Failed to list users", err)
 		return
 	}
 
@@ -466,7 +443,7 @@ func (s *Server) handleAdminUsers(w http.ResponseWriter, r *http.Request) {
 
 func (s *Server) handleAdminCreateUser(w http.ResponseWriter, r *http.Request) {
 	if err := r.ParseForm(); err != nil {
-		http.Error(w, "Failed to parse form", http.StatusBadRequest)
+		s.renderErrorStatus(w, r, http.StatusBadRequest, "Failed to parse form")
 		return
 	}
 
@@ -474,7 +451,7 @@ func (s *Server) handleAdminCreateUser(w http.ResponseWriter, r *http.Request) {
 	preset := r.FormValue("preset")
 
 	if name == "" {
-		http.Error(w, "Name is required", http.StatusBadRequest)
+		s.renderErrorStatus(w, r, http.StatusBadRequest, "Name is required")
 		return
 	}
 
@@ -482,16 +459,16 @@ func (s *Server) handleAdminCreateUser(w http.ResponseWriter, r *http.Request) {
 		preset = "readonly" // Default to readonly preset
 	}
 
-	resp, err := s.client.CreateUser(name, preset)
+	resp, err := s.apiClient(r).CreateUser(name, preset)
 	if err != nil {
-		http.Error(w, "Failed to create user: "+err.Error(), http.StatusInternalServerError)
+		s.renderError(w, r, "Failed to create user", err)
 		return
 	}
 
 	// Get updated user list
-	users, err := s.client.ListUsers()
+	users, err := s.apiClient(r).ListUsers()
 	if err != nil {
-		http.Error(w, "Failed to list users", http.StatusInternalServerError)
+		s.renderError(w, r, "Failed to list users", err)
 		return
 	}
 
@@ -504,15 +481,15 @@ func (s *Server) handleAdminCreateUser(w http.ResponseWriter, r *http.Request) {
 func (s *Server) handleAdminDeleteUser(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
 
-	if err := s.client.DeleteUser(id); err != nil {
-		http.Error(w, "Failed to delete user: "+err.Error(), http.StatusInternalServerError)
+	if err := s.apiClient(r).DeleteUser(id); err != nil {
+		s.renderError(w, r, "Failed to delete user", err)
 		return
 	}
 
 	// Get updated user list
-	users, err := s.client.ListUsers()
+	users, err := s.apiClient(r).ListUsers()
 	if err != nil {
-		http.Error(w, "Failed to list users", http.StatusInternalServerError)
+		s.renderError(w, r, "Failed to list users", err)
 		return
 	}
 
@@ -522,16 +499,16 @@ func (s *Server) handleAdminDeleteUser(w http.ResponseWriter, r *http.Request) {
 func (s *Server) handleAdminRotateKey(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
 
-	apiKey, err := s.client.RotateAPIKey(id)
+	apiKey, err := s.apiClient(r).RotateAPIKey(id)
 	if err != nil {
-		http.Error(w, "Failed to rotate API key: "+err.Error(), http.StatusInternalServerError)
+		s.renderError(w, r, "Failed to rotate API key", err)
 		return
 	}
 
 	// Get updated user list
-	users, err := s.client.ListUsers()
+	users, err := s.apiClient(r).ListUsers()
 	if err != nil {
-		http.Error(w, "Failed to list users", http.StatusInternalServerError)
+		s.renderError(w, r, "Failed to list users", err)
 		return
 	}
 
@@ -542,9 +519,9 @@ func (s *Server) handleAdminRotateKey(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) handleAdminSettings(w http.ResponseWriter, r *http.Request) {
-	settings, err := s.client.ListSettings()
+	settings, err := s.apiClient(r).ListSettings()
 	if err != nil {
-		s.renderError(w, "Failed to list settings", err)
+		s.renderError(w, r, "Failed to list settings", err)
 		return
 	}
 
@@ -558,21 +535,21 @@ func (s *Server) handleAdminUpdateSetting(w http.ResponseWriter, r *http.Request
 	key := r.PathValue("key")
 
 	if err := r.ParseForm(); err != nil {
-		http.Error(w, "Failed to parse form", http.StatusBadRequest)
+		s.renderErrorStatus(w, r, http.StatusBadRequest, "Failed to parse form")
 		return
 	}
 
 	value := r.FormValue("value")
 
-	if err := s.client.UpdateSetting(key, value); err != nil {
-		http.Error(w, "Failed to update setting: "+err.Error(), http.StatusInternalServerError)
+	if err := s.apiClient(r).UpdateSetting(key, value); err != nil {
+		s.renderError(w, r, "Failed to update setting", err)
 		return
 	}
 
 	// Get updated settings list
-	settings, err := s.client.ListSettings()
+	settings, err := s.apiClient(r).ListSettings()
 	if err != nil {
-		http.Error(w, "Failed to list settings", http.StatusInternalServerError)
+		s.renderError(w, r, "Failed to list settings", err)
 		return
 	}
 
@@ -580,11 +557,12 @@ func (s *Server) handleAdminUpdateSetting(w http.ResponseWriter, r *http.Request
 }
 
 func (s *Server) handleAdminReindex(w http.ResponseWriter, r *http.Request) {
-	status, err := s.client.GetReindexStatus()
+	status, err := s.apiClient(r).GetReindexStatus()
 	if err != nil {
-		s.renderError(w, "Failed to get reindex status", err)
+		s.renderError(w, r, "Failed to get reindex status", err)
 		return
 	}
+	s.observeReindex(s.apiClient(r), status)
 
 	s.render(w, "admin-reindex.html", pageData{
 		Title:   "Reindex",
@@ -593,27 +571,29 @@ func (s *Server) handleAdminReindex(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) handleAdminTriggerReindex(w http.ResponseWriter, r *http.Request) {
-	if err := s.client.TriggerReindex(); err != nil {
-		http.Error(w, "Failed to trigger reindex: "+err.Error(), http.StatusInternalServerError)
+	if err := s.apiClient(r).TriggerReindex(); err != nil {
+		s.renderError(w, r, "Failed to trigger reindex", err)
 		return
 	}
 
 	// Get updated status
-	status, err := s.client.GetReindexStatus()
+	status, err := s.apiClient(r).GetReindexStatus()
 	if err != nil {
-		http.Error(w, "Failed to get reindex status", http.StatusInternalServerError)
+		s.renderError(w, r, "Failed to get reindex status", err)
 		return
 	}
+	s.observeReindex(s.apiClient(r), status)
 
 	s.renderPartial(w, "partials/reindex-status.html", status)
 }
 
 func (s *Server) handleAdminReindexStatus(w http.ResponseWriter, r *http.Request) {
-	status, err := s.client.GetReindexStatus()
+	status, err := s.apiClient(r).GetReindexStatus()
 	if err != nil {
-		http.Error(w, "Failed to get reindex status", http.StatusInternalServerError)
+		s.renderError(w, r, "Failed to get reindex status", err)
 		return
 	}
+	s.observeReindex(s.apiClient(r), status)
 
 	s.renderPartial(w, "partials/reindex-status.html", status)
 }
@@ -621,7 +601,7 @@ func (s *Server) handleAdminReindexStatus(w http.ResponseWriter, r *http.Request
 // handleHealth proxies health check requests to the API server
 func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 	// Get health status from API
-	health, err := s.client.GetHealth()
+	health, err := s.apiClient(r).GetHealth()
 	if err != nil {
 		s.logger.Error("health check failed", "error", err)
 		http.Error(w, "Health check failed", http.StatusServiceUnavailable)
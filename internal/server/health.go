@@ -0,0 +1,334 @@
+package server
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rmrfslashbin/manuals-webui/internal/client"
+)
+
+const (
+	// healthConcurrency is how many devices are checked at once.
+	healthConcurrency = 4
+
+	// healthStaleGap is how far a device's IndexedAt may trail the most
+	// recently indexed device before it is reported as stale. A device
+	// that far behind was missed by the latest reindex, typically because
+	// it was moved or removed from the docs tree.
+	healthStaleGap = 24 * time.Hour
+)
+
+// Issue kinds reported by the catalog health scan, in report order.
+const (
+	issueNoPinout     = "no pinout"
+	issueNoSpecs      = "no specs"
+	issueNoDocuments  = "no documents"
+	issueNoContent    = "empty content"
+	issueDuplicatePin = "duplicate pin"
+	issueDuplicateIO  = "duplicate GPIO"
+	issueBrokenRef    = "broken ref"
+	issueStale        = "stale index"
+	issueError        = "error"
+)
+
+var healthIssueKinds = []string{
+	issueNoPinout, issueNoSpecs, issueNoDocuments, issueNoContent,
+	issueDuplicatePin, issueDuplicateIO, issueBrokenRef, issueStale, issueError,
+}
+
+// healthIssue is one problem found with a device.
+type healthIssue struct {
+	Device client.Device
+	Kind   string
+	Detail string
+}
+
+// healthCount is the number of devices with an issue kind.
+type healthCount struct {
+	Kind    string
+	Devices int
+}
+
+// healthReport is the result of a catalog health scan.
+type healthReport struct {
+	Generated time.Time
+	Devices   int
+	Healthy   int           // devices with no issues
+	Issues    []healthIssue // by device name, then kind
+	Counts    []healthCount // kinds with issues, in report order
+}
+
+// healthData is passed to the catalog health admin templates.
+type healthData struct {
+	Status jobStatus
+	Report *healthReport
+}
+
+// healthChecker scans every device for gaps and inconsistencies the
+// people curating the docs tree should fix.
+type healthChecker struct {
+	srv *Server
+	job *job
+
+	mu     sync.Mutex
+	report *healthReport
+}
+
+func newHealthChecker(s *Server) *healthChecker {
+	return &healthChecker{srv: s, job: newJob(s, "catalog health scan")}
+}
+
+// start begins a scan using the given API client. The previous report
+// stays available until the new one is complete. It returns false if a
+// scan is already running.
+func (hc *healthChecker) start(c *client.Client) bool {
+	return hc.job.start(func(ctx context.Context, p *jobProgress) error {
+		report, err := hc.scan(ctx, c, p)
+		if err != nil {
+			return err
+		}
+		hc.mu.Lock()
+		hc.report = report
+		hc.mu.Unlock()
+		return nil
+	})
+}
+
+func (hc *healthChecker) data() healthData {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	return healthData{Status: hc.job.snapshot(), Report: hc.report}
+}
+
+// scan checks healthConcurrency devices at a time, then compares their
+// IndexedAt times.
+func (hc *healthChecker) scan(ctx context.Context, c *client.Client, p *jobProgress) (*healthReport, error) {
+	devices, err := hc.srv.devices.get(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	p.setTotal(int64(len(devices.Devices)))
+
+	report := &healthReport{Devices: len(devices.Devices)}
+	var mu sync.Mutex
+	err = eachDevice(ctx, devices.Devices, healthConcurrency, func(device client.Device) {
+		issues := checkDevice(c, devices, device)
+		p.add(1)
+
+		mu.Lock()
+		defer mu.Unlock()
+		report.Issues = append(report.Issues, issues...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	report.Issues = append(report.Issues, staleDevices(devices.Devices)...)
+
+	rank := make(map[string]int)
+	for i, kind := range healthIssueKinds {
+		rank[kind] = i
+	}
+	sort.SliceStable(report.Issues, func(i, j int) bool {
+		a, b := report.Issues[i], report.Issues[j]
+		if an, bn := strings.ToLower(a.Device.Name), strings.ToLower(b.Device.Name); an != bn {
+			return an < bn
+		}
+		if a.Device.ID != b.Device.ID {
+			return a.Device.ID < b.Device.ID
+		}
+		return rank[a.Kind] < rank[b.Kind]
+	})
+
+	affected := make(map[string]bool)
+	byKind := make(map[string]map[string]bool)
+	for _, issue := range report.Issues {
+		affected[issue.Device.ID] = true
+		if byKind[issue.Kind] == nil {
+			byKind[issue.Kind] = make(map[string]bool)
+		}
+		byKind[issue.Kind][issue.Device.ID] = true
+	}
+	for _, kind := range healthIssueKinds {
+		if n := len(byKind[kind]); n > 0 {
+			report.Counts = append(report.Counts, healthCount{Kind: kind, Devices: n})
+		}
+	}
+	report.Healthy = report.Devices - len(affected)
+	report.Generated = time.Now()
+	return report, nil
+}
+
+// checkDevice fetches what the catalog holds for one device and reports
+// what is missing or inconsistent. API failures other than "not found"
+// are reported as errors rather than as missing data.
+func checkDevice(c *client.Client, devices *deviceIndex, device client.Device) []healthIssue {
+	var issues []healthIssue
+	add := func(kind, detail string) {
+		issues = append(issues, healthIssue{Device: device, Kind: kind, Detail: detail})
+	}
+	failed := func(what string, err error) {
+		add(issueError, fmt.Sprintf("loading %s: %v", what, err))
+	}
+
+	if full, err := c.GetDevice(device.ID, true); err != nil {
+		failed("device", err)
+	} else if strings.TrimSpace(full.Content) == "" {
+		add(issueNoContent, "")
+	}
+
+	pinout, err := c.GetDevicePinout(device.ID)
+	switch {
+	case isNotFound(err) || (err == nil && len(pinout.Pins) == 0):
+		add(issueNoPinout, "")
+	case err != nil:
+		failed("pinout", err)
+	default:
+		for _, detail := range duplicatePins(pinout) {
+			add(issueDuplicatePin, detail)
+		}
+		for _, detail := range duplicateGPIOs(pinout) {
+			add(issueDuplicateIO, detail)
+		}
+	}
+
+	specs, err := c.GetDeviceSpecs(device.ID)
+	switch {
+	case isNotFound(err) || (err == nil && len(specs.Specs) == 0):
+		add(issueNoSpecs, "")
+	case err != nil:
+		failed("specs", err)
+	}
+
+	docs, err := c.ListDocuments(1, 0, device.ID)
+	switch {
+	case err != nil:
+		failed("documents", err)
+	case docs.Total == 0 && len(docs.Data) == 0:
+		add(issueNoDocuments, "")
+	}
+
+	refs, err := c.GetDeviceRefs(device.ID)
+	switch {
+	case isNotFound(err):
+	case err != nil:
+		failed("refs", err)
+	default:
+		for _, ref := range refs.References {
+			if ref.ID != "" && devices.lookup(ref.ID) == nil {
+				add(issueBrokenRef, fmt.Sprintf("%q refers to unknown device %q", ref.Title, ref.ID))
+			}
+		}
+	}
+	return issues
+}
+
+// duplicatePins describes physical pin numbers used by more than one pin.
+func duplicatePins(pinout *client.PinoutResponse) []string {
+	names := make(map[int][]string)
+	for _, pin := range pinout.Pins {
+		names[pin.PhysicalPin] = append(names[pin.PhysicalPin], pin.Name)
+	}
+	return describeRepeats(names, "pin %d is listed %d times (%s)")
+}
+
+// duplicateGPIOs describes GPIO numbers assigned to more than one pin.
+func duplicateGPIOs(pinout *client.PinoutResponse) []string {
+	pins := make(map[int][]string)
+	for _, pin := range pinout.Pins {
+		if pin.GPIONum != nil {
+			pins[*pin.GPIONum] = append(pins[*pin.GPIONum], strconv.Itoa(pin.PhysicalPin))
+		}
+	}
+	return describeRepeats(pins, "GPIO %d is on %d pins (%s)")
+}
+
+func describeRepeats(values map[int][]string, format string) []string {
+	var keys []int
+	for k, v := range values {
+		if len(v) > 1 {
+			keys = append(keys, k)
+		}
+	}
+	sort.Ints(keys)
+	details := make([]string, len(keys))
+	for i, k := range keys {
+		details[i] = fmt.Sprintf(format, k, len(values[k]), strings.Join(values[k], ", "))
+	}
+	return details
+}
+
+// staleDevices reports devices indexed well before the most recently
+// indexed one, and devices whose IndexedAt can't be read.
+func staleDevices(devices []client.Device) []healthIssue {
+	var latest time.Time
+	for _, d := range devices {
+		if t := indexedTime(d.IndexedAt); t.After(latest) {
+			latest = t
+		}
+	}
+
+	var issues []healthIssue
+	for _, d := range devices {
+		t, err := time.Parse(time.RFC3339, d.IndexedAt)
+		switch {
+		case err != nil:
+			issues = append(issues, healthIssue{Device: d, Kind: issueStale, Detail: fmt.Sprintf("unreadable IndexedAt %q", d.IndexedAt)})
+		case latest.Sub(t) > healthStaleGap:
+			issues = append(issues, healthIssue{Device: d, Kind: issueStale, Detail: fmt.Sprintf("indexed %s, %s before the newest device",
+				t.Format("2006-01-02 15:04"), latest.Sub(t).Round(time.Hour))})
+		}
+	}
+	return issues
+}
+
+// Admin handlers
+
+func (s *Server) handleAdminHealth(w http.ResponseWriter, r *http.Request) {
+	s.render(w, "admin-health.html", pageData{
+		Title:   "Catalog Health",
+		Content: s.health.data(),
+	})
+}
+
+func (s *Server) handleAdminStartHealth(w http.ResponseWriter, r *http.Request) {
+	if !s.health.start(s.apiClient(r)) {
+		s.renderErrorStatus(w, r, http.StatusConflict, "A catalog health scan is already running")
+		return
+	}
+	s.renderPartial(w, "partials/health-report.html", s.health.data())
+}
+
+func (s *Server) handleAdminHealthStatus(w http.ResponseWriter, r *http.Request) {
+	s.renderPartial(w, "partials/health-report.html", s.health.data())
+}
+
+// handleAdminHealthCSV exports the latest report with one row per issue.
+func (s *Server) handleAdminHealthCSV(w http.ResponseWriter, r *http.Request) {
+	report := s.health.data().Report
+	if report == nil {
+		s.renderErrorStatus(w, r, http.StatusNotFound, "No catalog health report yet; run a scan first")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": "catalog-health.csv"}))
+
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"device_id", "device_name", "domain", "type", "path", "issue", "detail"})
+	for _, issue := range report.Issues {
+		d := issue.Device
+		cw.Write([]string{d.ID, d.Name, d.Domain, d.Type, d.Path, issue.Kind, issue.Detail})
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		s.logger.Error("failed to write catalog health CSV", "error", err)
+	}
+}
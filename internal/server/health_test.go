@@ -0,0 +1,142 @@
+package server
+
+import (
+	"encoding/csv"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rmrfslashbin/manuals-webui/internal/client"
+)
+
+// healthAPIServer serves a small catalog with one healthy device and one
+// of each problem the health scan looks for.
+func healthAPIServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	devices := []client.Device{
+		{ID: "esp32", Name: "ESP32", Path: "hardware/mcu/esp32", IndexedAt: "2026-10-01T12:00:00Z", Content: "# ESP32"},
+		{ID: "bme280", Name: "BME280", Path: "hardware/sensors/bme280", IndexedAt: "2026-10-01T11:00:00Z", Content: "# BME280"},
+		{ID: "old", Name: "Old Board", Path: "hardware/mcu/old", IndexedAt: "2026-08-01T12:00:00Z"},
+		{ID: "flaky", Name: "Flaky", Path: "hardware/mcu/flaky", IndexedAt: "not a time", Content: "# Flaky"},
+	}
+	pinouts := pinoutResponses(map[string][]client.PinoutPin{
+		"esp32": {
+			{PhysicalPin: 1, Name: "3V3"},
+			{PhysicalPin: 2, GPIONum: gpio(21), Name: "GPIO21"},
+		},
+		"bme280": {
+			{PhysicalPin: 1, Name: "VDD"},
+			{PhysicalPin: 1, Name: "VDDIO"},
+			{PhysicalPin: 3, GPIONum: gpio(4), Name: "SDA"},
+			{PhysicalPin: 4, GPIONum: gpio(4), Name: "SCL"},
+		},
+	})
+	specs := specResponses(map[string]map[string]string{"esp32": {"flash": "4MB"}, "bme280": {"voltage": "3.3V"}})
+	docs := map[string]any{}
+	for id, n := range map[string]int{"esp32": 2, "bme280": 0, "old": 1, "flaky": 0} {
+		docs[id] = client.DocumentsResponse{Data: make([]client.Document, min(n, 1)), Total: n}
+	}
+	refs := map[string]any{
+		"esp32":  client.RefsResponse{DeviceID: "esp32", References: []client.Reference{{Type: "related", Title: "BME280", ID: "bme280"}, {Type: "link", Title: "Espressif", URL: "https://espressif.com"}}},
+		"bme280": client.RefsResponse{DeviceID: "bme280", References: []client.Reference{{Type: "related", Title: "BMP180", ID: "bmp180"}}},
+		"old":    client.RefsResponse{DeviceID: "old"},
+	}
+	// Every per-device request for flaky fails
+	for _, responses := range []map[string]any{pinouts, specs, refs} {
+		responses["flaky"] = http.StatusInternalServerError
+	}
+
+	api, _ := deviceAPIServer(t, devices, map[string]map[string]any{
+		"pinout":    pinouts,
+		"specs":     specs,
+		"refs":      refs,
+		"documents": docs,
+	})
+	return api
+}
+
+func TestHealthScan(t *testing.T) {
+	api := healthAPIServer(t)
+	defer api.Close()
+	s := testServer(t, api)
+
+	s.health.start(client.New(api.URL, "test-key"))
+	if st := waitForJob(t, s.health.job); st.Error != "" {
+		t.Fatalf("scan failed: %s", st.Error)
+	}
+	report := s.health.data().Report
+
+	var got []string
+	for _, issue := range report.Issues {
+		got = append(got, issue.Device.ID+": "+issue.Kind+" "+issue.Detail)
+	}
+	expected := []string{
+		`bme280: no documents `,
+		`bme280: duplicate pin pin 1 is listed 2 times (VDD, VDDIO)`,
+		`bme280: duplicate GPIO GPIO 4 is on 2 pins (3, 4)`,
+		`bme280: broken ref "BMP180" refers to unknown device "bmp180"`,
+		`flaky: no documents `,
+		`flaky: stale index unreadable IndexedAt "not a time"`,
+		`flaky: error loading pinout: API error (500): `,
+		`flaky: error loading specs: API error (500): `,
+		`flaky: error loading refs: API error (500): `,
+		`old: no pinout `,
+		`old: no specs `,
+		`old: empty content `,
+		`old: stale index indexed 2026-08-01 12:00, 1464h0m0s before the newest device`,
+	}
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("unexpected issues:\n%s", strings.Join(got, "\n"))
+	}
+	if report.Devices != 4 || report.Healthy != 1 {
+		t.Errorf("expected 1 of 4 devices healthy, got %d of %d", report.Healthy, report.Devices)
+	}
+	if len(report.Counts) == 0 || report.Counts[0] != (healthCount{Kind: issueNoPinout, Devices: 1}) {
+		t.Errorf("unexpected counts %v", report.Counts)
+	}
+}
+
+func TestHandleAdminHealth(t *testing.T) {
+	api := healthAPIServer(t)
+	defer api.Close()
+	s := testServer(t, api)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		s.Handler().ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		return w
+	}
+	if w := get("/admin/health.csv"); w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 before a scan, got %d", w.Code)
+	}
+
+	w := httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, httptest.NewRequest("POST", "/admin/health", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	waitForJob(t, s.health.job)
+
+	w = get("/admin/health")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	for _, want := range []string{"Catalog Health", "no pinout: 1 devices", "hardware/mcu/old", `href="/admin/health.csv"`} {
+		if !strings.Contains(w.Body.String(), want) {
+			t.Errorf("expected page to contain %q", want)
+		}
+	}
+
+	w = get("/admin/health.csv")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	records, err := csv.NewReader(w.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("invalid CSV: %v", err)
+	}
+	if len(records) != 14 || strings.Join(records[1], ",") != "bme280,BME280,,,hardware/sensors/bme280,no documents," {
+		t.Errorf("unexpected CSV: %v", records[:2])
+	}
+}
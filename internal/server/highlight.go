@@ -0,0 +1,77 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"regexp"
+
+	"github.com/alecthomas/chroma/v2"
+	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/styles"
+	"github.com/yuin/goldmark"
+	highlighting "github.com/yuin/goldmark-highlighting/v2"
+)
+
+// Chroma styles used for code blocks in the light and dark themes.
+const (
+	highlightLightStyle = "github"
+	highlightDarkStyle  = "github-dark"
+)
+
+// highlightClassPattern matches the token classes Chroma puts on spans,
+// such as "k", "nf", "s2", "line" and "cl".
+var highlightClassPattern = regexp.MustCompile(`^[a-z][a-z0-9]{0,3}$|^line$`)
+
+// highlightRulePattern matches the start of each rule Chroma writes,
+// after an optional comment naming the token type.
+var highlightRulePattern = regexp.MustCompile(`(?m)^((?:/\*[^*]*\*/ )?)(\.)`)
+
+// newHighlighting returns the goldmark extension that highlights fenced
+// code blocks with a known language. Tokens get CSS classes rather than
+// inline styles, so highlightCSS can follow the page theme.
+func newHighlighting() goldmark.Extender {
+	return highlighting.NewHighlighting(
+		highlighting.WithStyle(highlightLightStyle),
+		highlighting.WithFormatOptions(chromahtml.WithClasses(true)),
+	)
+}
+
+// highlightCSS builds the stylesheet for highlighted code blocks, with
+// the light style under html:not(.dark) and the dark style under html.dark
+// so each applies only in its theme.
+func highlightCSS() ([]byte, error) {
+	formatter := chromahtml.New(chromahtml.WithClasses(true))
+
+	var buf bytes.Buffer
+	for _, theme := range []struct{ scope, style string }{
+		{"html:not(.dark) ", highlightLightStyle},
+		{"html.dark ", highlightDarkStyle},
+	} {
+		style := styles.Get(theme.style)
+		var css bytes.Buffer
+		if err := formatter.WriteCSS(&css, style); err != nil {
+			return nil, err
+		}
+		buf.Write(highlightRulePattern.ReplaceAll(css.Bytes(), []byte("${1}"+theme.scope+"$2")))
+
+		// Some styles leave plain text to the page, which would inherit the
+		// prose colors for preformatted text instead of contrasting with
+		// the style's background.
+		text := style.Get(chroma.Text).Colour
+		if !text.IsSet() {
+			text = chroma.NewColour(255, 255, 255)
+			if style.Get(chroma.Background).Background.Brightness() > 0.5 {
+				text = chroma.NewColour(0, 0, 0)
+			}
+		}
+		fmt.Fprintf(&buf, "/* Text */ %s.chroma { color: %s }\n", theme.scope, text)
+	}
+	return buf.Bytes(), nil
+}
+
+func (s *Server) handleHighlightCSS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/css; charset=utf-8")
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	w.Write(s.highlightCSS)
+}
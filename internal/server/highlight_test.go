@@ -0,0 +1,123 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRenderMarkdownHighlighting(t *testing.T) {
+	mr := newMarkdownRenderer()
+
+	tests := []struct {
+		name    string
+		input   string
+		want    []string
+		notWant []string
+	}{
+		{
+			name:  "c",
+			input: "```c\nint main(void) { return 0; }\n```",
+			want: []string{
+				`<pre class="chroma"><code>`,
+				`<span class="kt">int</span> <span class="nf">main</span>`,
+				`<span class="k">return</span> <span class="mi">0</span>`,
+			},
+			notWant: []string{"style=", "tabindex"},
+		},
+		{
+			name:  "python",
+			input: "```python\ndef f():\n    return \"x\"\n```",
+			want:  []string{`<span class="k">def</span>`, `<span class="s2">&#34;x&#34;</span>`},
+		},
+		{
+			name:  "shell",
+			input: "```sh\necho $HOME\n```",
+			want:  []string{`<span class="nb">echo</span>`, `<span class="nv">$HOME</span>`},
+		},
+		{
+			name:    "no language",
+			input:   "```\nplain <b>text</b>\n```",
+			want:    []string{"<pre><code>plain &lt;b&gt;text&lt;/b&gt;\n</code></pre>"},
+			notWant: []string{"chroma"},
+		},
+		{
+			name:    "unknown language",
+			input:   "```nosuchlang\nx = 1\n```",
+			want:    []string{"<pre><code>x = 1\n</code></pre>"},
+			notWant: []string{"chroma"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			html := string(mr.RenderMarkdown(tt.input))
+			for _, want := range tt.want {
+				if !strings.Contains(html, want) {
+					t.Errorf("expected HTML to contain %q, got:\n%s", want, html)
+				}
+			}
+			for _, notWant := range tt.notWant {
+				if strings.Contains(html, notWant) {
+					t.Errorf("expected HTML not to contain %q, got:\n%s", notWant, html)
+				}
+			}
+		})
+	}
+}
+
+func TestSanitizerHighlightClasses(t *testing.T) {
+	mr := newMarkdownRenderer()
+	html := mr.sanitizer.Sanitize(`<pre class="chroma evil"><code><span class="k">a</span><span class="sr-only hidden">b</span><p class="k">c</p></code></pre>`)
+	if html != `<pre><code><span class="k">a</span><span>b</span><p>c</p></code></pre>` {
+		t.Errorf("unexpected sanitized HTML %s", html)
+	}
+}
+
+func TestHighlightCSS(t *testing.T) {
+	css, err := highlightCSS()
+	if err != nil {
+		t.Fatalf("highlightCSS failed: %v", err)
+	}
+	for _, line := range strings.Split(strings.TrimSpace(string(css)), "\n") {
+		_, rule, _ := strings.Cut(line, "*/ ")
+		if !strings.HasPrefix(rule, "html:not(.dark) .") && !strings.HasPrefix(rule, "html.dark .") {
+			t.Errorf("expected every rule to be scoped to a theme, got %q", line)
+		}
+	}
+	for _, want := range []string{
+		"html:not(.dark) .chroma .k {",
+		"html.dark .chroma .k {",
+		"/* Text */ html:not(.dark) .chroma { color: #000000 }",
+		"/* Text */ html.dark .chroma { color: #e6edf3 }",
+	} {
+		if !strings.Contains(string(css), want) {
+			t.Errorf("expected CSS to contain %q", want)
+		}
+	}
+}
+
+func TestHandleHighlightCSS(t *testing.T) {
+	apiServer := mockAPIServer(t, nil)
+	defer apiServer.Close()
+	s := testServer(t, apiServer)
+
+	w := httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/static/highlight.css", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "text/css; charset=utf-8" {
+		t.Errorf("expected text/css, got %q", ct)
+	}
+	if !strings.Contains(w.Body.String(), "html.dark .chroma") {
+		t.Error("expected the highlighting stylesheet")
+	}
+
+	// Other static files are still served from disk
+	w = httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/static/clipboard.js", nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "addCodeCopyButtons") {
+		t.Errorf("expected clipboard.js to be served, got %d", w.Code)
+	}
+}
@@ -0,0 +1,248 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rmrfslashbin/manuals-webui/internal/client"
+)
+
+const (
+	// integrityConcurrency bounds parallel downloads during a scan.
+	integrityConcurrency = 4
+
+	// integrityPageSize is how many documents are listed per API call.
+	integrityPageSize = 100
+
+	// maxRecentMismatches is how many download-time mismatches are kept.
+	maxRecentMismatches = 50
+)
+
+// Problem kinds reported by the integrity scan.
+const (
+	problemCorrupt      = "corrupt"
+	problemMissing      = "missing"
+	problemSizeMismatch = "size mismatch"
+	problemError        = "error"
+)
+
+// integrityProblem is a document that failed verification.
+type integrityProblem struct {
+	Document client.Document
+	Kind     string
+	Detail   string
+	Seen     time.Time
+}
+
+// integrityReport is the result of an integrity scan.
+type integrityReport struct {
+	Checked      int
+	Verified     int
+	Unverifiable int
+	Problems     []integrityProblem
+}
+
+// integrityData is passed to the integrity admin templates.
+type integrityData struct {
+	Status     jobStatus
+	Report     *integrityReport
+	Mismatches []integrityProblem
+}
+
+// integrityChecker runs integrity scans and remembers checksum mismatches
+// observed while proxying downloads.
+type integrityChecker struct {
+	job *job
+
+	mu         sync.Mutex
+	report     *integrityReport
+	mismatches []integrityProblem
+}
+
+func newIntegrityChecker(s *Server) *integrityChecker {
+	return &integrityChecker{job: newJob(s, "integrity scan")}
+}
+
+// recordMismatch notes a document whose download did not verify.
+func (ic *integrityChecker) recordMismatch(doc client.Document, err error) {
+	ic.mu.Lock()
+	defer ic.mu.Unlock()
+
+	problem := integrityProblem{Document: doc, Kind: problemKind(err), Detail: err.Error(), Seen: time.Now()}
+	ic.mismatches = append([]integrityProblem{problem}, ic.mismatches...)
+	if len(ic.mismatches) > maxRecentMismatches {
+		ic.mismatches = ic.mismatches[:maxRecentMismatches]
+	}
+}
+
+func (ic *integrityChecker) data() integrityData {
+	ic.mu.Lock()
+	defer ic.mu.Unlock()
+
+	data := integrityData{
+		Status:     ic.job.snapshot(),
+		Mismatches: append([]integrityProblem(nil), ic.mismatches...),
+	}
+	// Copy the report since a running scan keeps appending to it
+	if ic.report != nil {
+		report := *ic.report
+		report.Problems = append([]integrityProblem(nil), ic.report.Problems...)
+		data.Report = &report
+	}
+	return data
+}
+
+// start begins a scan using the given API client. It returns false if a
+// scan is already running.
+func (ic *integrityChecker) start(c *client.Client) bool {
+	return ic.job.start(func(ctx context.Context, p *jobProgress) error {
+		report := &integrityReport{}
+		ic.mu.Lock()
+		ic.report = report
+		ic.mu.Unlock()
+
+		return ic.scan(ctx, c, p, report)
+	})
+}
+
+// scan lists every document and verifies each one with a bounded number
+// of concurrent downloads. The report is updated as results arrive.
+func (ic *integrityChecker) scan(ctx context.Context, c *client.Client, p *jobProgress, report *integrityReport) error {
+	docs := make(chan client.Document)
+	var wg sync.WaitGroup
+	for i := 0; i < integrityConcurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for doc := range docs {
+				problem, verified := verifyDocument(ctx, c, doc)
+				if ctx.Err() != nil {
+					// Results after cancellation are download errors, not findings
+					continue
+				}
+
+				ic.mu.Lock()
+				report.Checked++
+				switch {
+				case problem != nil:
+					report.Problems = append(report.Problems, *problem)
+				case verified:
+					report.Verified++
+				default:
+					report.Unverifiable++
+				}
+				ic.mu.Unlock()
+				p.add(1)
+			}
+		}()
+	}
+
+	err := listAllDocuments(ctx, c, "", func(total int, page []client.Document) error {
+		p.setTotal(int64(total))
+		for _, doc := range page {
+			select {
+			case docs <- doc:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		return nil
+	})
+	close(docs)
+	wg.Wait()
+
+	ic.mu.Lock()
+	sort.Slice(report.Problems, func(i, j int) bool {
+		return report.Problems[i].Document.Path < report.Problems[j].Document.Path
+	})
+	ic.mu.Unlock()
+
+	return err
+}
+
+// listAllDocuments pages through ListDocuments, optionally for a single
+// device, calling fn for each page.
+func listAllDocuments(ctx context.Context, c *client.Client, deviceID string, fn func(total int, page []client.Document) error) error {
+	// The API may return fewer documents than requested, so advance by
+	// what was actually received
+	for offset := 0; ; {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		resp, err := c.ListDocuments(integrityPageSize, offset, deviceID)
+		if err != nil {
+			return err
+		}
+		if err := fn(resp.Total, resp.Data); err != nil {
+			return err
+		}
+		offset += len(resp.Data)
+		if len(resp.Data) == 0 || offset >= resp.Total {
+			return nil
+		}
+	}
+}
+
+// verifyDocument downloads a document and checks it against its metadata.
+// verified is false when the document has no checksum to compare with.
+func verifyDocument(ctx context.Context, c *client.Client, doc client.Document) (problem *integrityProblem, verified bool) {
+	fail := func(kind string, err error) (*integrityProblem, bool) {
+		return &integrityProblem{Document: doc, Kind: kind, Detail: err.Error(), Seen: time.Now()}, false
+	}
+
+	resp, err := c.DownloadDocument(ctx, doc.ID, nil)
+	if err != nil {
+		var apiErr *client.APIError
+		if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+			return fail(problemMissing, err)
+		}
+		return fail(problemError, err)
+	}
+	defer resp.Body.Close()
+
+	verifier := newChecksumVerifier(&doc)
+	if _, err := io.Copy(verifier, resp.Body); err != nil {
+		return fail(problemError, err)
+	}
+	if err := verifier.verify(); err != nil {
+		return fail(problemKind(err), err)
+	}
+	return nil, verifier.hasChecksum()
+}
+
+func problemKind(err error) string {
+	switch {
+	case errors.Is(err, errSizeMismatch):
+		return problemSizeMismatch
+	case errors.Is(err, errChecksumMismatch):
+		return problemCorrupt
+	default:
+		return problemError
+	}
+}
+
+// Admin handlers
+
+func (s *Server) handleAdminIntegrity(w http.ResponseWriter, r *http.Request) {
+	s.render(w, "admin-integrity.html", pageData{
+		Title:   "Integrity",
+		Content: s.integrity.data(),
+	})
+}
+
+func (s *Server) handleAdminStartIntegrity(w http.ResponseWriter, r *http.Request) {
+	if !s.integrity.start(s.apiClient(r)) {
+		s.renderErrorStatus(w, r, http.StatusConflict, "An integrity scan is already running")
+		return
+	}
+	s.renderPartial(w, "partials/integrity-report.html", s.integrity.data())
+}
+
+func (s *Server) handleAdminIntegrityStatus(w http.ResponseWriter, r *http.Request) {
+	s.renderPartial(w, "partials/integrity-report.html", s.integrity.data())
+}
@@ -0,0 +1,133 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/rmrfslashbin/manuals-webui/internal/client"
+)
+
+func TestIntegrityScan(t *testing.T) {
+	good := testDocument("good", "good content")
+	corrupt := testDocument("corrupt", "original content")
+	short := testDocument("short", "full length content")
+	missing := testDocument("missing", "gone")
+	unverified := &client.Document{ID: "plain", Filename: "plain.txt", SizeBytes: 5}
+
+	content := map[string]string{
+		"good":    "good content",
+		"corrupt": "tampered content",
+		"short":   "full length",
+		"plain":   "hello",
+	}
+	docs := []client.Document{*good, *corrupt, *short, *missing, *unverified}
+
+	var inflight, maxInflight, listCalls atomic.Int32
+	apiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/download"):
+			n := inflight.Add(1)
+			defer inflight.Add(-1)
+			for {
+				m := maxInflight.Load()
+				if n <= m || maxInflight.CompareAndSwap(m, n) {
+					break
+				}
+			}
+			id := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/api/"+client.APIVersion+"/documents/"), "/download")
+			body, ok := content[id]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				json.NewEncoder(w).Encode(client.ErrorResponse{Error: "file not found"})
+				return
+			}
+			w.Write([]byte(body))
+		case strings.HasSuffix(r.URL.Path, "/documents"):
+			// The API caps pages at two documents to exercise paging
+			offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+			end := min(offset+2, len(docs))
+			listCalls.Add(1)
+			json.NewEncoder(w).Encode(client.DocumentsResponse{Data: docs[offset:end], Total: len(docs)})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer apiServer.Close()
+
+	s := testServer(t, apiServer)
+
+	if !s.integrity.start(s.client) {
+		t.Fatal("expected scan to start")
+	}
+	st := waitForJob(t, s.integrity.job)
+	if st.Error != "" {
+		t.Fatalf("scan failed: %s", st.Error)
+	}
+
+	data := s.integrity.data()
+	report := data.Report
+	if report.Checked != 5 || report.Verified != 1 || report.Unverifiable != 1 {
+		t.Errorf("unexpected counts %+v", report)
+	}
+
+	kinds := map[string]string{}
+	for _, p := range report.Problems {
+		kinds[p.Document.ID] = p.Kind
+	}
+	expected := map[string]string{
+		"corrupt": problemCorrupt,
+		"short":   problemSizeMismatch,
+		"missing": problemMissing,
+	}
+	for id, kind := range expected {
+		if kinds[id] != kind {
+			t.Errorf("expected %s to be %q, got %q", id, kind, kinds[id])
+		}
+	}
+	if len(kinds) != len(expected) {
+		t.Errorf("unexpected problems %v", kinds)
+	}
+	if listCalls.Load() != 3 {
+		t.Errorf("expected 3 list calls, got %d", listCalls.Load())
+	}
+	if maxInflight.Load() > integrityConcurrency {
+		t.Errorf("expected at most %d concurrent downloads, got %d", integrityConcurrency, maxInflight.Load())
+	}
+}
+
+func TestHandleDownloadRecordsMismatch(t *testing.T) {
+	doc := testDocument("doc-1", "expected content")
+	apiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/download") {
+			w.Write([]byte("corrupted bytes!"))
+			return
+		}
+		json.NewEncoder(w).Encode(doc)
+	}))
+	defer apiServer.Close()
+
+	s := testServer(t, apiServer)
+
+	req := httptest.NewRequest("GET", "/download/doc-1", nil)
+	req.SetPathValue("id", "doc-1")
+	w := httptest.NewRecorder()
+	s.handleDownload(w, req)
+
+	mismatches := s.integrity.data().Mismatches
+	if len(mismatches) != 1 || mismatches[0].Kind != problemCorrupt {
+		t.Fatalf("expected one corrupt mismatch, got %+v", mismatches)
+	}
+
+	// The admin page lists it
+	req = httptest.NewRequest("GET", "/admin/integrity/status", nil)
+	w = httptest.NewRecorder()
+	s.handleAdminIntegrityStatus(w, req)
+	if !strings.Contains(w.Body.String(), "doc-1.pdf") {
+		t.Errorf("expected mismatch in report, got %s", w.Body.String())
+	}
+}
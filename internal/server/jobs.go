@@ -0,0 +1,130 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// jobStatus is a snapshot of a background job, shown on admin pages.
+type jobStatus struct {
+	Running  bool
+	Started  time.Time
+	Finished time.Time
+	Done     int64
+	Total    int64
+	Error    string
+}
+
+// Percent is the share of work completed, for progress bars.
+func (st jobStatus) Percent() int {
+	if st.Total <= 0 {
+		return 0
+	}
+	return int(st.Done * 100 / st.Total)
+}
+
+// jobProgress is handed to a running job to report how far along it is.
+type jobProgress struct {
+	done  atomic.Int64
+	total atomic.Int64
+}
+
+func (p *jobProgress) setTotal(n int64) { p.total.Store(n) }
+func (p *jobProgress) addTotal(n int64) { p.total.Add(n) }
+func (p *jobProgress) add(n int64)      { p.done.Add(n) }
+
+// job runs one long task at a time in the background and tracks its
+// progress. Jobs are stopped when the server's background context is
+// cancelled.
+type job struct {
+	name string
+	srv  *Server
+
+	mu       sync.Mutex
+	running  bool
+	cancel   context.CancelFunc
+	progress *jobProgress
+	status   jobStatus
+}
+
+func newJob(srv *Server, name string) *job {
+	return &job{name: name, srv: srv}
+}
+
+// start runs fn in the background. It returns false if the job is
+// already running.
+func (j *job) start(fn func(ctx context.Context, p *jobProgress) error) bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.running {
+		return false
+	}
+
+	ctx, cancel := context.WithCancel(j.srv.bgCtx)
+	j.running = true
+	j.cancel = cancel
+	j.progress = &jobProgress{}
+	j.status = jobStatus{Running: true, Started: time.Now()}
+
+	progress := j.progress
+	j.srv.bgWG.Add(1)
+	go func() {
+		defer j.srv.bgWG.Done()
+		defer cancel()
+
+		j.srv.logger.Info("background job started", "job", j.name)
+		err := fn(ctx, progress)
+
+		j.mu.Lock()
+		defer j.mu.Unlock()
+		j.running = false
+		j.status.Running = false
+		j.status.Finished = time.Now()
+		j.status.Done = progress.done.Load()
+		j.status.Total = progress.total.Load()
+		switch {
+		case errors.Is(err, context.Canceled):
+			j.status.Error = "cancelled"
+			j.srv.logger.Info("background job cancelled", "job", j.name)
+		case err != nil:
+			j.status.Error = err.Error()
+			j.srv.logger.Error("background job failed", "job", j.name, "error", err)
+		default:
+			j.srv.logger.Info("background job finished", "job", j.name, "duration", j.status.Finished.Sub(j.status.Started))
+		}
+	}()
+
+	return true
+}
+
+// stop cancels the job if it is running.
+func (j *job) stop() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.running {
+		j.cancel()
+	}
+}
+
+// snapshot returns the current status.
+func (j *job) snapshot() jobStatus {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	st := j.status
+	if j.running {
+		st.Done = j.progress.done.Load()
+		st.Total = j.progress.total.Load()
+	}
+	return st
+}
+
+// Close stops all background jobs and waits for them to exit.
+func (s *Server) Close() {
+	s.bgCancel()
+	s.bgWG.Wait()
+}
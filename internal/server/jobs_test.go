@@ -0,0 +1,82 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func waitForJob(t *testing.T, j *job) jobStatus {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if st := j.snapshot(); !st.Running {
+			return st
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("job %q did not finish", j.name)
+	return jobStatus{}
+}
+
+func TestJobLifecycle(t *testing.T) {
+	apiServer := mockAPIServer(t, nil)
+	defer apiServer.Close()
+	s := testServer(t, apiServer)
+
+	j := newJob(s, "test")
+	release := make(chan struct{})
+	started := j.start(func(ctx context.Context, p *jobProgress) error {
+		p.setTotal(4)
+		p.add(1)
+		<-release
+		p.add(3)
+		return nil
+	})
+	if !started {
+		t.Fatal("expected job to start")
+	}
+	if j.start(func(ctx context.Context, p *jobProgress) error { return nil }) {
+		t.Error("expected second start to be rejected while running")
+	}
+
+	close(release)
+	st := waitForJob(t, j)
+	if st.Done != 4 || st.Total != 4 || st.Percent() != 100 || st.Error != "" {
+		t.Errorf("unexpected final status %+v", st)
+	}
+
+	j.start(func(ctx context.Context, p *jobProgress) error { return errors.New("boom") })
+	if st := waitForJob(t, j); st.Error != "boom" {
+		t.Errorf("expected error to be recorded, got %q", st.Error)
+	}
+}
+
+func TestServerCloseCancelsJobs(t *testing.T) {
+	apiServer := mockAPIServer(t, map[string]http.HandlerFunc{})
+	defer apiServer.Close()
+	s := testServer(t, apiServer)
+
+	j := newJob(s, "blocking")
+	j.start(func(ctx context.Context, p *jobProgress) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	done := make(chan struct{})
+	go func() {
+		s.Close()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close did not stop the running job")
+	}
+	if st := j.snapshot(); st.Error != "cancelled" {
+		t.Errorf("expected cancelled status, got %q", st.Error)
+	}
+}
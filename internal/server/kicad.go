@@ -0,0 +1,216 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rmrfslashbin/manuals-webui/internal/client"
+)
+
+// kicadGrid is KiCad's standard 50 mil grid in millimetres. Symbol
+// geometry is worked out in grid units and converted when written.
+const kicadGrid = 2.54
+
+// kicadPinTypes maps pin classes to KiCad electrical types.
+var kicadPinTypes = map[pinClass]string{
+	classPower:   "power_in",
+	classGround:  "power_in",
+	classControl: "input",
+	classNC:      "no_connect",
+	classAnalog:  "input",
+	classGPIO:    "bidirectional",
+	classOther:   "passive",
+}
+
+// kicadSide is the edge of the symbol body a pin is drawn on.
+type kicadSide int
+
+const (
+	sideLeft kicadSide = iota
+	sideRight
+	sideTop
+	sideBottom
+)
+
+// kicadPin is a pin with its place on the symbol.
+type kicadPin struct {
+	pin   client.PinoutPin
+	class pinClass
+	group string // the pin's first function family, for ordering
+	side  kicadSide
+}
+
+// kicadSideFor puts supplies on top and grounds at the bottom, GPIOs on the
+// right, and control, analog and other pins on the left.
+func kicadSideFor(class pinClass) kicadSide {
+	switch class {
+	case classPower:
+		return sideTop
+	case classGround:
+		return sideBottom
+	case classGPIO:
+		return sideRight
+	}
+	return sideLeft
+}
+
+// kicadGroup names the bus a pin's first alternate function belongs to,
+// e.g. "I2C" for "I2C0_SDA", so pins of one bus are drawn together.
+func kicadGroup(pin client.PinoutPin) string {
+	for _, f := range pin.AltFunctions {
+		if tokens := functionTokens(f); len(tokens) > 0 {
+			return trimIndex(tokens[0])
+		}
+	}
+	return ""
+}
+
+// layoutKicadPins assigns each pin to a side and orders every side: by
+// class on the left, by function group on the right, then by physical
+// pin.
+func layoutKicadPins(pins []client.PinoutPin) map[kicadSide][]kicadPin {
+	sides := make(map[kicadSide][]kicadPin)
+	for _, pin := range pins {
+		class := classifyPin(&pin)
+		kp := kicadPin{pin: pin, class: class, side: kicadSideFor(class)}
+		if kp.side == sideRight {
+			kp.group = kicadGroup(pin)
+		} else {
+			kp.group = string(class)
+		}
+		sides[kp.side] = append(sides[kp.side], kp)
+	}
+	for _, side := range sides {
+		sort.SliceStable(side, func(i, j int) bool {
+			if side[i].group != side[j].group {
+				return side[i].group < side[j].group
+			}
+			return side[i].pin.PhysicalPin < side[j].pin.PhysicalPin
+		})
+	}
+	return sides
+}
+
+// kicadString quotes a string for an S-expression.
+func kicadString(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return `"` + strings.ReplaceAll(s, "\n", " ") + `"`
+}
+
+// kicadSymbolName makes a library symbol name from a device name. KiCad
+// reserves ":" and "/" and spaces are awkward in library IDs.
+func kicadSymbolName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', ':', '/', '\\', '"', '\t':
+			return '_'
+		}
+		return r
+	}, strings.TrimSpace(name))
+	if name == "" {
+		return "Device"
+	}
+	return name
+}
+
+// mm formats a position in grid units as millimetres.
+func mm(units float64) string {
+	return strconv.FormatFloat(units*kicadGrid, 'f', -1, 64)
+}
+
+func maxNameLength(pins []kicadPin) int {
+	n := 0
+	for _, p := range pins {
+		n = max(n, len(p.pin.Name))
+	}
+	return n
+}
+
+// writePinoutKicad writes a KiCad 6 symbol library holding one symbol
+// for the device. Pins are numbered by physical pin, placed on the
+// body's sides by function, and list their alternate functions as KiCad
+// pin alternates.
+func writePinoutKicad(w io.Writer, device *client.Device, pinout *client.PinoutResponse) error {
+	sides := layoutKicadPins(pinout.Pins)
+	left, right, top, bottom := sides[sideLeft], sides[sideRight], sides[sideTop], sides[sideBottom]
+
+	// Body size in grid units. Side pins are one unit apart; pin names sit
+	// inside the body at about two characters per unit, and top and bottom
+	// names run vertically.
+	rows := max(len(left), len(right))
+	halfHeight := (rows+1)/2 + 1
+	if len(top) > 0 {
+		halfHeight += (maxNameLength(top) + 1) / 2
+	}
+	if len(bottom) > 0 {
+		halfHeight += (maxNameLength(bottom) + 1) / 2
+	}
+	columns := max(len(top), len(bottom))
+	halfWidth := max(4, (maxNameLength(left)+maxNameLength(right)+3)/4+1, (columns+1)/2+1)
+	const pinLength = 1.0
+
+	name := kicadSymbolName(device.Name)
+	var b strings.Builder
+	b.WriteString("(kicad_symbol_lib (version 20211014) (generator manuals-webui)\n")
+	fmt.Fprintf(&b, "  (symbol %s (in_bom yes) (on_board yes)\n", kicadString(name))
+	properties := []struct{ key, value, at string }{
+		{"Reference", "U", fmt.Sprintf("0 %s 0", mm(float64(halfHeight)+pinLength+1))},
+		{"Value", device.Name, fmt.Sprintf("0 %s 0", mm(-float64(halfHeight)-pinLength-1))},
+		{"Footprint", "", "0 0 0"},
+		{"Datasheet", "", "0 0 0"},
+	}
+	for i, p := range properties {
+		hide := ""
+		if i > 1 {
+			hide = " hide"
+		}
+		fmt.Fprintf(&b, "    (property %s %s (id %d) (at %s)\n      (effects (font (size 1.27 1.27))%s)\n    )\n",
+			kicadString(p.key), kicadString(p.value), i, p.at, hide)
+	}
+
+	fmt.Fprintf(&b, "    (symbol %s\n", kicadString(name+"_0_1"))
+	fmt.Fprintf(&b, "      (rectangle (start %s %s) (end %s %s)\n        (stroke (width 0.254) (type default) (color 0 0 0 0))\n        (fill (type background))\n      )\n",
+		mm(-float64(halfWidth)), mm(float64(halfHeight)), mm(float64(halfWidth)), mm(-float64(halfHeight)))
+	b.WriteString("    )\n")
+
+	fmt.Fprintf(&b, "    (symbol %s\n", kicadString(name+"_1_1"))
+	place := func(pins []kicadPin, at func(i int) (x, y float64), angle int) {
+		for i, p := range pins {
+			x, y := at(i)
+			fmt.Fprintf(&b, "      (pin %s line (at %s %s %d) (length %s)\n", kicadPinTypes[p.class], mm(x), mm(y), angle, mm(pinLength))
+			fmt.Fprintf(&b, "        (name %s (effects (font (size 1.27 1.27))))\n", kicadString(p.pin.Name))
+			fmt.Fprintf(&b, "        (number %s (effects (font (size 1.27 1.27))))\n", kicadString(strconv.Itoa(p.pin.PhysicalPin)))
+			for _, f := range p.pin.AltFunctions {
+				fmt.Fprintf(&b, "        (alternate %s %s line)\n", kicadString(f), kicadPinTypes[p.class])
+			}
+			b.WriteString("      )\n")
+		}
+	}
+	// Side pins start one unit below the top edge; top and bottom pins are
+	// centred
+	firstRow := float64(halfHeight - 1)
+	if len(top) > 0 {
+		firstRow -= float64((maxNameLength(top) + 1) / 2)
+	}
+	place(left, func(i int) (float64, float64) {
+		return -float64(halfWidth) - pinLength, firstRow - float64(i)
+	}, 0)
+	place(right, func(i int) (float64, float64) {
+		return float64(halfWidth) + pinLength, firstRow - float64(i)
+	}, 180)
+	place(top, func(i int) (float64, float64) {
+		return float64(i - len(top)/2), float64(halfHeight) + pinLength
+	}, 270)
+	place(bottom, func(i int) (float64, float64) {
+		return float64(i - len(bottom)/2), -float64(halfHeight) - pinLength
+	}, 90)
+	b.WriteString("    )\n")
+
+	b.WriteString("  )\n)\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,57 @@
+package server
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/rmrfslashbin/manuals-webui/internal/client"
+)
+
+func TestLayoutKicadPins(t *testing.T) {
+	pins := []client.PinoutPin{
+		{PhysicalPin: 1, Name: "VCC"},
+		{PhysicalPin: 2, Name: "GND"},
+		{PhysicalPin: 3, Name: "EN"},
+		{PhysicalPin: 4, GPIONum: gpio(4), Name: "GPIO4", AltFunctions: []string{"UART1_TX"}},
+		{PhysicalPin: 5, GPIONum: gpio(5), Name: "GPIO5", AltFunctions: []string{"I2C0_SDA"}},
+		{PhysicalPin: 6, Name: "NC"},
+		{PhysicalPin: 7, GPIONum: gpio(7), Name: "GPIO7", AltFunctions: []string{"I2C0_SCL"}},
+		{PhysicalPin: 8, Name: "A0", AltFunctions: []string{"ADC0"}},
+	}
+	sides := layoutKicadPins(pins)
+
+	describe := func(side kicadSide) string {
+		var parts []string
+		for _, p := range sides[side] {
+			parts = append(parts, strconv.Itoa(p.pin.PhysicalPin)+":"+kicadPinTypes[p.class])
+		}
+		return strings.Join(parts, " ")
+	}
+	tests := []struct {
+		side     kicadSide
+		expected string
+	}{
+		{sideTop, "1:power_in"},
+		{sideBottom, "2:power_in"},
+		{sideLeft, "8:input 3:input 6:no_connect"},
+		{sideRight, "5:bidirectional 7:bidirectional 4:bidirectional"},
+	}
+	for _, tc := range tests {
+		if got := describe(tc.side); got != tc.expected {
+			t.Errorf("side %d: expected %q, got %q", tc.side, tc.expected, got)
+		}
+	}
+}
+
+func TestKicadNames(t *testing.T) {
+	if got := kicadSymbolName("Pico W: rev 2/3"); got != "Pico_W__rev_2_3" {
+		t.Errorf("unexpected symbol name %q", got)
+	}
+	if got := kicadSymbolName(" "); got != "Device" {
+		t.Errorf("expected a fallback symbol name, got %q", got)
+	}
+	if got := kicadString(`5" \ TFT`); got != `"5\" \\ TFT"` {
+		t.Errorf("unexpected quoted string %s", got)
+	}
+}
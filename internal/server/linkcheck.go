@@ -0,0 +1,455 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/rmrfslashbin/manuals-webui/internal/client"
+)
+
+const (
+	// linkCheckConcurrency is how many links are checked at once.
+	linkCheckConcurrency = 8
+
+	// linkRefsConcurrency is how many devices' refs are listed at once.
+	linkRefsConcurrency = 4
+
+	// linkCheckTimeout bounds each request. Every redirect hop is a new
+	// request with its own timeout, so a chain can take longer in total.
+	linkCheckTimeout = 15 * time.Second
+
+	// linkHostInterval is the minimum time between requests to one host,
+	// so a catalog linking heavily to one vendor doesn't hammer it.
+	linkHostInterval = time.Second
+
+	// maxLinkRedirects is how many redirects are followed before giving up.
+	maxLinkRedirects = 10
+)
+
+// linkSource is a device reference using a link.
+type linkSource struct {
+	DeviceID   string
+	DeviceName string
+	Title      string
+}
+
+// linkResult is the outcome of checking one URL.
+type linkResult struct {
+	URL         string
+	Status      int      // final status code, 0 if no response was received
+	Error       string   // why the check failed, if it did
+	Redirects   []string // each URL redirected to, in order
+	Checked     time.Time
+	LastSuccess time.Time // zero if the link has never worked
+	Sources     []linkSource
+}
+
+// OK reports whether the link ended at a successful response.
+func (r *linkResult) OK() bool {
+	return r.Error == "" && r.Status >= 200 && r.Status < 300
+}
+
+// Problem describes why a broken link failed, for badges.
+func (r *linkResult) Problem() string {
+	if r.Error != "" {
+		return r.Error
+	}
+	return fmt.Sprintf("HTTP %d %s", r.Status, http.StatusText(r.Status))
+}
+
+// linkReport is the result of a link check.
+type linkReport struct {
+	Generated time.Time
+	Broken    int
+	Results   []*linkResult // broken first, then by URL
+
+	byURL map[string]*linkResult
+}
+
+// linksData is passed to the link check admin templates.
+type linksData struct {
+	Status jobStatus
+	Report *linkReport
+}
+
+// linkChecker requests every external reference URL in the catalog and
+// records which ones no longer work.
+type linkChecker struct {
+	*catalogIndex[*linkReport]
+	srv *Server
+
+	// http makes the checks. It must not follow redirects itself, so each
+	// hop can be recorded and rate limited.
+	http         *http.Client
+	timeout      time.Duration
+	hostInterval time.Duration
+
+	// allowPrivate lets checks connect to private, loopback and link-local
+	// addresses. Off by default, since reference URLs come from the catalog
+	// and could otherwise probe the network the server runs in.
+	allowPrivate bool
+}
+
+// errPrivateAddress is returned for connections refused by the link
+// checker's dialer.
+var errPrivateAddress = errors.New("refusing to connect to a private, loopback or link-local address")
+
+func newLinkChecker(s *Server, allowPrivate bool) *linkChecker {
+	lc := &linkChecker{
+		srv:          s,
+		timeout:      linkCheckTimeout,
+		hostInterval: linkHostInterval,
+		allowPrivate: allowPrivate,
+	}
+	// The check runs on the resolved address of every connection, so
+	// neither redirects nor DNS answers can reach a refused address.
+	dialer := &net.Dialer{Timeout: linkCheckTimeout, Control: lc.checkAddress}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+	transport.Proxy = lc.proxy
+	lc.http = &http.Client{
+		Transport:     transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+	lc.catalogIndex = newCatalogIndex(s, "link check", 0, lc.scan)
+	return lc
+}
+
+// schedule checks the links of c's API every interval until the server
+// is closed. Runs that come due while a check is running are skipped.
+func (lc *linkChecker) schedule(c *client.Client, every time.Duration) {
+	lc.srv.bgWG.Add(1)
+	go func() {
+		defer lc.srv.bgWG.Done()
+		ticker := time.NewTicker(every)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if !lc.start(c) {
+					lc.srv.logger.Info("skipping scheduled link check, one is already running")
+				}
+			case <-lc.srv.bgCtx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// data returns the check status and the report for c's API and key, if
+// one has been built. Scheduled checks only replace the default API's.
+func (lc *linkChecker) data(c *client.Client) linksData {
+	return linksData{Status: lc.job.snapshot(), Report: lc.indexFor(c)}
+}
+
+// scan lists every device's references, then checks each distinct URL.
+// The job's total grows from the number of devices to include the links
+// once they are known.
+func (lc *linkChecker) scan(ctx context.Context, c *client.Client, p *jobProgress) (*linkReport, error) {
+	devices, err := lc.srv.devices.get(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	p.setTotal(int64(len(devices.Devices)))
+
+	sources := make(map[string][]linkSource)
+	var mu sync.Mutex
+	var failed int
+	var lastErr error
+	err = eachDevice(ctx, devices.Devices, linkRefsConcurrency, func(device client.Device) {
+		resp, err := c.GetDeviceRefs(device.ID)
+		p.add(1)
+
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case isNotFound(err):
+		case err != nil:
+			failed++
+			lastErr = err
+		default:
+			for _, ref := range resp.References {
+				if ref.URL != "" {
+					sources[ref.URL] = append(sources[ref.URL], linkSource{DeviceID: device.ID, DeviceName: device.Name, Title: ref.Title})
+				}
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	if failed > 0 && failed == len(devices.Devices) {
+		return nil, lastErr
+	}
+	if failed > 0 {
+		lc.srv.logger.Warn("failed to load some device refs", "failed", failed, "error", lastErr)
+	}
+
+	previous := lc.indexFor(c)
+	report := &linkReport{byURL: make(map[string]*linkResult)}
+	urls := make(chan string)
+	var wg sync.WaitGroup
+	limiter := newHostLimiter(lc.hostInterval)
+	p.addTotal(int64(len(sources)))
+	for i := 0; i < linkCheckConcurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for u := range urls {
+				result := lc.check(ctx, limiter, u)
+				p.add(1)
+				if ctx.Err() != nil {
+					continue
+				}
+				result.Sources = sources[u]
+				if result.OK() {
+					result.LastSuccess = result.Checked
+				} else if previous != nil && previous.byURL[u] != nil {
+					result.LastSuccess = previous.byURL[u].LastSuccess
+				}
+
+				mu.Lock()
+				report.byURL[u] = result
+				report.Results = append(report.Results, result)
+				if !result.OK() {
+					report.Broken++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+feed:
+	for u := range sources {
+		select {
+		case urls <- u:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(urls)
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(report.Results, func(i, j int) bool {
+		a, b := report.Results[i], report.Results[j]
+		if a.OK() != b.OK() {
+			return !a.OK()
+		}
+		return a.URL < b.URL
+	})
+	report.Generated = time.Now()
+	return report, nil
+}
+
+// check requests a URL, following redirects by hand so every hop is
+// recorded and rate limited. HEAD is tried first; servers that reject it
+// with an error status are asked again with GET.
+func (lc *linkChecker) check(ctx context.Context, limiter *hostLimiter, rawURL string) *linkResult {
+	result := &linkResult{URL: rawURL}
+	defer func() { result.Checked = time.Now() }()
+
+	target, err := url.Parse(rawURL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		result.Error = "not an http or https URL"
+		return result
+	}
+
+	for hops := 0; ; hops++ {
+		resp, err := lc.request(ctx, limiter, http.MethodHead, target)
+		if err == nil && resp.StatusCode >= 400 {
+			resp, err = lc.request(ctx, limiter, http.MethodGet, target)
+		}
+		if err != nil {
+			result.Error = describeLinkError(err)
+			return result
+		}
+		result.Status = resp.StatusCode
+
+		location := resp.Header.Get("Location")
+		if resp.StatusCode < 300 || resp.StatusCode >= 400 || location == "" {
+			return result
+		}
+		if hops == maxLinkRedirects {
+			result.Error = fmt.Sprintf("more than %d redirects", maxLinkRedirects)
+			return result
+		}
+		next, err := target.Parse(location)
+		if err != nil {
+			result.Error = "invalid redirect to " + location
+			return result
+		}
+		target = next
+		result.Redirects = append(result.Redirects, target.String())
+	}
+}
+
+// checkAddress refuses connections to private, loopback, link-local
+// (including the 169.254.169.254 metadata service) and unspecified
+// addresses unless allowPrivate is set.
+func (lc *linkChecker) checkAddress(network, address string, _ syscall.RawConn) error {
+	if lc.allowPrivate {
+		return nil
+	}
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+	ip := addrPort.Addr().Unmap()
+	if ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsUnspecified() {
+		return errPrivateAddress
+	}
+	return nil
+}
+
+// proxy uses the environment's proxy only when private addresses are
+// allowed. A proxy connects to the target itself, where checkAddress
+// can't see the address.
+func (lc *linkChecker) proxy(r *http.Request) (*url.URL, error) {
+	if !lc.allowPrivate {
+		return nil, nil
+	}
+	return http.ProxyFromEnvironment(r)
+}
+
+// request makes one request with its own timeout, waiting for the host's
+// rate limit first. Only the status and headers are needed, so the body
+// is closed unread.
+func (lc *linkChecker) request(ctx context.Context, limiter *hostLimiter, method string, target *url.URL) (*http.Response, error) {
+	if err := limiter.wait(ctx, target.Host); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, lc.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, method, target.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "manuals-webui link checker")
+	resp, err := lc.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return resp, nil
+}
+
+// describeLinkError shortens transport errors for the report.
+func describeLinkError(err error) string {
+	var urlErr *url.Error
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timed out"
+	case errors.As(err, &urlErr):
+		return urlErr.Err.Error()
+	}
+	return err.Error()
+}
+
+// hostLimiter spaces out requests to each host.
+type hostLimiter struct {
+	interval time.Duration
+
+	mu   sync.Mutex
+	next map[string]time.Time
+}
+
+func newHostLimiter(interval time.Duration) *hostLimiter {
+	return &hostLimiter{interval: interval, next: make(map[string]time.Time)}
+}
+
+// wait blocks until the host may be requested again, reserving the slot.
+func (hl *hostLimiter) wait(ctx context.Context, host string) error {
+	host = strings.ToLower(host)
+	hl.mu.Lock()
+	now := time.Now()
+	slot := hl.next[host]
+	if slot.Before(now) {
+		slot = now
+	}
+	hl.next[host] = slot.Add(hl.interval)
+	hl.mu.Unlock()
+
+	delay := time.Until(slot)
+	if delay <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// deviceReference is a device's reference with the latest check of its
+// link, if any.
+type deviceReference struct {
+	client.Reference
+	Link *linkResult
+}
+
+// Broken reports whether the last check of the reference's link failed.
+func (r deviceReference) Broken() bool {
+	return r.Link != nil && !r.Link.OK()
+}
+
+// handleDeviceReferences lists a device's references, marking links the
+// latest check found broken. It is loaded lazily by the device page.
+func (s *Server) handleDeviceReferences(w http.ResponseWriter, r *http.Request) {
+	apiClient := s.apiClient(r)
+	resp, err := apiClient.GetDeviceRefs(r.PathValue("id"))
+	if err != nil && !isNotFound(err) {
+		s.renderError(w, r, "Failed to get references", err)
+		return
+	}
+
+	var refs []deviceReference
+	if resp != nil {
+		report := s.links.indexFor(apiClient)
+		for _, ref := range resp.References {
+			dr := deviceReference{Reference: ref}
+			if report != nil && ref.URL != "" {
+				dr.Link = report.byURL[ref.URL]
+			}
+			refs = append(refs, dr)
+		}
+	}
+	s.renderPartial(w, "partials/device-references.html", refs)
+}
+
+// Admin handlers
+
+func (s *Server) handleAdminLinks(w http.ResponseWriter, r *http.Request) {
+	s.render(w, "admin-links.html", pageData{
+		Title:   "Links",
+		Content: s.links.data(s.apiClient(r)),
+	})
+}
+
+func (s *Server) handleAdminStartLinks(w http.ResponseWriter, r *http.Request) {
+	apiClient := s.apiClient(r)
+	if !s.links.start(apiClient) {
+		s.renderErrorStatus(w, r, http.StatusConflict, "A link check is already running")
+		return
+	}
+	s.renderPartial(w, "partials/links-report.html", s.links.data(apiClient))
+}
+
+func (s *Server) handleAdminLinksStatus(w http.ResponseWriter, r *http.Request) {
+	s.renderPartial(w, "partials/links-report.html", s.links.data(s.apiClient(r)))
+}
@@ -0,0 +1,317 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rmrfslashbin/manuals-webui/internal/client"
+)
+
+// linkTargetServer stands in for the sites references link to. Requests
+// to /flaky succeed only while ok is set.
+func linkTargetServer(t *testing.T, ok *atomic.Bool) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ok":
+		case "/gone":
+			w.WriteHeader(http.StatusNotFound)
+		case "/moved":
+			http.Redirect(w, r, "/moved-again", http.StatusMovedPermanently)
+		case "/moved-again":
+			http.Redirect(w, r, "/ok", http.StatusFound)
+		case "/nohead":
+			if r.Method == http.MethodHead {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+			}
+		case "/loop":
+			http.Redirect(w, r, "/loop", http.StatusFound)
+		case "/slow":
+			select {
+			case <-time.After(time.Second):
+			case <-r.Context().Done():
+			}
+		case "/flaky":
+			if !ok.Load() {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func TestLinkCheck(t *testing.T) {
+	var ok atomic.Bool
+	target := linkTargetServer(t, &ok)
+	defer target.Close()
+	lc := newLinkChecker(&Server{}, true)
+	lc.timeout = 100 * time.Millisecond
+	limiter := newHostLimiter(0)
+
+	tests := []struct {
+		path      string
+		status    int
+		problem   string
+		redirects int
+	}{
+		{"/ok", 200, "", 0},
+		{"/gone", 404, "HTTP 404 Not Found", 0},
+		{"/moved", 200, "", 2},
+		{"/nohead", 200, "", 0},
+		{"/loop", 302, "more than 10 redirects", 10},
+		{"/slow", 0, "timed out", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			result := lc.check(context.Background(), limiter, target.URL+tt.path)
+			if result.Status != tt.status {
+				t.Errorf("expected status %d, got %d", tt.status, result.Status)
+			}
+			if tt.problem == "" && !result.OK() {
+				t.Errorf("expected link to be OK, got %q", result.Problem())
+			}
+			if tt.problem != "" && (result.OK() || result.Problem() != tt.problem) {
+				t.Errorf("expected problem %q, got %q", tt.problem, result.Problem())
+			}
+			if len(result.Redirects) != tt.redirects {
+				t.Errorf("expected %d redirects, got %v", tt.redirects, result.Redirects)
+			}
+			if result.Checked.IsZero() {
+				t.Error("expected Checked to be set")
+			}
+		})
+	}
+
+	if result := lc.check(context.Background(), limiter, "ftp://example.com/file"); result.Problem() != "not an http or https URL" {
+		t.Errorf("unexpected problem for ftp URL: %q", result.Problem())
+	}
+	if result := lc.check(context.Background(), limiter, target.URL+"/moved"); result.Redirects[1] != target.URL+"/ok" {
+		t.Errorf("expected redirects to be absolute, got %v", result.Redirects)
+	}
+}
+
+func TestLinkCheckRefusesPrivateAddresses(t *testing.T) {
+	var ok atomic.Bool
+	target := linkTargetServer(t, &ok)
+	defer target.Close()
+	lc := newLinkChecker(&Server{}, false)
+	limiter := newHostLimiter(0)
+
+	result := lc.check(context.Background(), limiter, target.URL+"/ok")
+	if result.OK() || !strings.Contains(result.Problem(), "refusing to connect") {
+		t.Errorf("expected the loopback target to be refused, got %q", result.Problem())
+	}
+
+	for _, tt := range []struct {
+		address string
+		refused bool
+	}{
+		{"127.0.0.1:80", true},
+		{"[::1]:80", true},
+		{"10.1.2.3:443", true},
+		{"192.168.0.10:80", true},
+		{"169.254.169.254:80", true},
+		{"[fe80::1]:80", true},
+		{"[::ffff:127.0.0.1]:80", true},
+		{"0.0.0.0:80", true},
+		{"93.184.216.34:443", false},
+		{"[2606:2800:220:1::1]:443", false},
+	} {
+		err := lc.checkAddress("tcp", tt.address, nil)
+		if refused := errors.Is(err, errPrivateAddress); refused != tt.refused {
+			t.Errorf("checkAddress(%s) = %v, want refused %v", tt.address, err, tt.refused)
+		}
+	}
+
+	lc.allowPrivate = true
+	if err := lc.checkAddress("tcp", "169.254.169.254:80", nil); err != nil {
+		t.Errorf("expected private addresses to be allowed, got %v", err)
+	}
+}
+
+func TestLinkCheckIgnoresProxy(t *testing.T) {
+	// A proxy would connect to refused addresses on the checker's behalf
+	t.Setenv("HTTP_PROXY", "http://proxy.example:3128")
+	t.Setenv("HTTPS_PROXY", "http://proxy.example:3128")
+	lc := newLinkChecker(&Server{}, false)
+
+	for _, target := range []string{"http://169.254.169.254/latest/meta-data/", "https://10.1.2.3/datasheet.pdf"} {
+		req := httptest.NewRequest("GET", target, nil)
+		if proxyURL, err := lc.http.Transport.(*http.Transport).Proxy(req); proxyURL != nil || err != nil {
+			t.Errorf("expected %s not to be proxied, got %v %v", target, proxyURL, err)
+		}
+	}
+}
+
+func TestHostLimiter(t *testing.T) {
+	hl := newHostLimiter(50 * time.Millisecond)
+	ctx := context.Background()
+
+	start := time.Now()
+	for range 3 {
+		if err := hl.wait(ctx, "example.com"); err != nil {
+			t.Fatalf("wait failed: %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("expected 3 requests to one host to take at least 100ms, took %v", elapsed)
+	}
+
+	start = time.Now()
+	if err := hl.wait(ctx, "other.example.com"); err != nil {
+		t.Fatalf("wait failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 40*time.Millisecond {
+		t.Errorf("expected other hosts not to wait, took %v", elapsed)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	hl.wait(ctx, "example.com")
+	if err := hl.wait(cancelled, "example.com"); err == nil {
+		t.Error("expected wait to fail once the context is cancelled")
+	}
+}
+
+// linksAPIServer serves devices whose references point at target.
+func linksAPIServer(t *testing.T, target string) *httptest.Server {
+	t.Helper()
+	devices := []client.Device{
+		{ID: "esp32", Name: "ESP32"},
+		{ID: "bme280", Name: "BME280"},
+		{ID: "bare", Name: "Bare"},
+	}
+	refs := map[string]any{
+		"esp32": client.RefsResponse{DeviceID: "esp32", References: []client.Reference{
+			{Type: "datasheet", Title: "Datasheet", URL: target + "/ok"},
+			{Type: "related", Title: "BME280", ID: "bme280"},
+			{Type: "link", Title: "Old forum", URL: target + "/gone"},
+			{Type: "link", Title: "Status page", URL: target + "/flaky"},
+		}},
+		"bme280": client.RefsResponse{DeviceID: "bme280", References: []client.Reference{
+			{Type: "datasheet", Title: "Datasheet", URL: target + "/ok"},
+		}},
+	}
+	api, _ := deviceAPIServer(t, devices, map[string]map[string]any{"refs": refs})
+	return api
+}
+
+func TestLinkScan(t *testing.T) {
+	var ok atomic.Bool
+	target := linkTargetServer(t, &ok)
+	defer target.Close()
+	api := linksAPIServer(t, target.URL)
+	defer api.Close()
+	s := testServer(t, api)
+	defer s.Close()
+	s.links.hostInterval = 0
+	s.links.allowPrivate = true
+
+	run := func() *linkReport {
+		t.Helper()
+		s.links.start(client.New(api.URL, "test-key"))
+		if st := waitForJob(t, s.links.job); st.Error != "" {
+			t.Fatalf("link check failed: %s", st.Error)
+		}
+		return s.links.data(s.client).Report
+	}
+
+	ok.Store(true)
+	first := run()
+	var got []string
+	for _, r := range first.Results {
+		got = append(got, strings.TrimPrefix(r.URL, target.URL))
+	}
+	if strings.Join(got, " ") != "/gone /flaky /ok" {
+		t.Errorf("expected broken links first, got %v", got)
+	}
+	if first.Broken != 1 {
+		t.Errorf("expected 1 broken link, got %d", first.Broken)
+	}
+	if sources := first.byURL[target.URL+"/ok"].Sources; len(sources) != 2 {
+		t.Errorf("expected /ok to be used by 2 devices, got %v", sources)
+	}
+	if !first.byURL[target.URL+"/gone"].LastSuccess.IsZero() {
+		t.Error("expected /gone never to have succeeded")
+	}
+
+	ok.Store(false)
+	second := run()
+	flaky := second.byURL[target.URL+"/flaky"]
+	if flaky.OK() || second.Broken != 2 {
+		t.Fatalf("expected /flaky to be broken on the second run, got %q", flaky.Problem())
+	}
+	if want := first.byURL[target.URL+"/flaky"].LastSuccess; !flaky.LastSuccess.Equal(want) {
+		t.Errorf("expected last success %v to carry over, got %v", want, flaky.LastSuccess)
+	}
+}
+
+func TestHandleLinks(t *testing.T) {
+	var ok atomic.Bool
+	target := linkTargetServer(t, &ok)
+	defer target.Close()
+	api := linksAPIServer(t, target.URL)
+	defer api.Close()
+	s := testServer(t, api)
+	defer s.Close()
+	s.links.hostInterval = 0
+	s.links.allowPrivate = true
+
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		s.Handler().ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		return w
+	}
+
+	w := get("/partials/devices/esp32/references")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), `href="/devices/bme280"`) || strings.Contains(w.Body.String(), "broken") {
+		t.Errorf("expected unchecked references without badges, got:\n%s", w.Body.String())
+	}
+
+	// A check run with another key, e.g. by another session, is not shown
+	s.links.start(client.New(api.URL, "other-key"))
+	waitForJob(t, s.links.job)
+	if body := get("/admin/links").Body.String(); !strings.Contains(body, "No link check has been run yet") {
+		t.Error("expected no report for the session's API key")
+	}
+
+	w = httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, httptest.NewRequest("POST", "/admin/links", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	waitForJob(t, s.links.job)
+
+	w = get("/admin/links")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	for _, want := range []string{"HTTP 404 Not Found", "HTTP 503 Service Unavailable", target.URL + "/gone", "never"} {
+		if !strings.Contains(w.Body.String(), want) {
+			t.Errorf("expected admin page to contain %q", want)
+		}
+	}
+
+	w = get("/partials/devices/esp32/references")
+	if n := strings.Count(w.Body.String(), ">broken<"); n != 2 {
+		t.Errorf("expected 2 broken badges, got %d:\n%s", n, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), `title="HTTP 404 Not Found, last checked`) {
+		t.Error("expected the badge to explain the failure")
+	}
+
+	w = get("/partials/devices/bare/references")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "No references.") {
+		t.Errorf("expected empty references, got %d:\n%s", w.Code, w.Body.String())
+	}
+}
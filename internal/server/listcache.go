@@ -0,0 +1,103 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/rmrfslashbin/manuals-webui/internal/client"
+)
+
+// apiIdentity identifies the API and key c talks to. Results cached under
+// it are never served to a session using another key for the same API,
+// which may not be allowed to see them. The key itself is not kept.
+func apiIdentity(c *client.Client) string {
+	sum := sha256.Sum256([]byte(c.APIKey()))
+	return c.BaseURL() + "\x00" + hex.EncodeToString(sum[:])
+}
+
+// listingCache keeps one loaded value per key, usually an API identity,
+// for a fixed time. Concurrent callers for the same key share a single
+// load; loads for different keys run independently, so one slow API does
+// not hold up pages served from another.
+type listingCache[T any] struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*listingEntry[T]
+}
+
+// listingEntry is a cached value, or a load in progress until done is
+// closed.
+type listingEntry[T any] struct {
+	done   chan struct{}
+	value  T
+	err    error
+	loaded time.Time
+}
+
+func newListingCache[T any](ttl time.Duration) *listingCache[T] {
+	return &listingCache[T]{ttl: ttl, entries: make(map[string]*listingEntry[T])}
+}
+
+// get returns the value for key, calling load if it is missing or older
+// than the TTL. The caller that starts a load runs it to completion even if
+// ctx is cancelled, since others may be waiting for it; ctx only bounds how
+// long those others wait.
+func (lc *listingCache[T]) get(ctx context.Context, key string, load func(context.Context) (T, error)) (T, error) {
+	lc.mu.Lock()
+	e, ok := lc.entries[key]
+	if ok {
+		select {
+		case <-e.done:
+			if time.Since(e.loaded) >= lc.ttl {
+				ok = false
+			}
+		default:
+		}
+	}
+	if !ok {
+		e = &listingEntry[T]{done: make(chan struct{})}
+		lc.entries[key] = e
+	}
+	lc.mu.Unlock()
+
+	if !ok {
+		lc.load(context.WithoutCancel(ctx), key, e, load)
+		return e.value, e.err
+	}
+
+	select {
+	case <-e.done:
+		return e.value, e.err
+	case <-ctx.Done():
+		var zero T
+		return zero, ctx.Err()
+	}
+}
+
+// load fills e and forgets it again if loading failed, so the next caller
+// retries instead of getting the cached error.
+func (lc *listingCache[T]) load(ctx context.Context, key string, e *listingEntry[T], load func(context.Context) (T, error)) {
+	e.value, e.err = load(ctx)
+	e.loaded = time.Now()
+	close(e.done)
+
+	if e.err != nil {
+		lc.mu.Lock()
+		if lc.entries[key] == e {
+			delete(lc.entries, key)
+		}
+		lc.mu.Unlock()
+	}
+}
+
+// invalidate drops every cached value. Loads in progress still complete
+// for the callers waiting on them but are not kept.
+func (lc *listingCache[T]) invalidate() {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	clear(lc.entries)
+}
@@ -0,0 +1,119 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestListingCache(t *testing.T) {
+	lc := newListingCache[int](time.Hour)
+	var loads atomic.Int32
+	load := func(context.Context) (int, error) {
+		return int(loads.Add(1)), nil
+	}
+
+	for i := 0; i < 2; i++ {
+		if v, err := lc.get(context.Background(), "a", load); err != nil || v != 1 {
+			t.Fatalf("get = %d, %v; want 1", v, err)
+		}
+	}
+	if v, _ := lc.get(context.Background(), "b", load); v != 2 {
+		t.Errorf("expected a separate load per key, got %d", v)
+	}
+
+	lc.invalidate()
+	if v, _ := lc.get(context.Background(), "a", load); v != 3 {
+		t.Errorf("expected a fresh load after invalidate, got %d", v)
+	}
+
+	// Values expire after the TTL
+	lc.ttl = 0
+	if v, _ := lc.get(context.Background(), "a", load); v != 4 {
+		t.Errorf("expected a fresh load after the TTL, got %d", v)
+	}
+}
+
+func TestListingCacheErrorsAreNotKept(t *testing.T) {
+	lc := newListingCache[string](time.Hour)
+	fail := true
+	load := func(context.Context) (string, error) {
+		if fail {
+			return "", errors.New("API down")
+		}
+		return "ok", nil
+	}
+
+	if _, err := lc.get(context.Background(), "a", load); err == nil {
+		t.Fatal("expected the load error")
+	}
+	fail = false
+	if v, err := lc.get(context.Background(), "a", load); err != nil || v != "ok" {
+		t.Errorf("expected a retry after an error, got %q, %v", v, err)
+	}
+}
+
+func TestListingCacheConcurrency(t *testing.T) {
+	lc := newListingCache[string](time.Hour)
+	release := make(chan struct{})
+	started := make(chan struct{})
+	var slowLoads atomic.Int32
+	slow := func(context.Context) (string, error) {
+		slowLoads.Add(1)
+		close(started)
+		<-release
+		return "slow", nil
+	}
+
+	var wg sync.WaitGroup
+	results := make([]string, 5)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		results[0], _ = lc.get(context.Background(), "slow", slow)
+	}()
+	<-started
+	for i := 1; i < len(results); i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], _ = lc.get(context.Background(), "slow", slow)
+		}(i)
+	}
+
+	// Another API is not held up by the slow one
+	done := make(chan string)
+	go func() {
+		v, _ := lc.get(context.Background(), "fast", func(context.Context) (string, error) { return "fast", nil })
+		done <- v
+	}()
+	select {
+	case v := <-done:
+		if v != "fast" {
+			t.Errorf("expected fast, got %q", v)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("a load for another key waited for the slow one")
+	}
+
+	// A waiter can give up without affecting the load
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := lc.get(ctx, "slow", slow); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the waiter's context error, got %v", err)
+	}
+
+	close(release)
+	wg.Wait()
+	for i, v := range results {
+		if v != "slow" {
+			t.Errorf("caller %d got %q", i, v)
+		}
+	}
+	if n := slowLoads.Load(); n != 1 {
+		t.Errorf("expected concurrent callers to share one load, got %d", n)
+	}
+}
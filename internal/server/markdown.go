@@ -4,15 +4,22 @@ import (
 	"bytes"
 	"html"
 	"html/template"
+	"regexp"
 	"strings"
 
 	"github.com/microcosm-cc/bluemonday"
 	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
 	"github.com/yuin/goldmark/extension"
 	"github.com/yuin/goldmark/parser"
 	goldmarkhtml "github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/text"
 )
 
+// headingAnchorClass marks the "copy link to section" anchors added to
+// headings by RenderWithTOC.
+const headingAnchorClass = "heading-anchor"
+
 // MarkdownRenderer provides markdown to HTML conversion with sanitization.
 type MarkdownRenderer struct {
 	goldmark  goldmark.Markdown
@@ -24,11 +31,12 @@ func newMarkdownRenderer() *MarkdownRenderer {
 	// Configure Goldmark with GitHub Flavored Markdown extensions
 	md := goldmark.New(
 		goldmark.WithExtensions(
-			extension.GFM,          // GitHub Flavored Markdown
-			extension.Table,        // Tables
+			extension.GFM,           // GitHub Flavored Markdown
+			extension.Table,         // Tables
 			extension.Strikethrough, // ~~strikethrough~~
-			extension.TaskList,     // - [ ] task lists
-			extension.Linkify,      // Auto-link URLs
+			extension.TaskList,      // - [ ] task lists
+			extension.Linkify,       // Auto-link URLs
+			newHighlighting(),       // Syntax highlighting for fenced code
 		),
 		goldmark.WithParserOptions(
 			parser.WithAutoHeadingID(), // Auto-generate heading IDs for anchoring
@@ -48,6 +56,11 @@ func newMarkdownRenderer() *MarkdownRenderer {
 	policy.RequireNoReferrerOnLinks(true)
 	policy.AllowRelativeURLs(true)
 	policy.AddTargetBlankToFullyQualifiedLinks(true)
+	policy.AllowAttrs("class").Matching(regexp.MustCompile("^" + headingAnchorClass + "$")).OnElements("a")
+
+	// Highlighted code blocks are styled by class, see highlightCSS
+	policy.AllowAttrs("class").Matching(regexp.MustCompile("^chroma$")).OnElements("pre")
+	policy.AllowAttrs("class").Matching(highlightClassPattern).OnElements("span")
 
 	return &MarkdownRenderer{
 		goldmark:  md,
@@ -102,3 +115,92 @@ func (mr *MarkdownRenderer) RenderMarkdownInline(content string) template.HTML {
 
 	return template.HTML(strings.TrimSpace(str))
 }
+
+// Heading is an entry in a document's table of contents.
+type Heading struct {
+	Level    int
+	ID       string
+	Text     string
+	Children []*Heading
+}
+
+// RenderWithTOC converts markdown content to safe HTML like RenderMarkdown,
+// and also returns its headings as a tree. Each heading gets an anchor
+// linking to itself, for copying a link to the section.
+func (mr *MarkdownRenderer) RenderWithTOC(content string) (template.HTML, []*Heading) {
+	if content == "" {
+		return template.HTML(""), nil
+	}
+
+	source := []byte(content)
+	doc := mr.goldmark.Parser().Parse(text.NewReader(source))
+
+	var headings []*ast.Heading
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if h, ok := n.(*ast.Heading); ok && entering {
+			headings = append(headings, h)
+			return ast.WalkSkipChildren, nil
+		}
+		return ast.WalkContinue, nil
+	})
+
+	var toc []*Heading
+	var open []*Heading // the most recent heading at each depth
+	for _, h := range headings {
+		id, ok := h.AttributeString("id")
+		if !ok {
+			continue
+		}
+		entry := &Heading{Level: h.Level, ID: string(id.([]byte)), Text: plainText(h, source)}
+
+		// Nest under the nearest preceding heading of a higher level
+		for len(open) > 0 && open[len(open)-1].Level >= entry.Level {
+			open = open[:len(open)-1]
+		}
+		if len(open) == 0 {
+			toc = append(toc, entry)
+		} else {
+			parent := open[len(open)-1]
+			parent.Children = append(parent.Children, entry)
+		}
+		open = append(open, entry)
+
+		anchor := ast.NewLink()
+		anchor.Destination = []byte("#" + entry.ID)
+		anchor.Title = []byte("Copy link to section")
+		anchor.SetAttributeString("class", []byte(headingAnchorClass))
+		anchor.AppendChild(anchor, ast.NewString([]byte("#")))
+		h.AppendChild(h, ast.NewString([]byte(" ")))
+		h.AppendChild(h, anchor)
+	}
+
+	var buf bytes.Buffer
+	if err := mr.goldmark.Renderer().Render(&buf, source, doc); err != nil {
+		return template.HTML("<p>" + html.EscapeString(content) + "</p>"), nil
+	}
+	return template.HTML(mr.sanitizer.Sanitize(buf.String())), toc
+}
+
+// plainText returns the text of an inline node's children without any
+// markup, for headings in a table of contents.
+func plainText(n ast.Node, source []byte) string {
+	var sb strings.Builder
+	ast.Walk(n, func(c ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch c := c.(type) {
+		case *ast.Text:
+			sb.Write(c.Segment.Value(source))
+			if c.SoftLineBreak() || c.HardLineBreak() {
+				sb.WriteByte(' ')
+			}
+		case *ast.String:
+			sb.Write(c.Value)
+		case *ast.AutoLink:
+			sb.Write(c.Label(source))
+		}
+		return ast.WalkContinue, nil
+	})
+	return strings.Join(strings.Fields(sb.String()), " ")
+}
@@ -0,0 +1,68 @@
+package server
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestRenderWithTOC(t *testing.T) {
+	mr := newMarkdownRenderer()
+	content := strings.Join([]string{
+		"# ESP32 DevKit",
+		"Intro.",
+		"## Power",
+		"### Pins `3V3` and *VIN*",
+		"## Power",
+		"#### Skipped level",
+		"# Links <script>alert(1)</script>",
+	}, "\n\n")
+
+	html, toc := mr.RenderWithTOC(content)
+
+	var got []string
+	var walk func(hs []*Heading, depth int)
+	walk = func(hs []*Heading, depth int) {
+		for _, h := range hs {
+			got = append(got, fmt.Sprintf("%s%d %s %s", strings.Repeat("  ", depth), h.Level, h.ID, h.Text))
+			walk(h.Children, depth+1)
+		}
+	}
+	walk(toc, 0)
+	expected := []string{
+		"1 esp32-devkit ESP32 DevKit",
+		"  2 power Power",
+		"    3 pins-3v3-and-vin Pins 3V3 and VIN",
+		"  2 power-1 Power",
+		"    4 skipped-level Skipped level",
+		"1 links-scriptalert1script Links alert(1)",
+	}
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("unexpected outline:\n%s", strings.Join(got, "\n"))
+	}
+
+	for _, want := range []string{
+		`<h2 id="power">Power <a href="#power" title="Copy link to section" class="heading-anchor" rel="nofollow noreferrer">#</a></h2>`,
+		`<h3 id="pins-3v3-and-vin">Pins <code>3V3</code> and <em>VIN</em> <a href="#pins-3v3-and-vin"`,
+		`<h2 id="power-1">`,
+	} {
+		if !strings.Contains(string(html), want) {
+			t.Errorf("expected HTML to contain %q, got:\n%s", want, html)
+		}
+	}
+	if strings.Contains(string(html), "<script>") {
+		t.Error("expected HTML to be sanitized")
+	}
+
+	if html, toc := mr.RenderWithTOC(""); html != "" || toc != nil {
+		t.Errorf("expected nothing for empty content, got %q %v", html, toc)
+	}
+}
+
+func TestSanitizerClasses(t *testing.T) {
+	mr := newMarkdownRenderer()
+	html := mr.sanitizer.Sanitize(`<a href="#x" class="heading-anchor evil">x</a> <a href="#y" class="heading-anchor">y</a> <p class="heading-anchor">z</p>`)
+	if strings.Contains(html, "evil") || strings.Count(html, "class=") != 1 {
+		t.Errorf("expected only exact heading anchor classes on links to survive, got %s", html)
+	}
+}
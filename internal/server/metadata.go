@@ -0,0 +1,314 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Kinds of metadata value, used by the metadata tree template to pick how
+// a value is shown.
+const (
+	metaObject = "object"
+	metaList   = "list"
+	metaText   = "text"
+	metaURL    = "url"
+	metaDate   = "date"
+	metaNumber = "number"
+	metaBool   = "bool"
+	metaNull   = "null"
+)
+
+// metadataDateLayouts are the date formats recognized in string values.
+// Date-only layouts come last so they don't shadow a time of day.
+var metadataDateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// metadataNumberPattern matches the decimal numbers recognized in string
+// values. strconv.ParseFloat alone also accepts hex, "Inf" and "NaN".
+var metadataNumberPattern = regexp.MustCompile(`^[-+]?(\d+\.?\d*|\.\d+)([eE][-+]?\d+)?$`)
+
+// metadataNode is one value in a device's metadata, with its children if
+// it is an object or list.
+type metadataNode struct {
+	Key      string // map key, list index, or path for promoted values
+	Path     string // dotted path from the top of the metadata
+	Kind     string
+	Text     string    // scalars as displayed
+	Time     time.Time // dates
+	JSON     string    // the value as indented JSON, for copying
+	Children []metadataNode
+}
+
+// IsTree reports whether the node has children to expand.
+func (n metadataNode) IsTree() bool {
+	return n.Kind == metaObject || n.Kind == metaList
+}
+
+// Summary describes an object or list while it is collapsed.
+func (n metadataNode) Summary() string {
+	switch n.Kind {
+	case metaObject:
+		return plural(len(n.Children), "key")
+	case metaList:
+		return plural(len(n.Children), "item")
+	}
+	return n.Text
+}
+
+func plural(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+// metadataView is a device's metadata prepared for the device page.
+type metadataView struct {
+	Promoted []metadataNode // configured keys the device has, in order
+	Tree     []metadataNode // every top-level key, sorted
+	JSON     string
+}
+
+// newMetadataView builds the tree for a device's metadata, pulling out
+// the promoted key paths it has. It returns nil if there is no metadata.
+func newMetadataView(meta map[string]interface{}, promoted []string) *metadataView {
+	if len(meta) == 0 {
+		return nil
+	}
+	root := newMetadataNode("", "", meta)
+	view := &metadataView{Tree: root.Children, JSON: root.JSON}
+	for _, path := range promoted {
+		if v, ok := lookupMetadata(meta, path); ok {
+			view.Promoted = append(view.Promoted, newMetadataNode(path, path, v))
+		}
+	}
+	return view
+}
+
+func newMetadataNode(key, path string, v interface{}) metadataNode {
+	n := metadataNode{Key: key, Path: path}
+	if b, err := json.MarshalIndent(v, "", "  "); err == nil {
+		n.JSON = string(b)
+	}
+
+	child := func(k string) string {
+		if path == "" {
+			return k
+		}
+		return path + "." + k
+	}
+	switch v := v.(type) {
+	case map[string]interface{}:
+		n.Kind = metaObject
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			n.Children = append(n.Children, newMetadataNode(k, child(k), v[k]))
+		}
+	case []interface{}:
+		n.Kind = metaList
+		for i, item := range v {
+			k := strconv.Itoa(i)
+			n.Children = append(n.Children, newMetadataNode(k, child(k), item))
+		}
+	case nil:
+		n.Kind, n.Text = metaNull, "null"
+	case bool:
+		n.Kind, n.Text = metaBool, strconv.FormatBool(v)
+	case float64:
+		n.Kind, n.Text = metaNumber, formatMetadataNumber(v)
+	case json.Number:
+		n.Kind, n.Text = metaNumber, v.String()
+	case string:
+		n.Kind, n.Text = classifyMetadataString(v)
+		if n.Kind == metaDate {
+			n.Time, _ = parseMetadataDate(v)
+		}
+	default:
+		n.Kind, n.Text = metaText, fmt.Sprint(v)
+	}
+	return n
+}
+
+// classifyMetadataString detects URLs, dates and numbers written as
+// strings. Anything else is shown as text.
+func classifyMetadataString(s string) (kind, text string) {
+	trimmed := strings.TrimSpace(s)
+	if u, err := url.Parse(trimmed); err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "" {
+		return metaURL, trimmed
+	}
+	if t, ok := parseMetadataDate(trimmed); ok {
+		if len(trimmed) == len("2006-01-02") {
+			return metaDate, t.Format("2006-01-02")
+		}
+		return metaDate, t.Format("2006-01-02 15:04")
+	}
+	if metadataNumberPattern.MatchString(trimmed) {
+		return metaNumber, trimmed
+	}
+	return metaText, s
+}
+
+func parseMetadataDate(s string) (time.Time, bool) {
+	for _, layout := range metadataDateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// formatMetadataNumber writes whole numbers without a decimal point or
+// exponent, the way they usually appear in the source YAML.
+func formatMetadataNumber(f float64) string {
+	if f == math.Trunc(f) && math.Abs(f) < 1e15 {
+		return strconv.FormatInt(int64(f), 10)
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// lookupMetadata finds the value at a dotted path such as "power.vin" or
+// "interfaces.0". List elements are addressed by index.
+func lookupMetadata(meta map[string]interface{}, path string) (interface{}, bool) {
+	var v interface{} = meta
+	for _, key := range strings.Split(path, ".") {
+		switch c := v.(type) {
+		case map[string]interface{}:
+			next, ok := c[key]
+			if !ok {
+				return nil, false
+			}
+			v = next
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(c) {
+				return nil, false
+			}
+			v = c[i]
+		default:
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// parseMetadataKeys reads promoted key paths from a form, one per line or
+// separated by commas. Blank entries and repeats are dropped.
+func parseMetadataKeys(s string) ([]string, error) {
+	seen := make(map[string]bool)
+	var keys []string
+	for _, key := range strings.FieldsFunc(s, func(r rune) bool { return r == '\n' || r == '\r' || r == ',' }) {
+		key = strings.TrimSpace(key)
+		if key == "" || seen[key] {
+			continue
+		}
+		for _, part := range strings.Split(key, ".") {
+			if strings.TrimSpace(part) == "" {
+				return nil, fmt.Errorf("invalid key path %q", key)
+			}
+		}
+		seen[key] = true
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// metadataSettings holds the metadata keys promoted to the device page
+// header. Admins can change them at runtime.
+type metadataSettings struct {
+	// save, if set, persists changed keys so they survive a restart.
+	save func([]string) error
+
+	// saveMu serialises set, so the saved and the promoted keys are
+	// always from the same request.
+	saveMu sync.Mutex
+
+	mu   sync.RWMutex
+	keys []string
+}
+
+func (ms *metadataSettings) promoted() []string {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	return ms.keys
+}
+
+// set replaces the promoted keys, saving them first if possible. Key
+// slices are never modified once set, so callers may keep them.
+func (ms *metadataSettings) set(keys []string) error {
+	ms.saveMu.Lock()
+	defer ms.saveMu.Unlock()
+	if ms.save != nil {
+		if err := ms.save(keys); err != nil {
+			return err
+		}
+	}
+	ms.mu.Lock()
+	ms.keys = keys
+	ms.mu.Unlock()
+	return nil
+}
+
+// metadataKeysData is passed to the metadata admin templates.
+type metadataKeysData struct {
+	Keys       string // one per line
+	Persistent bool   // whether changes survive a restart
+	Saved      bool
+	Error      string
+}
+
+func (s *Server) metadataKeysData() metadataKeysData {
+	return metadataKeysData{
+		Keys:       strings.Join(s.metadata.promoted(), "\n"),
+		Persistent: s.metadata.save != nil,
+	}
+}
+
+// Admin handlers
+
+func (s *Server) handleAdminMetadata(w http.ResponseWriter, r *http.Request) {
+	s.render(w, "admin-metadata.html", pageData{
+		Title:   "Metadata",
+		Content: s.metadataKeysData(),
+	})
+}
+
+func (s *Server) handleAdminUpdateMetadata(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		s.renderErrorStatus(w, r, http.StatusBadRequest, "Failed to parse form")
+		return
+	}
+
+	data := s.metadataKeysData()
+	keys, err := parseMetadataKeys(r.FormValue("keys"))
+	if err == nil {
+		err = s.metadata.set(keys)
+	}
+	if err != nil {
+		data.Keys = r.FormValue("keys")
+		data.Error = err.Error()
+		s.renderPartial(w, "partials/metadata-keys.html", data)
+		return
+	}
+
+	data = s.metadataKeysData()
+	data.Saved = true
+	s.renderPartial(w, "partials/metadata-keys.html", data)
+}
@@ -0,0 +1,224 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/rmrfslashbin/manuals-webui/internal/client"
+)
+
+func metadataFixture(t *testing.T) map[string]interface{} {
+	t.Helper()
+	var meta map[string]interface{}
+	err := json.Unmarshal([]byte(`{
+		"vendor": "Espressif",
+		"datasheet": "https://espressif.com/esp32.pdf",
+		"released": "2016-09-06",
+		"power": {"vin": 5, "current_ma": "240", "sleep": null},
+		"interfaces": ["i2c", {"name": "spi", "count": 4}],
+		"wifi": true
+	}`), &meta)
+	if err != nil {
+		t.Fatalf("invalid fixture: %v", err)
+	}
+	return meta
+}
+
+func TestNewMetadataView(t *testing.T) {
+	view := newMetadataView(metadataFixture(t), []string{"vendor", "power.vin", "interfaces.1.name", "missing"})
+
+	var got []string
+	var walk func(nodes []metadataNode)
+	walk = func(nodes []metadataNode) {
+		for _, n := range nodes {
+			got = append(got, n.Path+" "+n.Kind+" "+n.Summary())
+			walk(n.Children)
+		}
+	}
+	walk(view.Tree)
+	expected := []string{
+		"datasheet url https://espressif.com/esp32.pdf",
+		"interfaces list 2 items",
+		"interfaces.0 text i2c",
+		"interfaces.1 object 2 keys",
+		"interfaces.1.count number 4",
+		"interfaces.1.name text spi",
+		"power object 3 keys",
+		"power.current_ma number 240",
+		"power.sleep null null",
+		"power.vin number 5",
+		"released date 2016-09-06",
+		"vendor text Espressif",
+		"wifi bool true",
+	}
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("unexpected tree:\n%s", strings.Join(got, "\n"))
+	}
+
+	var promoted []string
+	for _, n := range view.Promoted {
+		promoted = append(promoted, n.Key+"="+n.Text)
+	}
+	if strings.Join(promoted, " ") != "vendor=Espressif power.vin=5 interfaces.1.name=spi" {
+		t.Errorf("unexpected promoted keys %v", promoted)
+	}
+
+	if view.Tree[1].Children[1].JSON != "{\n  \"count\": 4,\n  \"name\": \"spi\"\n}" {
+		t.Errorf("unexpected JSON %q", view.Tree[1].Children[1].JSON)
+	}
+	if newMetadataView(nil, []string{"vendor"}) != nil {
+		t.Error("expected no view without metadata")
+	}
+}
+
+func TestClassifyMetadataString(t *testing.T) {
+	tests := []struct {
+		input string
+		kind  string
+		text  string
+	}{
+		{"https://example.com/a?b=c", metaURL, "https://example.com/a?b=c"},
+		{"ftp://example.com", metaText, "ftp://example.com"},
+		{"2024-03-01", metaDate, "2024-03-01"},
+		{"2024-03-01T10:30:00Z", metaDate, "2024-03-01 10:30"},
+		{"2024-03-01 10:30", metaDate, "2024-03-01 10:30"},
+		{"3.3", metaNumber, "3.3"},
+		{"-40", metaNumber, "-40"},
+		{"1e6", metaNumber, "1e6"},
+		{"0x40", metaText, "0x40"},
+		{"NaN", metaText, "NaN"},
+		{"3.3V", metaText, "3.3V"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			kind, text := classifyMetadataString(tt.input)
+			if kind != tt.kind || text != tt.text {
+				t.Errorf("classifyMetadataString(%q) = %s %q, want %s %q", tt.input, kind, text, tt.kind, tt.text)
+			}
+		})
+	}
+}
+
+func TestFormatMetadataNumber(t *testing.T) {
+	tests := map[float64]string{
+		5:       "5",
+		-40:     "-40",
+		3.3:     "3.3",
+		240e6:   "240000000",
+		1e20:    "1e+20",
+		0.00001: "1e-05",
+	}
+	for input, want := range tests {
+		if got := formatMetadataNumber(input); got != want {
+			t.Errorf("formatMetadataNumber(%v) = %q, want %q", input, got, want)
+		}
+	}
+}
+
+func TestParseMetadataKeys(t *testing.T) {
+	keys, err := parseMetadataKeys("vendor\r\npower.vin, package\n\nvendor\n")
+	if err != nil {
+		t.Fatalf("parseMetadataKeys failed: %v", err)
+	}
+	if strings.Join(keys, " ") != "vendor power.vin package" {
+		t.Errorf("unexpected keys %v", keys)
+	}
+	if _, err := parseMetadataKeys("power..vin"); err == nil {
+		t.Error("expected an error for an empty path segment")
+	}
+}
+
+func TestHandleDeviceMetadata(t *testing.T) {
+	meta := metadataFixture(t)
+	api := mockAPIServer(t, map[string]http.HandlerFunc{
+		"/api/" + client.APIVersion + "/devices/esp32": func(w http.ResponseWriter, r *http.Request) {
+			if strings.HasSuffix(r.URL.Path, "/esp32") {
+				json.NewEncoder(w).Encode(client.Device{ID: "esp32", Name: "ESP32", Metadata: meta})
+				return
+			}
+			w.WriteHeader(http.StatusNotFound)
+		},
+	})
+	defer api.Close()
+	s := testServer(t, api)
+	s.metadata.set([]string{"vendor", "power"})
+
+	w := httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/devices/esp32", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	body := w.Body.String()
+	for _, want := range []string{
+		`<span class="text-gray-500 dark:text-gray-400">vendor:</span>`,
+		`href="https://espressif.com/esp32.pdf"`,
+		`<time datetime="2016-09-06T00:00:00Z"`,
+		`<summary`,
+		`3 keys`,
+		`data-copy="{ &#34;count&#34;: 4,`,
+		"Copy as JSON",
+	} {
+		if !strings.Contains(strings.Join(strings.Fields(body), " "), want) {
+			t.Errorf("expected device page to contain %q", want)
+		}
+	}
+	if strings.Contains(body, "map[") {
+		t.Error("expected no Go map syntax on the device page")
+	}
+}
+
+func TestHandleAdminMetadata(t *testing.T) {
+	api := mockAPIServer(t, map[string]http.HandlerFunc{
+		"/api/" + client.APIVersion + "/admin/settings": func(w http.ResponseWriter, r *http.Request) {
+			json.NewEncoder(w).Encode(client.SettingsResponse{})
+		},
+	})
+	defer api.Close()
+	s := testServer(t, api)
+
+	var saved []string
+	s.metadata.save = func(keys []string) error {
+		if len(keys) > 2 {
+			return errors.New("disk full")
+		}
+		saved = keys
+		return nil
+	}
+	post := func(keys string) *httptest.ResponseRecorder {
+		form := url.Values{"keys": {keys}}
+		req := httptest.NewRequest("POST", "/admin/metadata", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		s.Handler().ServeHTTP(w, req)
+		return w
+	}
+
+	w := post("vendor\npower.vin")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Promoted keys saved.") {
+		t.Fatalf("expected keys to be saved, got %d:\n%s", w.Code, w.Body.String())
+	}
+	if strings.Join(saved, ",") != "vendor,power.vin" || strings.Join(s.metadata.promoted(), ",") != "vendor,power.vin" {
+		t.Errorf("unexpected keys saved %v, promoted %v", saved, s.metadata.promoted())
+	}
+
+	for _, keys := range []string{"a..b", "a\nb\nc"} {
+		w = post(keys)
+		if !strings.Contains(w.Body.String(), "text-red-700") {
+			t.Errorf("expected an error for %q", keys)
+		}
+		if strings.Join(s.metadata.promoted(), ",") != "vendor,power.vin" {
+			t.Errorf("expected keys unchanged after %q, got %v", keys, s.metadata.promoted())
+		}
+	}
+
+	w = httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/admin/metadata", nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "vendor\npower.vin</textarea>") {
+		t.Errorf("expected the form to show the current keys, got %d", w.Code)
+	}
+}
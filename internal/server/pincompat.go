@@ -0,0 +1,341 @@
+package server
+
+import (
+	"math"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/rmrfslashbin/manuals-webui/internal/client"
+)
+
+// maxReplacements caps the ranked replacements shown on a device page.
+const maxReplacements = 10
+
+// pinClass is the broad role of a pin. Pins of different classes can't
+// stand in for each other.
+type pinClass string
+
+const (
+	classPower   pinClass = "power"
+	classGround  pinClass = "ground"
+	classControl pinClass = "control"
+	classNC      pinClass = "nc"
+	classAnalog  pinClass = "analog"
+	classGPIO    pinClass = "gpio"
+	classOther   pinClass = "other"
+)
+
+// pinClassNames maps compacted pin names to the classes they identify.
+var pinClassNames = map[string]pinClass{
+	"GND": classGround, "VSS": classGround, "AGND": classGround, "DGND": classGround, "GROUND": classGround,
+	"VCC": classPower, "VDD": classPower, "VIN": classPower, "VBAT": classPower, "VBUS": classPower,
+	"3V3": classPower, "5V": classPower, "VSYS": classPower, "AVDD": classPower, "IOREF": classPower,
+	"EN": classControl, "RST": classControl, "RESET": classControl, "NRST": classControl, "CHIPPU": classControl, "RUN": classControl, "BOOT": classControl,
+	"NC": classNC,
+}
+
+// classifyPin works out a pin's class from its name, GPIO number and
+// alternate functions.
+func classifyPin(pin *client.PinoutPin) pinClass {
+	if class, ok := pinClassNames[compactPinName(pin.Name)]; ok {
+		return class
+	}
+	name := strings.ToUpper(pin.Name)
+	switch {
+	case strings.HasPrefix(name, "GND"):
+		return classGround
+	case strings.HasPrefix(name, "VDD") || strings.HasPrefix(name, "VCC"):
+		return classPower
+	}
+	if pin.GPIONum != nil {
+		return classGPIO
+	}
+	for _, f := range append([]string{pin.Name}, pin.AltFunctions...) {
+		family := functionFamily(functionTokens(f))
+		switch {
+		case family == "":
+			return classGPIO
+		case strings.HasPrefix(family, "ADC") || strings.HasPrefix(family, "A_") || family == "A" || family == "AIN":
+			return classAnalog
+		}
+	}
+	if len(pin.AltFunctions) > 0 {
+		return classGPIO
+	}
+	return classOther
+}
+
+// voltageName matches voltages written with a decimal point, e.g. "3.3V".
+var voltageName = regexp.MustCompile(`(\d+)\.(\d+)[vV]`)
+
+// compactPinName upper-cases a pin name and drops punctuation, so "3.3V",
+// "3V3" and "+3V3" compare equal.
+func compactPinName(name string) string {
+	name = voltageName.ReplaceAllString(name, "${1}V$2")
+	return strings.Join(functionTokens(name), "")
+}
+
+// pinFunctionSet is the set of function families a pin offers.
+func pinFunctionSet(pin *client.PinoutPin) map[string]bool {
+	set := make(map[string]bool)
+	for _, f := range append([]string{pin.Name}, pin.AltFunctions...) {
+		if family := functionFamily(functionTokens(f)); family != "" {
+			set[family] = true
+		}
+	}
+	return set
+}
+
+// pinDiffStatus summarises how a pair of pins compares.
+type pinDiffStatus string
+
+const (
+	diffSame       pinDiffStatus = "same"       // same name and functions
+	diffCompatible pinDiffStatus = "compatible" // same class, most functions shared
+	diffPartial    pinDiffStatus = "partial"    // same class, few functions shared
+	diffDifferent  pinDiffStatus = "different"  // different class
+	diffConflict   pinDiffStatus = "conflict"   // power or ground against anything else
+	diffMissing    pinDiffStatus = "missing"    // only one board has the pin
+)
+
+// pinDiff compares the pins at one physical position.
+type pinDiff struct {
+	Physical int
+	A, B     *client.PinoutPin // nil if the board has no such pin
+	Status   pinDiffStatus
+	Score    float64
+	Shared   []string // function families on both pins
+	OnlyA    []string
+	OnlyB    []string
+}
+
+// pinCompatibility is the result of comparing two pinouts.
+type pinCompatibility struct {
+	Score     int // 0 to 100
+	Pins      []pinDiff
+	Same      int
+	Conflicts int
+	Missing   int
+}
+
+// comparePinouts compares two pinouts pin by pin, aligned by physical pin
+// number. Each position scores up to 1: a quarter for the same class, half
+// for the share of function families in common and a quarter for the same
+// name; power, ground and control pins are compared by name. Positions only
+// one board has score 0, as do power or ground pins facing anything else.
+func comparePinouts(a, b *client.PinoutResponse) *pinCompatibility {
+	byPhysical := func(p *client.PinoutResponse) map[int]*client.PinoutPin {
+		m := make(map[int]*client.PinoutPin)
+		if p != nil {
+			for i := range p.Pins {
+				m[p.Pins[i].PhysicalPin] = &p.Pins[i]
+			}
+		}
+		return m
+	}
+	pinsA, pinsB := byPhysical(a), byPhysical(b)
+
+	var positions []int
+	for n := range pinsA {
+		positions = append(positions, n)
+	}
+	for n := range pinsB {
+		if pinsA[n] == nil {
+			positions = append(positions, n)
+		}
+	}
+	sort.Ints(positions)
+
+	c := &pinCompatibility{}
+	var total float64
+	for _, n := range positions {
+		d := comparePins(pinsA[n], pinsB[n])
+		d.Physical = n
+		switch d.Status {
+		case diffSame:
+			c.Same++
+		case diffConflict:
+			c.Conflicts++
+		case diffMissing:
+			c.Missing++
+		}
+		total += d.Score
+		c.Pins = append(c.Pins, d)
+	}
+	if len(positions) > 0 {
+		c.Score = int(math.Round(total / float64(len(positions)) * 100))
+	}
+	return c
+}
+
+func comparePins(a, b *client.PinoutPin) pinDiff {
+	d := pinDiff{A: a, B: b}
+	if a == nil || b == nil {
+		d.Status = diffMissing
+		return d
+	}
+
+	classA, classB := classifyPin(a), classifyPin(b)
+	if classA != classB {
+		d.Status = diffDifferent
+		if classA == classPower || classA == classGround || classB == classPower || classB == classGround {
+			d.Status = diffConflict
+		}
+		return d
+	}
+
+	sameName := compactPinName(a.Name) == compactPinName(b.Name)
+	switch classA {
+	case classPower, classGround, classControl, classNC:
+		// These pins are identified by name alone
+		switch {
+		case sameName:
+			d.Status, d.Score = diffSame, 1
+		case classA == classGround:
+			d.Status, d.Score = diffCompatible, 0.75
+		default:
+			d.Status, d.Score = diffPartial, 0.25
+		}
+		return d
+	}
+
+	setA, setB := pinFunctionSet(a), pinFunctionSet(b)
+	for f := range setA {
+		if setB[f] {
+			d.Shared = append(d.Shared, f)
+		} else {
+			d.OnlyA = append(d.OnlyA, f)
+		}
+	}
+	for f := range setB {
+		if !setA[f] {
+			d.OnlyB = append(d.OnlyB, f)
+		}
+	}
+	sort.Strings(d.Shared)
+	sort.Strings(d.OnlyA)
+	sort.Strings(d.OnlyB)
+
+	overlap := 1.0
+	if union := len(d.Shared) + len(d.OnlyA) + len(d.OnlyB); union > 0 {
+		overlap = float64(len(d.Shared)) / float64(union)
+	}
+	d.Score = 0.25 + 0.5*overlap
+	if sameName {
+		d.Score += 0.25
+	}
+
+	switch {
+	case sameName && overlap == 1:
+		d.Status = diffSame
+	case d.Score >= 0.6:
+		d.Status = diffCompatible
+	default:
+		d.Status = diffPartial
+	}
+	return d
+}
+
+// replacement is another device ranked by pin compatibility.
+type replacement struct {
+	Device client.Device
+	*pinCompatibility
+}
+
+// replacements ranks the other devices with pinouts by how well they
+// match the device's pinout, leaving out those with nothing in common.
+func (idx *pinIndex) replacements(deviceID string, limit int) []replacement {
+	target := idx.pinouts[deviceID]
+	if target == nil {
+		return nil
+	}
+	var ranked []replacement
+	for _, device := range idx.devices {
+		if device.ID == deviceID {
+			continue
+		}
+		c := comparePinouts(target, idx.pinouts[device.ID])
+		if c.Score > 0 {
+			ranked = append(ranked, replacement{Device: device, pinCompatibility: c})
+		}
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		if ranked[i].Score != ranked[j].Score {
+			return ranked[i].Score > ranked[j].Score
+		}
+		return ranked[i].Conflicts < ranked[j].Conflicts
+	})
+	if len(ranked) > limit {
+		ranked = ranked[:limit]
+	}
+	return ranked
+}
+
+// replacementsData is passed to the device page's replacements partial.
+type replacementsData struct {
+	DeviceID     string
+	Pending      bool
+	Unavailable  bool
+	NoPinout     bool
+	Replacements []replacement
+}
+
+// handleDeviceReplacements ranks other devices by pin compatibility. It
+// is loaded lazily by the device page and uses the pin index, building it
+// first if needed.
+func (s *Server) handleDeviceReplacements(w http.ResponseWriter, r *http.Request) {
+	data := replacementsData{DeviceID: r.PathValue("id")}
+
+	idx, ok, state := s.pins.indexOrStart(s.apiClient(r), false)
+	if ok {
+		data.Replacements = idx.replacements(data.DeviceID, maxReplacements)
+		data.NoPinout = idx.pinouts[data.DeviceID] == nil
+	}
+	data.Pending = state.Pending
+	data.Unavailable = state.Unavailable != ""
+
+	s.renderPartial(w, "partials/device-replacements.html", data)
+}
+
+// deviceCompareData is passed to device-compare.html.
+type deviceCompareData struct {
+	A, B *client.Device
+	*pinCompatibility
+}
+
+// handleDeviceCompare shows the pin-by-pin comparison of two devices,
+// using their current pinouts.
+func (s *Server) handleDeviceCompare(w http.ResponseWriter, r *http.Request) {
+	apiClient := s.apiClient(r)
+	data := deviceCompareData{}
+	var pinouts [2]*client.PinoutResponse
+	for i, id := range []string{r.PathValue("id"), r.PathValue("other")} {
+		device, err := apiClient.GetDevice(id, false)
+		if err != nil {
+			s.renderError(w, r, "Failed to get device", err)
+			return
+		}
+		pinout, err := apiClient.GetDevicePinout(id)
+		if isNotFound(err) {
+			s.renderErrorStatus(w, r, http.StatusNotFound, device.Name+" has no pinout")
+			return
+		} else if err != nil {
+			s.renderError(w, r, "Failed to get pinout", err)
+			return
+		}
+		pinouts[i] = pinout
+		if i == 0 {
+			data.A = device
+		} else {
+			data.B = device
+		}
+	}
+	data.pinCompatibility = comparePinouts(pinouts[0], pinouts[1])
+
+	s.render(w, "device-compare.html", pageData{
+		Title:   data.A.Name + " vs " + data.B.Name,
+		Content: data,
+	})
+}
@@ -0,0 +1,182 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rmrfslashbin/manuals-webui/internal/client"
+)
+
+func TestClassifyPin(t *testing.T) {
+	tests := []struct {
+		pin      client.PinoutPin
+		expected pinClass
+	}{
+		{client.PinoutPin{Name: "GND"}, classGround},
+		{client.PinoutPin{Name: "GND2"}, classGround},
+		{client.PinoutPin{Name: "3.3V"}, classPower},
+		{client.PinoutPin{Name: "+5V"}, classPower},
+		{client.PinoutPin{Name: "VDD_SDIO"}, classPower},
+		{client.PinoutPin{Name: "EN"}, classControl},
+		{client.PinoutPin{Name: "NC"}, classNC},
+		{client.PinoutPin{Name: "GPIO4", GPIONum: gpio(4)}, classGPIO},
+		{client.PinoutPin{Name: "A0", AltFunctions: []string{"ADC0"}}, classAnalog},
+		{client.PinoutPin{Name: "SDI", AltFunctions: []string{"I2C_SDA"}}, classGPIO},
+		{client.PinoutPin{Name: "XTAL"}, classOther},
+	}
+	for _, tc := range tests {
+		if got := classifyPin(&tc.pin); got != tc.expected {
+			t.Errorf("classifyPin(%q) = %s, want %s", tc.pin.Name, got, tc.expected)
+		}
+	}
+}
+
+func TestComparePinouts(t *testing.T) {
+	board := func(pins ...client.PinoutPin) *client.PinoutResponse {
+		return &client.PinoutResponse{Pins: pins}
+	}
+	reference := board(
+		client.PinoutPin{PhysicalPin: 1, Name: "3V3"},
+		client.PinoutPin{PhysicalPin: 2, Name: "GND"},
+		client.PinoutPin{PhysicalPin: 3, Name: "GPIO21", GPIONum: gpio(21), AltFunctions: []string{"I2C0_SDA"}},
+		client.PinoutPin{PhysicalPin: 4, Name: "GPIO22", GPIONum: gpio(22), AltFunctions: []string{"I2C0_SCL", "UART1_TX"}},
+	)
+
+	c := comparePinouts(reference, reference)
+	if c.Score != 100 || c.Same != 4 || c.Conflicts != 0 {
+		t.Errorf("identical pinouts = %+v, want a perfect score", c)
+	}
+
+	// Same roles, renumbered GPIOs and one function missing
+	c = comparePinouts(reference, board(
+		client.PinoutPin{PhysicalPin: 1, Name: "3.3V"},
+		client.PinoutPin{PhysicalPin: 2, Name: "GND"},
+		client.PinoutPin{PhysicalPin: 3, Name: "GP4", GPIONum: gpio(4), AltFunctions: []string{"I2C1 SDA"}},
+		client.PinoutPin{PhysicalPin: 4, Name: "GP5", GPIONum: gpio(5), AltFunctions: []string{"I2C1 SCL"}},
+	))
+	expected := []pinDiffStatus{diffSame, diffSame, diffCompatible, diffPartial}
+	for i, d := range c.Pins {
+		if d.Status != expected[i] {
+			t.Errorf("pin %d status = %s, want %s", d.Physical, d.Status, expected[i])
+		}
+	}
+	if d := c.Pins[3]; len(d.Shared) != 1 || d.Shared[0] != "I2C_SCL" || len(d.OnlyA) != 1 || d.OnlyA[0] != "UART_TX" {
+		t.Errorf("pin 4 diff = %+v", d)
+	}
+	if c.Score <= 50 || c.Score >= 100 {
+		t.Errorf("expected a high but imperfect score, got %d", c.Score)
+	}
+
+	// Power swapped with ground, and an extra pin
+	c = comparePinouts(reference, board(
+		client.PinoutPin{PhysicalPin: 1, Name: "GND"},
+		client.PinoutPin{PhysicalPin: 2, Name: "3V3"},
+		client.PinoutPin{PhysicalPin: 3, Name: "GPIO21", GPIONum: gpio(21), AltFunctions: []string{"I2C0_SDA"}},
+		client.PinoutPin{PhysicalPin: 4, Name: "GPIO22", GPIONum: gpio(22), AltFunctions: []string{"I2C0_SCL", "UART1_TX"}},
+		client.PinoutPin{PhysicalPin: 5, Name: "GPIO23", GPIONum: gpio(23)},
+	))
+	if c.Conflicts != 2 || c.Missing != 1 || c.Same != 2 || c.Score != 40 {
+		t.Errorf("swapped power = %+v", c)
+	}
+	if c.Pins[4].A != nil || c.Pins[4].B == nil {
+		t.Error("expected the extra pin only on the second board")
+	}
+}
+
+func TestPinIndexReplacements(t *testing.T) {
+	devices, pinouts := pinCatalogFixture()
+	devices = append(devices, client.Device{ID: "esp32-clone", Name: "ESP32 Clone"})
+	pinouts["esp32-clone"] = append([]client.PinoutPin(nil), pinouts["esp32"]...)
+	api, _ := deviceAPIServer(t, devices, map[string]map[string]any{"pinout": pinoutResponses(pinouts)})
+	defer api.Close()
+	s := testServer(t, api)
+
+	s.pins.start(client.New(api.URL, "test-key"))
+	waitForJob(t, s.pins.job)
+	idx := s.pins.indexFor(s.client)
+
+	ranked := idx.replacements("esp32", 10)
+	if len(ranked) == 0 || ranked[0].Device.ID != "esp32-clone" || ranked[0].Score != 100 {
+		t.Fatalf("expected the clone first with a perfect score, got %+v", ranked)
+	}
+	for i := 1; i < len(ranked); i++ {
+		if ranked[i].Device.ID == "esp32" {
+			t.Error("expected the device itself to be left out")
+		}
+		if ranked[i].Score > ranked[i-1].Score {
+			t.Errorf("replacements not ranked by score: %d before %d", ranked[i-1].Score, ranked[i].Score)
+		}
+	}
+	if got := idx.replacements("esp32", 1); len(got) != 1 {
+		t.Errorf("expected the limit to apply, got %d", len(got))
+	}
+	if got := idx.replacements("mqtt", 10); got != nil {
+		t.Errorf("expected no replacements without a pinout, got %+v", got)
+	}
+}
+
+func TestHandleDeviceReplacements(t *testing.T) {
+	devices, pinouts := pinCatalogFixture()
+	devices = append(devices, client.Device{ID: "esp32-rev2", Name: "ESP32 rev2"})
+	pinouts["esp32-rev2"] = pinouts["esp32"][:2]
+	api, _ := deviceAPIServer(t, devices, map[string]map[string]any{"pinout": pinoutResponses(pinouts)})
+	defer api.Close()
+	s := testServer(t, api)
+
+	get := func() string {
+		req := httptest.NewRequest("GET", "/partials/devices/esp32/replacements", nil)
+		req.Header.Set("HX-Request", "true")
+		w := httptest.NewRecorder()
+		s.Handler().ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", w.Code)
+		}
+		return w.Body.String()
+	}
+
+	if body := get(); !strings.Contains(body, `hx-trigger="every 2s"`) {
+		t.Error("expected the partial to poll while pinouts load")
+	}
+	waitForJob(t, s.pins.job)
+
+	body := get()
+	if strings.Contains(body, `hx-trigger="every 2s"`) {
+		t.Error("expected polling to stop once the index is built")
+	}
+	if !strings.Contains(body, `href="/devices/esp32/compare/esp32-rev2"`) {
+		t.Error("expected a comparison link for the ranked device")
+	}
+}
+
+func TestHandleDeviceCompare(t *testing.T) {
+	devices, pinouts := pinCatalogFixture()
+	devices = append(devices, client.Device{ID: "esp32-rev2", Name: "ESP32 rev2"})
+	pinouts["esp32-rev2"] = []client.PinoutPin{
+		{PhysicalPin: 33, GPIONum: gpio(21), Name: "GPIO21", AltFunctions: []string{"I2C0_SDA"}},
+	}
+	api, _ := deviceAPIServer(t, devices, map[string]map[string]any{"pinout": pinoutResponses(pinouts)})
+	defer api.Close()
+	s := testServer(t, api)
+
+	req := httptest.NewRequest("GET", "/devices/esp32/compare/esp32-rev2", nil)
+	w := httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	body := w.Body.String()
+	for _, want := range []string{"ESP32 vs ESP32 rev2", "GPIO21", "missing", "&minus;VSPI_HD", "I2C_SDA</span>"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected comparison to contain %q", want)
+		}
+	}
+
+	req = httptest.NewRequest("GET", "/devices/esp32/compare/mqtt", nil)
+	w = httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound || !strings.Contains(w.Body.String(), "MQTT has no pinout") {
+		t.Errorf("expected 404 for a device without a pinout, got %d", w.Code)
+	}
+}
@@ -0,0 +1,239 @@
+package server
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rmrfslashbin/manuals-webui/internal/client"
+)
+
+// pinoutExporter turns a device's pinout into a file for another tool.
+// Each exporter is served at /devices/{id}/pinout.{Ext}.
+type pinoutExporter struct {
+	Ext         string
+	Label       string
+	ContentType string
+	filename    string // download name if the tool expects a fixed one
+	write       func(w io.Writer, device *client.Device, pinout *client.PinoutResponse) error
+}
+
+// pinoutExporters are the available pinout formats, in the order they are
+// offered on the device page.
+var pinoutExporters = []*pinoutExporter{
+	{Ext: "h", Label: "C header", ContentType: "text/x-c; charset=utf-8", write: writePinoutHeader},
+	{Ext: "dts", Label: "Devicetree overlay", ContentType: "text/plain; charset=utf-8", write: writePinoutDTS},
+	{Ext: "json", Label: "JSON", ContentType: "application/json", write: writePinoutJSON},
+	{Ext: "csv", Label: "CSV", ContentType: "text/csv; charset=utf-8", write: writePinoutCSV},
+	{Ext: "arduino.h", Label: "Arduino variant", ContentType: "text/x-c; charset=utf-8", filename: "pins_arduino.h", write: writePinoutArduino},
+	{Ext: "kicad_sym", Label: "KiCad symbol", ContentType: "text/plain; charset=utf-8", write: writePinoutKicad},
+}
+
+// URL is the export of the device's pinout in this format.
+func (e *pinoutExporter) URL(deviceID string) string {
+	return "/devices/" + deviceID + "/pinout." + e.Ext
+}
+
+// handlePinoutExport serves a device's pinout in the exporter's format.
+func (s *Server) handlePinoutExport(e *pinoutExporter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+		apiClient := s.apiClient(r)
+		device, err := apiClient.GetDevice(id, false)
+		if err != nil {
+			s.renderError(w, r, "Failed to get device", err)
+			return
+		}
+		pinout, err := apiClient.GetDevicePinout(id)
+		if isNotFound(err) || (err == nil && len(pinout.Pins) == 0) {
+			s.renderErrorStatus(w, r, http.StatusNotFound, device.Name+" has no pinout")
+			return
+		} else if err != nil {
+			s.renderError(w, r, "Failed to get pinout", err)
+			return
+		}
+
+		filename := e.filename
+		if filename == "" {
+			filename = id + "-pinout." + e.Ext
+		}
+		w.Header().Set("Content-Type", e.ContentType)
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+		if err := e.write(w, device, sortedPinout(pinout)); err != nil {
+			s.logger.Error("failed to export pinout", "device", id, "format", e.Ext, "error", err)
+		}
+	}
+}
+
+// sortedPinout returns a copy of the pinout ordered by physical pin, so
+// exports don't depend on the order the API returned.
+func sortedPinout(p *client.PinoutResponse) *client.PinoutResponse {
+	sorted := *p
+	sorted.Pins = append([]client.PinoutPin(nil), p.Pins...)
+	sort.SliceStable(sorted.Pins, func(i, j int) bool {
+		return sorted.Pins[i].PhysicalPin < sorted.Pins[j].PhysicalPin
+	})
+	return &sorted
+}
+
+// cIdentifier turns a name into an upper-case C identifier: "I2C0 SDA"
+// becomes "I2C0_SDA" and "3.3V" becomes "3V3".
+func cIdentifier(name string) string {
+	return strings.Join(functionTokens(voltageName.ReplaceAllString(name, "${1}V$2")), "_")
+}
+
+// commentText keeps text from ending a C or devicetree comment early.
+func commentText(s string) string {
+	return strings.ReplaceAll(s, "*/", "* /")
+}
+
+// identifierSet hands out identifiers, numbering repeats: the second GND
+// pin becomes "GND_2".
+type identifierSet map[string]int
+
+func (ids identifierSet) unique(name string) string {
+	ids[name]++
+	if n := ids[name]; n > 1 {
+		return name + "_" + strconv.Itoa(n)
+	}
+	return name
+}
+
+// writePinoutHeader writes a C header with the physical pin of every pin,
+// and the GPIO number of every pin name and alternate function. A function
+// offered by several pins is defined for the first and listed for the
+// rest, since only one can be chosen.
+func writePinoutHeader(w io.Writer, device *client.Device, pinout *client.PinoutResponse) error {
+	prefix := cIdentifier(device.ID)
+	if prefix == "" || (prefix[0] >= '0' && prefix[0] <= '9') {
+		prefix = "DEVICE_" + prefix
+	}
+	guard := prefix + "_PINOUT_H"
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "/*\n * %s pinout\n *\n * Generated by manuals-webui from device %q.\n */\n\n", commentText(device.Name), commentText(device.ID))
+	fmt.Fprintf(&b, "#ifndef %s\n#define %s\n", guard, guard)
+
+	b.WriteString("\n/* Physical pin numbers */\n")
+	physical := make(identifierSet)
+	for _, pin := range pinout.Pins {
+		fmt.Fprintf(&b, "#define %s_PIN_%s %d\n", prefix, physical.unique(cIdentifier(pin.Name)), pin.PhysicalPin)
+	}
+
+	gpios := make(identifierSet)
+	defined := make(map[string]int)
+	var functions strings.Builder
+	var names strings.Builder
+	for _, pin := range pinout.Pins {
+		if pin.GPIONum == nil {
+			continue
+		}
+		gpio := *pin.GPIONum
+		name := gpios.unique(cIdentifier(pin.Name))
+		defined[name] = gpio
+		fmt.Fprintf(&names, "#define %s_%s %d\n", prefix, name, gpio)
+		for _, f := range pin.AltFunctions {
+			id := cIdentifier(f)
+			if id == "" {
+				continue
+			}
+			if first, ok := defined[id]; ok {
+				if first != gpio {
+					fmt.Fprintf(&functions, "/* %s_%s is also on GPIO %d */\n", prefix, id, gpio)
+				}
+				continue
+			}
+			defined[id] = gpio
+			fmt.Fprintf(&functions, "#define %s_%s %d\n", prefix, id, gpio)
+		}
+	}
+	if names.Len() > 0 {
+		b.WriteString("\n/* GPIO numbers */\n")
+		b.WriteString(names.String())
+	}
+	if functions.Len() > 0 {
+		b.WriteString("\n/* GPIO numbers by function */\n")
+		b.WriteString(functions.String())
+	}
+
+	fmt.Fprintf(&b, "\n#endif /* %s */\n", guard)
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// dtsName turns a name into a devicetree property name: "I2C0_SDA"
+// becomes "i2c0-sda".
+func dtsName(name string) string {
+	return strings.ToLower(strings.Join(functionTokens(voltageName.ReplaceAllString(name, "${1}V$2")), "-"))
+}
+
+// dtsFlags maps a pin's default pull to devicetree GPIO flags.
+func dtsFlags(pull string) string {
+	switch strings.ToLower(pull) {
+	case "up":
+		return "(GPIO_ACTIVE_HIGH | GPIO_PULL_UP)"
+	case "down":
+		return "(GPIO_ACTIVE_HIGH | GPIO_PULL_DOWN)"
+	}
+	return "GPIO_ACTIVE_HIGH"
+}
+
+// writePinoutDTS writes a Zephyr-style overlay fragment naming every GPIO
+// in the zephyr,user node, so firmware can look pins up with
+// GPIO_DT_SPEC_GET. GPIOs are assumed to be in banks of 32 named gpio0,
+// gpio1 and so on; boards that differ need the phandles adjusting.
+func writePinoutDTS(w io.Writer, device *client.Device, pinout *client.PinoutResponse) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "/*\n * %s pinout\n *\n * Generated by manuals-webui from device %q.\n", commentText(device.Name), commentText(device.ID))
+	b.WriteString(" * GPIO controllers are assumed to be gpio0, gpio1, ... with 32 pins each.\n */\n\n")
+	b.WriteString("#include <zephyr/dt-bindings/gpio/gpio.h>\n\n")
+	b.WriteString("/ {\n\tzephyr,user {\n")
+
+	names := make(identifierSet)
+	for _, pin := range pinout.Pins {
+		if pin.GPIONum == nil {
+			continue
+		}
+		gpio := *pin.GPIONum
+		fmt.Fprintf(&b, "\t\t/* pin %d", pin.PhysicalPin)
+		if len(pin.AltFunctions) > 0 {
+			fmt.Fprintf(&b, ": %s", commentText(strings.Join(pin.AltFunctions, ", ")))
+		}
+		b.WriteString(" */\n")
+		fmt.Fprintf(&b, "\t\t%s-gpios = <&gpio%d %d %s>;\n",
+			strings.ReplaceAll(names.unique(dtsName(pin.Name)), "_", "-"), gpio/32, gpio%32, dtsFlags(pin.DefaultPull))
+	}
+
+	b.WriteString("\t};\n};\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writePinoutJSON writes the pinout as the API returns it.
+func writePinoutJSON(w io.Writer, device *client.Device, pinout *client.PinoutResponse) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(pinout)
+}
+
+// writePinoutCSV writes one row per pin, with alternate functions joined
+// by semicolons.
+func writePinoutCSV(w io.Writer, device *client.Device, pinout *client.PinoutResponse) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"physical_pin", "gpio", "name", "default_pull", "alt_functions", "description"})
+	for _, pin := range pinout.Pins {
+		gpio := ""
+		if pin.GPIONum != nil {
+			gpio = strconv.Itoa(*pin.GPIONum)
+		}
+		cw.Write([]string{strconv.Itoa(pin.PhysicalPin), gpio, pin.Name, pin.DefaultPull, strings.Join(pin.AltFunctions, ";"), pin.Description})
+	}
+	cw.Flush()
+	return cw.Error()
+}
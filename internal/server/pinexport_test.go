@@ -0,0 +1,138 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rmrfslashbin/manuals-webui/internal/client"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files in testdata")
+
+// exportFixture is a small board with the cases exporters must handle:
+// repeated names, a function on two pins, pulls, a GPIO past the first
+// bank and pins listed out of order.
+func exportFixture() (*client.Device, *client.PinoutResponse) {
+	device := &client.Device{ID: "esp32-devkit", Name: "ESP32 DevKit"}
+	pinout := &client.PinoutResponse{
+		DeviceID: "esp32-devkit",
+		Name:     "ESP32 DevKit",
+		Pins: []client.PinoutPin{
+			{PhysicalPin: 2, Name: "GND"},
+			{PhysicalPin: 1, Name: "3.3V", Description: "Regulated supply, 600 mA max"},
+			{PhysicalPin: 33, GPIONum: gpio(21), Name: "GPIO21", DefaultPull: "up", AltFunctions: []string{"I2C0_SDA", "VSPI_HD"}},
+			{PhysicalPin: 36, GPIONum: gpio(22), Name: "GPIO22", DefaultPull: "up", AltFunctions: []string{"I2C0_SCL", "U0RTS"}},
+			{PhysicalPin: 8, GPIONum: gpio(33), Name: "GPIO33", AltFunctions: []string{"ADC1_CH5", "TOUCH8"}},
+			{PhysicalPin: 35, GPIONum: gpio(1), Name: "TX0", DefaultPull: "down", AltFunctions: []string{"UART0_TX", "CLK_OUT3"}},
+			{PhysicalPin: 37, GPIONum: gpio(23), Name: "GPIO23", AltFunctions: []string{"VSPI_MOSI", "I2C0_SDA"}},
+			{PhysicalPin: 38, Name: "GND"},
+		},
+	}
+	return device, pinout
+}
+
+func TestPinoutExportersGolden(t *testing.T) {
+	device, pinout := exportFixture()
+	for _, e := range pinoutExporters {
+		t.Run(e.Ext, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := e.write(&buf, device, sortedPinout(pinout)); err != nil {
+				t.Fatalf("export failed: %v", err)
+			}
+			golden := filepath.Join("testdata", "pinout", "esp32-devkit."+e.Ext)
+			if *update {
+				if err := os.MkdirAll(filepath.Dir(golden), 0o755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(golden, buf.Bytes(), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("reading golden file (run with -update to create it): %v", err)
+			}
+			if !bytes.Equal(buf.Bytes(), want) {
+				t.Errorf("%s export differs from %s:\n%s", e.Ext, golden, buf.String())
+			}
+		})
+	}
+}
+
+func TestCIdentifier(t *testing.T) {
+	tests := []struct {
+		name, expected string
+	}{
+		{"I2C0 SDA", "I2C0_SDA"},
+		{"3.3V", "3V3"},
+		{"vspi-clk", "VSPI_CLK"},
+		{"GPIO21", "GPIO21"},
+		{"+5V", "5V"},
+	}
+	for _, tc := range tests {
+		if got := cIdentifier(tc.name); got != tc.expected {
+			t.Errorf("cIdentifier(%q) = %q, want %q", tc.name, got, tc.expected)
+		}
+	}
+}
+
+func TestHandlePinoutExport(t *testing.T) {
+	device, pinout := exportFixture()
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/devices/esp32-devkit/pinout"):
+			json.NewEncoder(w).Encode(pinout)
+		case strings.HasSuffix(r.URL.Path, "/devices/esp32-devkit"):
+			json.NewEncoder(w).Encode(device)
+		case strings.HasSuffix(r.URL.Path, "/devices/mqtt"):
+			json.NewEncoder(w).Encode(client.Device{ID: "mqtt", Name: "MQTT"})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer api.Close()
+	s := testServer(t, api)
+
+	tests := []struct {
+		url         string
+		status      int
+		contentType string
+		disposition string
+		contains    string
+	}{
+		{"/devices/esp32-devkit/pinout.h", http.StatusOK, "text/x-c; charset=utf-8", "attachment; filename=esp32-devkit-pinout.h", "#define ESP32_DEVKIT_I2C0_SDA 21"},
+		{"/devices/esp32-devkit/pinout.dts", http.StatusOK, "text/plain; charset=utf-8", "attachment; filename=esp32-devkit-pinout.dts", "gpio33-gpios = <&gpio1 1 GPIO_ACTIVE_HIGH>;"},
+		{"/devices/esp32-devkit/pinout.json", http.StatusOK, "application/json", "attachment; filename=esp32-devkit-pinout.json", `"physical_pin": 1`},
+		{"/devices/esp32-devkit/pinout.csv", http.StatusOK, "text/csv; charset=utf-8", "attachment; filename=esp32-devkit-pinout.csv", "33,21,GPIO21,up,I2C0_SDA;VSPI_HD,"},
+		{"/devices/esp32-devkit/pinout.arduino.h", http.StatusOK, "text/x-c; charset=utf-8", "attachment; filename=pins_arduino.h", "static const uint8_t SDA = 21;"},
+		{"/devices/esp32-devkit/pinout.kicad_sym", http.StatusOK, "text/plain; charset=utf-8", "attachment; filename=esp32-devkit-pinout.kicad_sym", `(symbol "ESP32_DevKit" (in_bom yes)`},
+		{"/devices/mqtt/pinout.h", http.StatusNotFound, "", "", "MQTT has no pinout"},
+		{"/devices/missing/pinout.csv", http.StatusNotFound, "", "", ""},
+	}
+	for _, tc := range tests {
+		t.Run(tc.url, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tc.url, nil)
+			w := httptest.NewRecorder()
+			s.Handler().ServeHTTP(w, req)
+			if w.Code != tc.status {
+				t.Fatalf("expected status %d, got %d", tc.status, w.Code)
+			}
+			if tc.contentType != "" && w.Header().Get("Content-Type") != tc.contentType {
+				t.Errorf("unexpected Content-Type %q", w.Header().Get("Content-Type"))
+			}
+			if tc.disposition != "" && w.Header().Get("Content-Disposition") != tc.disposition {
+				t.Errorf("unexpected Content-Disposition %q", w.Header().Get("Content-Disposition"))
+			}
+			if !strings.Contains(w.Body.String(), tc.contains) {
+				t.Errorf("expected body to contain %q", tc.contains)
+			}
+		})
+	}
+}
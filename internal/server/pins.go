@@ -0,0 +1,373 @@
+package server
+
+import (
+	"context"
+	"html/template"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/rmrfslashbin/manuals-webui/internal/client"
+)
+
+const (
+	// pinIndexConcurrency is how many pinout requests run at once while
+	// building the index.
+	pinIndexConcurrency = 4
+
+	// pinIndexTTL is how long an index is served before it is rebuilt in
+	// the background.
+	pinIndexTTL = time.Hour
+)
+
+// pinEntry is one pin of one device.
+type pinEntry struct {
+	Device    client.Device
+	Pin       client.PinoutPin
+	functions [][]string // pin name and alt functions, split into tokens
+}
+
+// pinIndex holds the pinout of every device of one API.
+type pinIndex struct {
+	Pins     []pinEntry // by device name, then physical pin
+	Devices  int        // devices with a pinout
+	Failed   int        // devices whose pinout could not be loaded
+	Families []pinFamily
+
+	pinouts map[string]*client.PinoutResponse
+	devices []client.Device // with a pinout, by name
+}
+
+// pinFamily is a function with bus and channel numbers removed, e.g.
+// "I2C_SDA" for "I2C0_SDA", with the number of devices offering it.
+type pinFamily struct {
+	Name    string
+	Devices int
+}
+
+// newPinCatalog loads every device's pinout in the background so
+// functions can be searched across the catalog.
+func newPinCatalog(s *Server) *catalogIndex[*pinIndex] {
+	return newCatalogIndex(s, "pin index", pinIndexTTL, s.buildPinIndex)
+}
+
+// buildPinIndex fetches every device's pinout. Devices without one are
+// left out.
+func (s *Server) buildPinIndex(ctx context.Context, c *client.Client, p *jobProgress) (*pinIndex, error) {
+	devices, err := s.devices.get(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	p.setTotal(int64(len(devices.Devices)))
+
+	idx := &pinIndex{pinouts: make(map[string]*client.PinoutResponse)}
+	var mu sync.Mutex
+	var lastErr error
+	err = eachDevice(ctx, devices.Devices, pinIndexConcurrency, func(device client.Device) {
+		resp, err := c.GetDevicePinout(device.ID)
+		p.add(1)
+
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case isNotFound(err):
+		case err != nil:
+			idx.Failed++
+			lastErr = err
+		case len(resp.Pins) > 0:
+			idx.Devices++
+			idx.pinouts[device.ID] = resp
+			for _, pin := range resp.Pins {
+				idx.Pins = append(idx.Pins, newPinEntry(device, pin))
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	if idx.Failed > 0 && idx.Devices == 0 {
+		return nil, lastErr
+	}
+	if idx.Failed > 0 {
+		s.logger.Warn("failed to load some device pinouts", "failed", idx.Failed, "error", lastErr)
+	}
+
+	sort.Slice(idx.Pins, func(i, j int) bool {
+		a, b := &idx.Pins[i], &idx.Pins[j]
+		if an, bn := strings.ToLower(a.Device.Name), strings.ToLower(b.Device.Name); an != bn {
+			return an < bn
+		}
+		if a.Device.ID != b.Device.ID {
+			return a.Device.ID < b.Device.ID
+		}
+		return a.Pin.PhysicalPin < b.Pin.PhysicalPin
+	})
+	idx.Families = pinFamilies(idx.Pins)
+	// The directory listing is already sorted by name
+	for _, device := range devices.Devices {
+		if idx.pinouts[device.ID] != nil {
+			idx.devices = append(idx.devices, device)
+		}
+	}
+	return idx, nil
+}
+
+func newPinEntry(device client.Device, pin client.PinoutPin) pinEntry {
+	return pinEntry{Device: device, Pin: pin, functions: pinFunctions(pin)}
+}
+
+// pinFunctions lists a pin's name and alternate functions as tokens.
+func pinFunctions(pin client.PinoutPin) [][]string {
+	var functions [][]string
+	for _, f := range append([]string{pin.Name}, pin.AltFunctions...) {
+		if tokens := functionTokens(f); len(tokens) > 0 {
+			functions = append(functions, tokens)
+		}
+	}
+	return functions
+}
+
+// functionTokens splits a function name such as "I2C0_SDA", "SPI MOSI" or
+// "hspi-clk" into upper-case tokens.
+func functionTokens(s string) []string {
+	return strings.FieldsFunc(strings.ToUpper(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// trimIndex removes a trailing bus or channel number: "I2C0" becomes
+// "I2C", "GPIO12" becomes "GPIO". Names that are only a number are kept.
+func trimIndex(token string) string {
+	trimmed := strings.TrimRightFunc(token, unicode.IsDigit)
+	if trimmed == "" {
+		return token
+	}
+	return trimmed
+}
+
+// functionFamily names the function without bus and channel numbers, or
+// returns "" for a plain GPIO name.
+func functionFamily(tokens []string) string {
+	family := make([]string, len(tokens))
+	for i, t := range tokens {
+		family[i] = trimIndex(t)
+	}
+	name := strings.Join(family, "_")
+	if name == "GPIO" || name == "IO" || name == "GP" {
+		return ""
+	}
+	return name
+}
+
+// matchFunction reports whether every query token names a token of the
+// function. A query token without a number matches any numbered
+// instance, so "I2C_SDA" finds "I2C0_SDA" and "SDA" finds both.
+func matchFunction(query, function []string) bool {
+	if len(query) == 0 {
+		return false
+	}
+	for _, q := range query {
+		found := false
+		for _, f := range function {
+			if f == q || (trimIndex(q) == q && trimIndex(f) == q) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// pinFamilies counts the devices offering each function family, most
+// widely available first. Plain GPIO numbers are left out.
+func pinFamilies(pins []pinEntry) []pinFamily {
+	devices := make(map[string]map[string]bool)
+	for _, e := range pins {
+		for _, tokens := range e.functions {
+			name := functionFamily(tokens)
+			if name == "" {
+				continue
+			}
+			if devices[name] == nil {
+				devices[name] = make(map[string]bool)
+			}
+			devices[name][e.Device.ID] = true
+		}
+	}
+
+	families := make([]pinFamily, 0, len(devices))
+	for name, ids := range devices {
+		families = append(families, pinFamily{Name: name, Devices: len(ids)})
+	}
+	sort.Slice(families, func(i, j int) bool {
+		if families[i].Devices != families[j].Devices {
+			return families[i].Devices > families[j].Devices
+		}
+		return families[i].Name < families[j].Name
+	})
+	return families
+}
+
+// pinQuery is the state of the pin search, kept in the URL.
+type pinQuery struct {
+	Function string
+	Domain   string
+	Type     string
+}
+
+func parsePinQuery(v url.Values) pinQuery {
+	return pinQuery{
+		Function: strings.TrimSpace(v.Get("function")),
+		Domain:   v.Get("domain"),
+		Type:     v.Get("type"),
+	}
+}
+
+func (q pinQuery) url(path string, poll bool) template.URL {
+	v := url.Values{}
+	for key, value := range map[string]string{"function": q.Function, "domain": q.Domain, "type": q.Type} {
+		if value != "" {
+			v.Set(key, value)
+		}
+	}
+	if poll {
+		v.Set("poll", "1")
+	}
+	if len(v) == 0 {
+		return template.URL(path)
+	}
+	return template.URL(path + "?" + v.Encode())
+}
+
+// URL is the pins page for this query.
+func (q pinQuery) URL() template.URL { return q.url("/pins", false) }
+
+// PollURL is the results partial polled while the index is built.
+func (q pinQuery) PollURL() template.URL { return q.url("/partials/pins", true) }
+
+// FunctionURL is the pins page for another function, keeping the filters.
+func (q pinQuery) FunctionURL(function string) template.URL {
+	q.Function = function
+	return q.URL()
+}
+
+// pinMatch is a pin offering the searched function.
+type pinMatch struct {
+	pinEntry
+	Matching []string // the pin's functions that matched
+}
+
+// Others are the pin's functions that did not match.
+func (m pinMatch) Others() []string {
+	var others []string
+	for _, f := range append([]string{m.Pin.Name}, m.Pin.AltFunctions...) {
+		matched := false
+		for _, mf := range m.Matching {
+			matched = matched || mf == f
+		}
+		if !matched {
+			others = append(others, f)
+		}
+	}
+	return others
+}
+
+// search lists the pins offering the query's function on devices passing
+// its filters, and the number of devices they belong to.
+func (idx *pinIndex) search(q pinQuery) ([]pinMatch, int) {
+	query := functionTokens(q.Function)
+	var matches []pinMatch
+	devices := make(map[string]bool)
+	for _, e := range idx.Pins {
+		if (q.Domain != "" && e.Device.Domain != q.Domain) || (q.Type != "" && e.Device.Type != q.Type) {
+			continue
+		}
+		m := pinMatch{pinEntry: e}
+		names := append([]string{e.Pin.Name}, e.Pin.AltFunctions...)
+		for _, name := range names {
+			if matchFunction(query, functionTokens(name)) {
+				m.Matching = append(m.Matching, name)
+			}
+		}
+		if len(m.Matching) > 0 {
+			matches = append(matches, m)
+			devices[e.Device.ID] = true
+		}
+	}
+	return matches, len(devices)
+}
+
+// Domains returns the domains of devices with pinouts, for the filters.
+func (idx *pinIndex) Domains() []string {
+	return idx.distinct(func(d client.Device) string { return d.Domain })
+}
+
+// Types returns the types of devices with pinouts, for the filters.
+func (idx *pinIndex) Types() []string {
+	return idx.distinct(func(d client.Device) string { return d.Type })
+}
+
+func (idx *pinIndex) distinct(field func(client.Device) string) []string {
+	seen := make(map[string]bool)
+	var values []string
+	for _, e := range idx.Pins {
+		if v := field(e.Device); v != "" && !seen[v] {
+			seen[v] = true
+			values = append(values, v)
+		}
+	}
+	sort.Strings(values)
+	return values
+}
+
+// pinsData is passed to pins.html and its results partial.
+type pinsData struct {
+	Query pinQuery
+
+	catalogState
+	Index   *pinIndex
+	Matches []pinMatch
+	Devices int // devices with a matching pin
+}
+
+// searchPins runs the query in the request against the pin index,
+// starting a build if there is none yet or the index is stale. Polls
+// from the results partial don't restart a build that failed.
+func (s *Server) searchPins(r *http.Request, retry bool) pinsData {
+	data := pinsData{Query: parsePinQuery(r.URL.Query())}
+
+	idx, ok, state := s.pins.indexOrStart(s.apiClient(r), retry)
+	data.catalogState = state
+	if ok {
+		data.Index = idx
+		if data.Query.Function != "" {
+			data.Matches, data.Devices = idx.search(data.Query)
+		}
+	}
+	return data
+}
+
+func (s *Server) handlePins(w http.ResponseWriter, r *http.Request) {
+	s.render(w, "pins.html", pageData{
+		Title:   "Pins",
+		Content: s.searchPins(r, true),
+	})
+}
+
+// handlePinsPartial refreshes the results, both when the search changes
+// and while the index is being built.
+func (s *Server) handlePinsPartial(w http.ResponseWriter, r *http.Request) {
+	poll := r.URL.Query().Get("poll") != ""
+	data := s.searchPins(r, !poll)
+	if !poll {
+		w.Header().Set("HX-Push-Url", string(data.Query.URL()))
+	}
+	s.renderPartial(w, "partials/pins-results.html", data)
+}
@@ -0,0 +1,197 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/rmrfslashbin/manuals-webui/internal/client"
+)
+
+func gpio(n int) *int { return &n }
+
+// pinoutResponses wraps pinouts for deviceAPIServer.
+func pinoutResponses(pinouts map[string][]client.PinoutPin) map[string]any {
+	responses := make(map[string]any, len(pinouts))
+	for id, pins := range pinouts {
+		responses[id] = client.PinoutResponse{DeviceID: id, Pins: pins}
+	}
+	return responses
+}
+
+func pinCatalogFixture() ([]client.Device, map[string][]client.PinoutPin) {
+	devices := []client.Device{
+		{ID: "esp32", Name: "ESP32", Domain: "hardware", Type: "mcu"},
+		{ID: "rp2040", Name: "RP2040", Domain: "hardware", Type: "mcu"},
+		{ID: "bme280", Name: "BME280", Domain: "hardware", Type: "sensors"},
+		{ID: "mqtt", Name: "MQTT", Domain: "protocol", Type: "messaging"},
+	}
+	pinouts := map[string][]client.PinoutPin{
+		"esp32": {
+			{PhysicalPin: 33, GPIONum: gpio(21), Name: "GPIO21", DefaultPull: "up", AltFunctions: []string{"I2C0_SDA", "VSPI_HD"}},
+			{PhysicalPin: 36, GPIONum: gpio(22), Name: "GPIO22", AltFunctions: []string{"I2C0_SCL", "VSPI_WP"}},
+			{PhysicalPin: 37, GPIONum: gpio(23), Name: "GPIO23", AltFunctions: []string{"VSPI_MOSI"}},
+		},
+		"rp2040": {
+			{PhysicalPin: 6, GPIONum: gpio(4), Name: "GP4", AltFunctions: []string{"I2C0 SDA", "SPI0 RX", "UART1 TX"}},
+			{PhysicalPin: 9, GPIONum: gpio(6), Name: "GP6", AltFunctions: []string{"I2C1 SDA", "SPI0 SCK"}},
+		},
+		"bme280": {
+			{PhysicalPin: 3, Name: "SDI", AltFunctions: []string{"I2C_SDA", "SPI_MOSI"}},
+		},
+	}
+	return devices, pinouts
+}
+
+func TestMatchFunction(t *testing.T) {
+	tests := []struct {
+		query, function string
+		expected        bool
+	}{
+		{"I2C_SDA", "I2C0_SDA", true},
+		{"i2c sda", "I2C1 SDA", true},
+		{"SDA", "I2C0_SDA", true},
+		{"I2C0_SDA", "I2C1_SDA", false},
+		{"I2C_SDA", "I2C0_SCL", false},
+		{"SPI", "VSPI_MOSI", false},
+		{"VSPI", "VSPI_MOSI", true},
+		{"GPIO21", "GPIO21", true},
+		{"GPIO2", "GPIO21", false},
+		{"", "GPIO21", false},
+	}
+	for _, tc := range tests {
+		if got := matchFunction(functionTokens(tc.query), functionTokens(tc.function)); got != tc.expected {
+			t.Errorf("matchFunction(%q, %q) = %v, want %v", tc.query, tc.function, got, tc.expected)
+		}
+	}
+}
+
+func TestPinIndexSearch(t *testing.T) {
+	devices, pinouts := pinCatalogFixture()
+	api, requests := deviceAPIServer(t, devices, map[string]map[string]any{"pinout": pinoutResponses(pinouts)})
+	defer api.Close()
+	s := testServer(t, api)
+
+	if !s.pins.start(client.New(api.URL, "test-key")) {
+		t.Fatal("expected the build to start")
+	}
+	if st := waitForJob(t, s.pins.job); st.Error != "" {
+		t.Fatalf("build failed: %s", st.Error)
+	}
+	if n := requests.Load(); n != 4 {
+		t.Errorf("expected one pinout request per device, got %d", n)
+	}
+	idx := s.pins.indexFor(s.client)
+	if idx == nil || idx.Devices != 3 || len(idx.Pins) != 6 {
+		t.Fatalf("expected 6 pins on 3 devices, got %+v", idx)
+	}
+
+	tests := []struct {
+		query    string
+		expected []string // device:physical pin
+		devices  int
+	}{
+		{"function=I2C_SDA", []string{"bme280:3", "esp32:33", "rp2040:6", "rp2040:9"}, 3},
+		{"function=I2C_SDA&type=mcu", []string{"esp32:33", "rp2040:6", "rp2040:9"}, 2},
+		{"function=MOSI", []string{"bme280:3", "esp32:37"}, 2},
+		{"function=UART_TX&domain=hardware", []string{"rp2040:6"}, 1},
+		{"function=CAN_TX", nil, 0},
+	}
+	for _, tc := range tests {
+		t.Run(tc.query, func(t *testing.T) {
+			v, _ := url.ParseQuery(tc.query)
+			matches, n := idx.search(parsePinQuery(v))
+			var got []string
+			for _, m := range matches {
+				got = append(got, m.Device.ID+":"+strconv.Itoa(m.Pin.PhysicalPin))
+			}
+			if !reflect.DeepEqual(got, tc.expected) || n != tc.devices {
+				t.Errorf("search = %v on %d devices, want %v on %d", got, n, tc.expected, tc.devices)
+			}
+		})
+	}
+
+	families := make(map[string]int)
+	for _, f := range idx.Families {
+		families[f.Name] = f.Devices
+	}
+	if families["I2C_SDA"] != 3 || families["SPI_MOSI"] != 1 || families["VSPI_MOSI"] != 1 {
+		t.Errorf("unexpected families %v", families)
+	}
+	if _, ok := families["GPIO"]; ok {
+		t.Error("expected plain GPIO names to be left out of the families")
+	}
+}
+
+func TestHandlePins(t *testing.T) {
+	devices, pinouts := pinCatalogFixture()
+	api, _ := deviceAPIServer(t, devices, map[string]map[string]any{"pinout": pinoutResponses(pinouts)})
+	defer api.Close()
+	s := testServer(t, api)
+
+	get := func(target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", target, nil)
+		w := httptest.NewRecorder()
+		s.Handler().ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET %s: expected status 200, got %d", target, w.Code)
+		}
+		return w
+	}
+
+	body := get("/pins?function=I2C_SDA").Body.String()
+	if !strings.Contains(body, "Loading pinouts") || !strings.Contains(body, `hx-get="/partials/pins?function=I2C_SDA&amp;poll=1"`) {
+		t.Error("expected the page to poll while the index builds")
+	}
+	waitForJob(t, s.pins.job)
+
+	body = get("/pins?function=I2C_SDA&type=mcu").Body.String()
+	for _, want := range []string{
+		`href="/devices/esp32"`,
+		`href="/devices/rp2040"`,
+		"3 pins on 2 devices",
+		`<td class="px-4 py-2 text-right text-gray-900 dark:text-gray-100">21</td>`,
+		">up<",
+		"I2C0_SDA</span>",
+		`<option value="sensors" >sensors</option>`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected page to contain %q", want)
+		}
+	}
+	if strings.Contains(body, `href="/devices/bme280"`) {
+		t.Error("expected the type filter to exclude sensors")
+	}
+
+	w := get("/partials/pins?function=SPI_MOSI")
+	if got := w.Header().Get("HX-Push-Url"); got != "/pins?function=SPI_MOSI" {
+		t.Errorf("expected HX-Push-Url to the page, got %q", got)
+	}
+
+	// Without a function the page lists the families to pick from
+	body = get("/pins?type=mcu").Body.String()
+	if !strings.Contains(body, `href="/pins?function=I2C_SDA&amp;type=mcu"`) {
+		t.Error("expected family links keeping the filters")
+	}
+}
+
+func TestReindexRebuildsPinIndex(t *testing.T) {
+	devices, pinouts := pinCatalogFixture()
+	api, requests := deviceAPIServer(t, devices, map[string]map[string]any{"pinout": pinoutResponses(pinouts)})
+	defer api.Close()
+	s := testServer(t, api)
+	c := client.New(api.URL, "test-key")
+
+	s.observeReindex(c, &client.ReindexStatus{LastRun: "t1"})
+	s.observeReindex(c, &client.ReindexStatus{LastRun: "t2"})
+	if st := waitForJob(t, s.pins.job); st.Started.IsZero() || st.Error != "" {
+		t.Fatalf("expected the pin index to be rebuilt, got %+v", st)
+	}
+	if requests.Load() != 4 || s.pins.indexFor(c) == nil {
+		t.Error("expected a fresh pin index after the reindex")
+	}
+}
@@ -0,0 +1,133 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"mime"
+	"path"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/rmrfslashbin/manuals-webui/internal/client"
+)
+
+// maxPreviewBytes caps how much of a text or markdown document is rendered.
+const maxPreviewBytes = 1 << 20
+
+// Preview kinds understood by document.html.
+const (
+	previewNone     = ""
+	previewPDF      = "pdf"
+	previewImage    = "image"
+	previewMarkdown = "markdown"
+	previewText     = "text"
+)
+
+// errPreviewTooLarge is returned when a document exceeds maxPreviewBytes.
+var errPreviewTooLarge = errors.New("document is too large to preview")
+
+// documentPreview is the rendered preview for the document page.
+type documentPreview struct {
+	Kind      string
+	HTML      template.HTML // markdown
+	Lines     []string      // text
+	Truncated bool
+	Limit     int64 // bytes shown of a truncated document
+	Error     string
+}
+
+// previewKind picks how a document is previewed from its MIME type,
+// falling back to the file extension for generic types.
+func previewKind(doc *client.Document) string {
+	mediaType, _, err := mime.ParseMediaType(doc.MimeType)
+	if err != nil {
+		mediaType = ""
+	}
+	ext := strings.ToLower(path.Ext(doc.Filename))
+
+	switch {
+	case mediaType == "application/pdf":
+		return previewPDF
+	case inlineMimeTypes[mediaType] && strings.HasPrefix(mediaType, "image/"):
+		return previewImage
+	case mediaType == "text/markdown" || mediaType == "text/x-markdown" || ext == ".md" || ext == ".markdown":
+		return previewMarkdown
+	case strings.HasPrefix(mediaType, "text/") && mediaType != "text/html":
+		return previewText
+	case mediaType == "application/json" || mediaType == "application/xml" || mediaType == "application/x-yaml":
+		return previewText
+	}
+	return previewNone
+}
+
+// buildPreview loads and renders the preview for text-based documents.
+// PDFs and images are embedded from the download proxy by the template.
+func (s *Server) buildPreview(ctx context.Context, apiClient *client.Client, doc *client.Document) documentPreview {
+	preview := documentPreview{Kind: previewKind(doc)}
+	if preview.Kind != previewMarkdown && preview.Kind != previewText {
+		return preview
+	}
+
+	content, err := s.readDocument(ctx, apiClient, doc, maxPreviewBytes)
+	if errors.Is(err, errPreviewTooLarge) {
+		preview.Truncated = true
+		preview.Limit = maxPreviewBytes
+	} else if err != nil {
+		s.logger.Warn("failed to load document preview", "id", doc.ID, "error", err)
+		preview.Error = err.Error()
+		return preview
+	}
+
+	text := string(content)
+	if preview.Truncated {
+		// Drop a multi-byte character split by the cut
+		for i := 0; i < utf8.UTFMax-1 && len(text) > 0; i++ {
+			if r, size := utf8.DecodeLastRuneInString(text); r != utf8.RuneError || size != 1 {
+				break
+			}
+			text = text[:len(text)-1]
+		}
+	}
+	text = strings.ToValidUTF8(text, "�")
+
+	if preview.Kind == previewMarkdown {
+		preview.HTML = s.mdRenderer.RenderMarkdown(text)
+		return preview
+	}
+
+	preview.Lines = strings.Split(strings.TrimSuffix(strings.ReplaceAll(text, "\r\n", "\n"), "\n"), "\n")
+	return preview
+}
+
+// readDocument returns up to limit bytes of a document, from the cache
+// when possible. errPreviewTooLarge is returned with the first limit bytes
+// when the document is longer.
+func (s *Server) readDocument(ctx context.Context, apiClient *client.Client, doc *client.Document, limit int64) ([]byte, error) {
+	var src io.Reader
+	if s.docCache != nil {
+		if f, _, ok := s.docCache.open(doc); ok {
+			defer f.Close()
+			src = f
+		}
+	}
+	if src == nil {
+		resp, err := apiClient.DownloadDocument(ctx, doc.ID, nil)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		src = resp.Body
+	}
+
+	content, err := io.ReadAll(io.LimitReader(src, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read document: %w", err)
+	}
+	if int64(len(content)) > limit {
+		return content[:limit], errPreviewTooLarge
+	}
+	return content, nil
+}
@@ -0,0 +1,122 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rmrfslashbin/manuals-webui/internal/client"
+)
+
+func TestPreviewKind(t *testing.T) {
+	tests := []struct {
+		name     string
+		doc      client.Document
+		expected string
+	}{
+		{"pdf", client.Document{Filename: "a.pdf", MimeType: "application/pdf"}, previewPDF},
+		{"png", client.Document{Filename: "a.png", MimeType: "image/png"}, previewImage},
+		{"svg not inline", client.Document{Filename: "a.svg", MimeType: "image/svg+xml"}, previewNone},
+		{"markdown", client.Document{Filename: "a.md", MimeType: "text/markdown; charset=utf-8"}, previewMarkdown},
+		{"markdown by extension", client.Document{Filename: "README.md", MimeType: "application/octet-stream"}, previewMarkdown},
+		{"plain text", client.Document{Filename: "a.txt", MimeType: "text/plain"}, previewText},
+		{"json", client.Document{Filename: "a.json", MimeType: "application/json"}, previewText},
+		{"html", client.Document{Filename: "a.html", MimeType: "text/html"}, previewNone},
+		{"binary", client.Document{Filename: "a.bin", MimeType: "application/octet-stream"}, previewNone},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := previewKind(&tc.doc); got != tc.expected {
+				t.Errorf("previewKind = %q, want %q", got, tc.expected)
+			}
+		})
+	}
+}
+
+func TestHandleDocumentPreview(t *testing.T) {
+	tests := []struct {
+		name     string
+		doc      client.Document
+		content  string
+		contains []string
+	}{
+		{
+			name:     "markdown",
+			doc:      client.Document{ID: "doc-1", DeviceID: "dev-1", Filename: "notes.md", MimeType: "text/markdown"},
+			content:  "# Wiring\n\nConnect **SDA** first.",
+			contains: []string{"<h1", "Wiring", "<strong>SDA</strong>", "/download/doc-1"},
+		},
+		{
+			name:     "text",
+			doc:      client.Document{ID: "doc-2", DeviceID: "dev-1", Filename: "pins.txt", MimeType: "text/plain"},
+			content:  "VCC 3.3V\nGND\n<script>",
+			contains: []string{`id="L3"`, "VCC 3.3V", "&lt;script&gt;"},
+		},
+		{
+			name:     "pdf",
+			doc:      client.Document{ID: "doc-3", DeviceID: "dev-1", Filename: "datasheet.pdf", MimeType: "application/pdf"},
+			content:  "%PDF-1.4",
+			contains: []string{`<iframe src="/download/doc-3?inline=1"`},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var downloads int
+			apiServer := downloadAPIServer(t, tc.doc, tc.content, &downloads)
+			defer apiServer.Close()
+
+			s := testServer(t, apiServer)
+			req := httptest.NewRequest("GET", "/documents/"+tc.doc.ID, nil)
+			w := httptest.NewRecorder()
+			s.Handler().ServeHTTP(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d", w.Code)
+			}
+			body := w.Body.String()
+			for _, want := range tc.contains {
+				if !strings.Contains(body, want) {
+					t.Errorf("expected body to contain %q", want)
+				}
+			}
+			if tc.doc.MimeType == "application/pdf" && downloads != 0 {
+				t.Errorf("expected PDF preview not to download content, got %d downloads", downloads)
+			}
+		})
+	}
+}
+
+func TestBuildPreviewTruncated(t *testing.T) {
+	var downloads int
+	content := strings.Repeat("x", maxPreviewBytes) + "tail"
+	doc := client.Document{ID: "doc-1", Filename: "big.txt", MimeType: "text/plain"}
+	apiServer := downloadAPIServer(t, doc, content, &downloads)
+	defer apiServer.Close()
+
+	s := testServer(t, apiServer)
+	preview := s.buildPreview(t.Context(), s.client, &doc)
+
+	if !preview.Truncated || preview.Limit != maxPreviewBytes {
+		t.Errorf("expected preview to be truncated to %d bytes, got %d", maxPreviewBytes, preview.Limit)
+	}
+	if len(preview.Lines) != 1 || len(preview.Lines[0]) != maxPreviewBytes {
+		t.Errorf("expected a single %d byte line", maxPreviewBytes)
+	}
+}
+
+func TestHandleDocumentNotFound(t *testing.T) {
+	apiServer := mockAPIServer(t, map[string]http.HandlerFunc{})
+	defer apiServer.Close()
+
+	s := testServer(t, apiServer)
+	req := httptest.NewRequest("GET", "/documents/missing", nil)
+	w := httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404, got %d", w.Code)
+	}
+}
@@ -0,0 +1,89 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/rmrfslashbin/manuals-webui/internal/client"
+)
+
+// reindexWatcher notices when the API finishes a reindex, so data derived
+// from the catalog can be rebuilt. Runs are recognised by their LastRun
+// timestamp, per API base URL.
+type reindexWatcher struct {
+	mu      sync.Mutex
+	lastRun map[string]string
+}
+
+func newReindexWatcher() *reindexWatcher {
+	return &reindexWatcher{lastRun: make(map[string]string)}
+}
+
+// finished records a reindex status and reports whether it shows a run
+// that completed since the previous observation. The first observation
+// only sets the baseline; caches built before it are expired by their TTL.
+func (rw *reindexWatcher) finished(apiURL string, status *client.ReindexStatus) bool {
+	if status == nil || status.Running || status.LastRun == "" {
+		return false
+	}
+
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	last, seen := rw.lastRun[apiURL]
+	rw.lastRun[apiURL] = status.LastRun
+	return seen && last != status.LastRun
+}
+
+// observeReindex refreshes catalog-derived data when status shows a newly
+// finished reindex.
+func (s *Server) observeReindex(c *client.Client, status *client.ReindexStatus) {
+	if !s.reindex.finished(c.BaseURL(), status) {
+		return
+	}
+	s.logger.Info("reindex finished, refreshing catalog data", "last_run", status.LastRun)
+
+	s.documentList.invalidate()
+	s.facets.refresh(c)
+	s.pins.start(c)
+	// Only rebuild reports someone has looked at
+	if s.duplicates.indexFor(c) != nil {
+		s.duplicates.start(c)
+	}
+	if s.specs.indexFor(c) != nil {
+		s.specs.start(c)
+	}
+}
+
+// watchReindex polls the reindex status of c's API every interval until
+// the server is closed, so catalog data is refreshed after reindexes that
+// nobody followed on the admin page. It stops if the API key may not read
+// the status.
+func (s *Server) watchReindex(c *client.Client, every time.Duration) {
+	s.bgWG.Add(1)
+	go func() {
+		defer s.bgWG.Done()
+		ticker := time.NewTicker(every)
+		defer ticker.Stop()
+		for {
+			status, err := c.GetReindexStatus()
+			var apiErr *client.APIError
+			switch {
+			case errors.As(err, &apiErr) && (apiErr.StatusCode == http.StatusUnauthorized || apiErr.StatusCode == http.StatusForbidden):
+				s.logger.Warn("API key cannot read the reindex status, not watching for reindexes", "error", err)
+				return
+			case err != nil:
+				s.logger.Debug("failed to poll reindex status", "error", err)
+			default:
+				s.observeReindex(c, status)
+			}
+
+			select {
+			case <-ticker.C:
+			case <-s.bgCtx.Done():
+				return
+			}
+		}
+	}()
+}
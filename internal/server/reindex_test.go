@@ -0,0 +1,163 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rmrfslashbin/manuals-webui/internal/client"
+)
+
+func TestReindexWatcher(t *testing.T) {
+	rw := newReindexWatcher()
+	status := func(running bool, lastRun string) *client.ReindexStatus {
+		return &client.ReindexStatus{Running: running, LastRun: lastRun}
+	}
+
+	steps := []struct {
+		api      string
+		status   *client.ReindexStatus
+		finished bool
+	}{
+		{"a", status(false, "t1"), false}, // baseline
+		{"a", status(false, "t1"), false}, // unchanged
+		{"a", status(true, "t1"), false},  // running
+		{"a", status(false, "t2"), true},  // new run finished
+		{"b", status(false, "t2"), false}, // other API has its own baseline
+		{"a", nil, false},
+	}
+	for i, step := range steps {
+		if got := rw.finished(step.api, step.status); got != step.finished {
+			t.Errorf("step %d: finished = %v, want %v", i, got, step.finished)
+		}
+	}
+}
+
+func TestReindexStatusRefreshesFacets(t *testing.T) {
+	var mu sync.Mutex
+	lastRun := "t1"
+	var deviceLists int
+
+	apiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/settings"):
+			json.NewEncoder(w).Encode(client.SettingsResponse{})
+		case strings.HasSuffix(r.URL.Path, "/reindex/status"):
+			json.NewEncoder(w).Encode(client.ReindexStatus{LastRun: lastRun, LastStatus: "success"})
+		case strings.HasSuffix(r.URL.Path, "/devices"):
+			deviceLists++
+			json.NewEncoder(w).Encode(client.DevicesResponse{Data: facetCatalog(), Total: len(facetCatalog())})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer apiServer.Close()
+	s := testServer(t, apiServer)
+
+	poll := func() {
+		w := httptest.NewRecorder()
+		s.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/admin/reindex/status", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", w.Code)
+		}
+	}
+
+	poll()
+	if st := s.facets.job.snapshot(); !st.Started.IsZero() {
+		t.Error("expected no refresh for the first observation")
+	}
+
+	mu.Lock()
+	lastRun = "t2"
+	mu.Unlock()
+	poll()
+	if st := waitForJob(t, s.facets.job); st.Started.IsZero() || st.Error != "" {
+		t.Fatalf("expected a successful facet refresh, got %+v", st)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if deviceLists != 1 {
+		t.Errorf("expected the refresh to list devices once, got %d", deviceLists)
+	}
+}
+
+func TestWatchReindex(t *testing.T) {
+	var mu sync.Mutex
+	lastRun := "t1"
+	var polls atomic.Int32
+	apiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/reindex/status"):
+			polls.Add(1)
+			json.NewEncoder(w).Encode(client.ReindexStatus{LastRun: lastRun, LastStatus: "success"})
+		case strings.HasSuffix(r.URL.Path, "/devices"):
+			json.NewEncoder(w).Encode(client.DevicesResponse{Data: facetCatalog(), Total: len(facetCatalog())})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer apiServer.Close()
+	s, err := New(Config{
+		Client:              client.New(apiServer.URL, "test-key"),
+		Logger:              slog.New(slog.NewTextHandler(io.Discard, nil)),
+		ReindexPollInterval: 10 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer s.Close()
+
+	// Nobody opens the admin page; the poller alone notices the new run
+	for polls.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	mu.Lock()
+	lastRun = "t2"
+	mu.Unlock()
+	deadline := time.Now().Add(5 * time.Second)
+	for s.facets.job.snapshot().Started.IsZero() {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the poller to refresh the facets")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	waitForJob(t, s.facets.job)
+	waitForJob(t, s.pins.job)
+}
+
+func TestWatchReindexStopsWhenForbidden(t *testing.T) {
+	var polls atomic.Int32
+	apiServer := mockAPIServer(t, map[string]http.HandlerFunc{
+		"/api/" + client.APIVersion + "/rw/reindex/status": func(w http.ResponseWriter, r *http.Request) {
+			polls.Add(1)
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(map[string]string{"error": "missing capability"})
+		},
+	})
+	defer apiServer.Close()
+	s, err := New(Config{
+		Client:              client.New(apiServer.URL, "test-key"),
+		Logger:              slog.New(slog.NewTextHandler(io.Discard, nil)),
+		ReindexPollInterval: time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer s.Close()
+
+	time.Sleep(50 * time.Millisecond)
+	if n := polls.Load(); n != 1 {
+		t.Errorf("expected polling to stop after a forbidden response, got %d polls", n)
+	}
+}
@@ -2,12 +2,17 @@
 package server
 
 import (
+	"context"
 	"embed"
 	"fmt"
 	"html/template"
 	"io/fs"
 	"log/slog"
 	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
 
 	"github.com/rmrfslashbin/manuals-webui/internal/client"
 )
@@ -22,28 +27,203 @@ var staticFS embed.FS
 type Config struct {
 	Client *client.Client
 	Logger *slog.Logger
+
+	// AllowedAPIURLs lists the API URLs browsers may bind their session to
+	// via the setup flow. The default client's URL is always permitted.
+	AllowedAPIURLs []string
+
+	// SessionTTL is how long a configured browser session stays valid.
+	SessionTTL time.Duration
+
+	// DevTemplatesDir, if set, loads templates from this directory instead
+	// of the embedded copies and reloads them whenever a file changes.
+	DevTemplatesDir string
+
+	// TemplatesDir, if set, is an overlay directory whose files shadow the
+	// built-in templates of the same name.
+	TemplatesDir string
+
+	// Branding customizes the site title, logo, accent color and footer.
+	Branding Branding
+
+	// CacheDir, if set, enables an on-disk cache of downloaded documents.
+	CacheDir string
+
+	// CacheMaxBytes caps the size of the document cache.
+	CacheMaxBytes int64
+
+	// LinkCheckInterval, if set, checks the default API's reference links
+	// this often in the background.
+	LinkCheckInterval time.Duration
+
+	// LinkCheckAllowPrivate lets the link checker request private, loopback
+	// and link-local addresses, e.g. for an intranet catalog.
+	LinkCheckAllowPrivate bool
+
+	// ReindexPollInterval, if set, checks the default API's reindex status
+	// this often so catalog data is refreshed after a reindex.
+	ReindexPollInterval time.Duration
+
+	// MetadataKeys are the device metadata key paths shown in the device
+	// page header. Admins can change them at runtime.
+	MetadataKeys []string
+
+	// SaveMetadataKeys, if set, persists metadata keys changed by an
+	// admin. Without it, changes last until the server restarts.
+	SaveMetadataKeys func([]string) error
 }
 
 // Server is the web UI server.
 type Server struct {
-	client       *client.Client
-	logger       *slog.Logger
-	baseTemplate *template.Template
-	funcMap      template.FuncMap
-	mdRenderer   *MarkdownRenderer
+	client         *client.Client
+	logger         *slog.Logger
+	templates      *templateRegistry
+	funcMap        template.FuncMap
+	mdRenderer     *MarkdownRenderer
+	highlightCSS   []byte
+	sessions       *sessionStore
+	allowedAPIURLs []string
+	docCache       *documentCache
+	thumbs         *thumbnailer
+	devices        *deviceDirectory
+	documentList   *listingCache[*documentListing]
+	facets         *facetService
+	reindex        *reindexWatcher
+	integrity      *integrityChecker
+	duplicates     *catalogIndex[*duplicateReport]
+	specs          *catalogIndex[*specIndex]
+	pins           *catalogIndex[*pinIndex]
+	health         *healthChecker
+	links          *linkChecker
+	metadata       *metadataSettings
+
+	// Background jobs run under bgCtx and are tracked by bgWG so Close
+	// can stop them.
+	bgCtx    context.Context
+	bgCancel context.CancelFunc
+	bgWG     sync.WaitGroup
 }
 
-// New creates a new server instance.
-func New(cfg Config) *Server {
+// New creates a new server instance. All templates are parsed up front, so
+// a broken template is reported here rather than on first request.
+func New(cfg Config) (*Server, error) {
 	// Initialize markdown renderer
 	mdRenderer := newMarkdownRenderer()
+	codeCSS, err := highlightCSS()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build code highlighting styles: %w", err)
+	}
+
+	branding := cfg.Branding
+	if err := branding.validate(); err != nil {
+		return nil, err
+	}
 
 	// Create function map for templates
-	funcMap := template.FuncMap{
-		"formatBytes":    formatBytes,
-		"truncate":       truncate,
-		"add":            func(a, b int) int { return a + b },
-		"multiply":       func(a, b interface{}) float64 {
+	funcMap := newFuncMap(mdRenderer, branding)
+
+	// Parse every page and partial once
+	var builtinFS fs.FS
+	if cfg.DevTemplatesDir != "" {
+		builtinFS = os.DirFS(cfg.DevTemplatesDir)
+		cfg.Logger.Info("dev mode: loading templates from disk", "dir", cfg.DevTemplatesDir)
+	} else {
+		builtinFS, _ = fs.Sub(templatesFS, "templates")
+	}
+	templateFS := builtinFS
+	if cfg.TemplatesDir != "" {
+		templateFS = overlayFS{upper: os.DirFS(cfg.TemplatesDir), lower: builtinFS}
+		cfg.Logger.Info("using template overrides", "dir", cfg.TemplatesDir)
+	}
+	templates, err := newTemplateRegistry(templateFS, funcMap, cfg.DevTemplatesDir != "")
+	if err != nil {
+		return nil, err
+	}
+	if cfg.TemplatesDir != "" {
+		if err := validateTemplateOverrides(os.DirFS(cfg.TemplatesDir), builtinFS, templates); err != nil {
+			return nil, err
+		}
+	}
+
+	// Build the setup allowlist, always including the default API URL
+	var allowed []string
+	for _, raw := range append([]string{cfg.Client.BaseURL()}, cfg.AllowedAPIURLs...) {
+		normalized, err := normalizeAPIURL(raw)
+		if err != nil {
+			cfg.Logger.Warn("ignoring invalid allowed API URL", "url", raw, "error", err)
+			continue
+		}
+		allowed = append(allowed, normalized)
+	}
+
+	var docCache *documentCache
+	if cfg.CacheDir != "" {
+		docCache, err = newDocumentCache(cfg.CacheDir, cfg.CacheMaxBytes, cfg.Logger)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Logger.Info("document cache enabled", "dir", cfg.CacheDir, "max_bytes", cfg.CacheMaxBytes)
+	}
+
+	// Thumbnails are small and keyed by checksum, so they live beside the
+	// document cache without counting against its size limit
+	var thumbDir string
+	if cfg.CacheDir != "" {
+		thumbDir = filepath.Join(cfg.CacheDir, "thumbnails")
+	}
+	thumbs, err := newThumbnailer(thumbDir)
+	if err != nil {
+		return nil, err
+	}
+
+	bgCtx, bgCancel := context.WithCancel(context.Background())
+
+	s := &Server{
+		client:         cfg.Client,
+		logger:         cfg.Logger,
+		templates:      templates,
+		funcMap:        funcMap,
+		mdRenderer:     mdRenderer,
+		highlightCSS:   codeCSS,
+		sessions:       newSessionStore(cfg.SessionTTL),
+		allowedAPIURLs: allowed,
+		docCache:       docCache,
+		thumbs:         thumbs,
+		devices:        newDeviceDirectory(),
+		documentList:   newListingCache[*documentListing](documentListingTTL),
+		metadata:       &metadataSettings{keys: cfg.MetadataKeys, save: cfg.SaveMetadataKeys},
+		bgCtx:          bgCtx,
+		bgCancel:       bgCancel,
+	}
+	s.integrity = newIntegrityChecker(s)
+	s.duplicates = newDuplicateFinder(s)
+	s.facets = newFacetService(s, s.devices)
+	s.specs = newSpecCatalog(s)
+	s.pins = newPinCatalog(s)
+	s.health = newHealthChecker(s)
+	s.links = newLinkChecker(s, cfg.LinkCheckAllowPrivate)
+	s.reindex = newReindexWatcher()
+
+	if cfg.LinkCheckInterval > 0 {
+		s.links.schedule(cfg.Client, cfg.LinkCheckInterval)
+		cfg.Logger.Info("scheduled link checks enabled", "interval", cfg.LinkCheckInterval)
+	}
+	if cfg.ReindexPollInterval > 0 {
+		s.watchReindex(cfg.Client, cfg.ReindexPollInterval)
+	}
+
+	return s, nil
+}
+
+// newFuncMap returns the functions available to all templates.
+func newFuncMap(mdRenderer *MarkdownRenderer, branding Branding) template.FuncMap {
+	return template.FuncMap{
+		"brand":        func() Branding { return branding },
+		"formatBytes":  formatBytes,
+		"truncate":     truncate,
+		"hasThumbnail": hasThumbnail,
+		"add":          func(a, b int) int { return a + b },
+		"multiply": func(a, b interface{}) float64 {
 			// Handle different numeric types for template multiplication
 			var af, bf float64
 			switch v := a.(type) {
@@ -71,17 +251,6 @@ func New(cfg Config) *Server {
 		"markdown":       mdRenderer.RenderMarkdown,
 		"markdownInline": mdRenderer.RenderMarkdownInline,
 	}
-
-	// Parse base template and all partials
-	baseTemplate := template.Must(template.New("").Funcs(funcMap).ParseFS(templatesFS, "templates/base.html", "templates/partials/*.html"))
-
-	return &Server{
-		client:       cfg.Client,
-		logger:       cfg.Logger,
-		baseTemplate: baseTemplate,
-		funcMap:      funcMap,
-		mdRenderer:   mdRenderer,
-	}
 }
 
 // Handler returns the HTTP handler for the server.
@@ -91,27 +260,50 @@ func (s *Server) Handler() http.Handler {
 	// Static files
 	staticContent, _ := fs.Sub(staticFS, "static")
 	mux.Handle("GET /static/", http.StripPrefix("/static/", http.FileServer(http.FS(staticContent))))
+	mux.HandleFunc("GET /static/highlight.css", s.handleHighlightCSS)
 
 	// Health check proxy (no auth required)
 	mux.HandleFunc("GET /health", s.handleHealth)
 
 	// Configuration pages
 	mux.HandleFunc("GET /setup", s.handleSetup)
+	mux.HandleFunc("POST /setup", s.handleSetupSubmit)
+	mux.HandleFunc("POST /logout", s.handleLogout)
 	mux.HandleFunc("GET /settings", s.handleSettings)
 
 	// Pages
 	mux.HandleFunc("GET /", s.handleHome)
 	mux.HandleFunc("GET /devices", s.handleDevices)
+	mux.HandleFunc("GET /devices/find", s.handleDeviceFind)
 	mux.HandleFunc("GET /devices/{id}", s.handleDevice)
+	mux.HandleFunc("GET /devices/{id}/documents.zip", s.handleDeviceArchive)
+	mux.HandleFunc("GET /devices/{id}/compare/{other}", s.handleDeviceCompare)
+	for _, e := range pinoutExporters {
+		mux.HandleFunc("GET /devices/{id}/pinout."+e.Ext, s.handlePinoutExport(e))
+	}
 	mux.HandleFunc("GET /search", s.handleSearch)
 	mux.HandleFunc("GET /documents", s.handleDocuments)
+	mux.HandleFunc("GET /documents/{id}", s.handleDocument)
+	mux.HandleFunc("GET /guides", s.handleGuides)
+	mux.HandleFunc("GET /guides/{id}", s.handleGuide)
+	mux.HandleFunc("GET /pins", s.handlePins)
+	mux.HandleFunc("GET /wiring", s.handleWiring)
+	mux.HandleFunc("GET /wiring.csv", s.handleWiringDownload("csv"))
+	mux.HandleFunc("GET /wiring.md", s.handleWiringDownload("md"))
 
 	// htmx partials
 	mux.HandleFunc("GET /partials/devices", s.handleDevicesPartial)
+	mux.HandleFunc("GET /partials/devices/find", s.handleDeviceFindPartial)
+	mux.HandleFunc("GET /partials/devices/{id}/replacements", s.handleDeviceReplacements)
+	mux.HandleFunc("GET /partials/devices/{id}/references", s.handleDeviceReferences)
+	mux.HandleFunc("GET /partials/pins", s.handlePinsPartial)
+	mux.HandleFunc("GET /partials/documents", s.handleDocumentsPartial)
+	mux.HandleFunc("GET /partials/documents/{id}/copies", s.handleDocumentCopies)
 	mux.HandleFunc("GET /partials/search-results", s.handleSearchResultsPartial)
 
 	// Document proxy (to add auth header)
 	mux.HandleFunc("GET /download/{id}", s.handleDownload)
+	mux.HandleFunc("GET /thumbnails/{id}", s.handleThumbnail)
 
 	// Admin pages
 	mux.HandleFunc("GET /admin", s.handleAdmin)
@@ -124,6 +316,24 @@ func (s *Server) Handler() http.Handler {
 	mux.HandleFunc("GET /admin/reindex", s.handleAdminReindex)
 	mux.HandleFunc("POST /admin/reindex", s.handleAdminTriggerReindex)
 	mux.HandleFunc("GET /admin/reindex/status", s.handleAdminReindexStatus)
+	mux.HandleFunc("GET /admin/cache", s.requireAdmin(s.handleAdminCache))
+	mux.HandleFunc("POST /admin/cache/purge", s.requireAdmin(s.handleAdminCachePurge))
+	mux.HandleFunc("GET /admin/integrity", s.requireAdmin(s.handleAdminIntegrity))
+	mux.HandleFunc("POST /admin/integrity", s.requireAdmin(s.handleAdminStartIntegrity))
+	mux.HandleFunc("GET /admin/integrity/status", s.requireAdmin(s.handleAdminIntegrityStatus))
+	mux.HandleFunc("GET /admin/duplicates", s.requireAdmin(s.handleAdminDuplicates))
+	mux.HandleFunc("POST /admin/duplicates", s.requireAdmin(s.handleAdminStartDuplicates))
+	mux.HandleFunc("GET /admin/duplicates/status", s.requireAdmin(s.handleAdminDuplicatesStatus))
+	mux.HandleFunc("GET /admin/duplicates.csv", s.requireAdmin(s.handleAdminDuplicatesCSV))
+	mux.HandleFunc("GET /admin/health", s.requireAdmin(s.handleAdminHealth))
+	mux.HandleFunc("POST /admin/health", s.requireAdmin(s.handleAdminStartHealth))
+	mux.HandleFunc("GET /admin/health/status", s.requireAdmin(s.handleAdminHealthStatus))
+	mux.HandleFunc("GET /admin/health.csv", s.requireAdmin(s.handleAdminHealthCSV))
+	mux.HandleFunc("GET /admin/links", s.requireAdmin(s.handleAdminLinks))
+	mux.HandleFunc("POST /admin/links", s.requireAdmin(s.handleAdminStartLinks))
+	mux.HandleFunc("GET /admin/links/status", s.requireAdmin(s.handleAdminLinksStatus))
+	mux.HandleFunc("GET /admin/metadata", s.requireAdmin(s.handleAdminMetadata))
+	mux.HandleFunc("POST /admin/metadata", s.requireAdmin(s.handleAdminUpdateMetadata))
 
 	return s.loggingMiddleware(mux)
 }
@@ -135,6 +345,19 @@ func (s *Server) loggingMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+// requireAdmin only lets a request through if its API credentials can read
+// the admin settings. Admin pages backed by local state rather than an API
+// call use it in place of the API's own permission check.
+func (s *Server) requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if _, err := s.apiClient(r).ListSettings(); err != nil {
+			s.renderError(w, r, "Admin access required", err)
+			return
+		}
+		next(w, r)
+	}
+}
+
 // Helper functions for templates
 func formatBytes(b int64) string {
 	const unit = 1024
@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strconv"
 	"strings"
 	"testing"
 
@@ -33,10 +34,14 @@ func mockAPIServer(t *testing.T, handlers map[string]http.HandlerFunc) *httptest
 func testServer(t *testing.T, apiServer *httptest.Server) *Server {
 	t.Helper()
 	apiClient := client.New(apiServer.URL, "test-key")
-	return New(Config{
+	s, err := New(Config{
 		Client: apiClient,
 		Logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
 	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	return s
 }
 
 func TestFormatBytes(t *testing.T) {
@@ -96,10 +101,13 @@ func TestNew(t *testing.T) {
 	defer apiServer.Close()
 
 	apiClient := client.New(apiServer.URL, "test-key")
-	s := New(Config{
+	s, err := New(Config{
 		Client: apiClient,
 		Logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
 	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
 
 	if s == nil {
 		t.Fatal("expected non-nil server")
@@ -110,8 +118,8 @@ func TestNew(t *testing.T) {
 	if s.logger == nil {
 		t.Error("expected logger to be set")
 	}
-	if s.baseTemplate == nil {
-		t.Error("expected baseTemplate to be set")
+	if s.templates == nil {
+		t.Error("expected templates to be set")
 	}
 	if s.funcMap == nil {
 		t.Error("expected funcMap to be set")
@@ -284,6 +292,11 @@ func TestHandleDevices(t *testing.T) {
 func TestHandleDevicesWithFilters(t *testing.T) {
 	apiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if strings.Contains(r.URL.Path, "/devices") {
+			// The facet service pages through every device unfiltered
+			if r.URL.Query().Get("limit") == strconv.Itoa(devicePageSize) {
+				json.NewEncoder(w).Encode(client.DevicesResponse{Total: 0})
+				return
+			}
 			// Verify query params were passed
 			if r.URL.Query().Get("domain") != "hardware" {
 				t.Errorf("expected domain=hardware, got %s", r.URL.Query().Get("domain"))
@@ -417,6 +430,9 @@ func TestHandleDocuments(t *testing.T) {
 	if w.Code != http.StatusOK {
 		t.Errorf("expected status 200, got %d", w.Code)
 	}
+	if !strings.Contains(w.Body.String(), `href="/documents/doc-1"`) {
+		t.Error("expected documents to link to their detail page")
+	}
 }
 
 func TestHandleDocumentsWithPagination(t *testing.T) {
@@ -486,8 +502,8 @@ func TestHandleDevicesPartialError(t *testing.T) {
 
 	s.handleDevicesPartial(w, req)
 
-	if w.Code != http.StatusInternalServerError {
-		t.Errorf("expected status 500, got %d", w.Code)
+	if w.Code != http.StatusBadGateway {
+		t.Errorf("expected status 502, got %d", w.Code)
 	}
 }
 
@@ -551,30 +567,24 @@ func TestHandleSearchResultsPartialError(t *testing.T) {
 
 	s.handleSearchResultsPartial(w, req)
 
-	if w.Code != http.StatusInternalServerError {
-		t.Errorf("expected status 500, got %d", w.Code)
+	if w.Code != http.StatusBadGateway {
+		t.Errorf("expected status 502, got %d", w.Code)
 	}
 }
 
 func TestHandleDownload(t *testing.T) {
-	// Create a mock file server to simulate document download
-	fileServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if strings.Contains(r.URL.Path, "/download") {
+	apiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/documents/doc-123/download") {
 			w.Header().Set("Content-Type", "application/pdf")
 			w.Write([]byte("PDF content"))
 			return
 		}
-		w.WriteHeader(http.StatusNotFound)
-	}))
-	defer fileServer.Close()
-
-	apiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if strings.Contains(r.URL.Path, "/documents/doc-123") && !strings.Contains(r.URL.Path, "/download") {
+		if strings.HasSuffix(r.URL.Path, "/documents/doc-123") {
 			json.NewEncoder(w).Encode(client.Document{
 				ID:        "doc-123",
 				Filename:  "test.pdf",
 				MimeType:  "application/pdf",
-				SizeBytes: 1024,
+				SizeBytes: 11,
 			})
 			return
 		}
@@ -590,9 +600,15 @@ func TestHandleDownload(t *testing.T) {
 
 	s.handleDownload(w, req)
 
-	// We can't fully test this since it needs to proxy to API server
-	// but we can verify it tries to get the document metadata first
-	// The actual download would fail since it tries to hit the real download URL
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", w.Code)
+	}
+	if w.Body.String() != "PDF content" {
+		t.Errorf("expected proxied body, got %q", w.Body.String())
+	}
+	if got := w.Header().Get("Content-Disposition"); got != `attachment; filename=test.pdf` {
+		t.Errorf("unexpected Content-Disposition %q", got)
+	}
 }
 
 func TestHandleDownloadNotFound(t *testing.T) {
@@ -643,7 +659,7 @@ func TestHandleAdminUsers(t *testing.T) {
 	apiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if strings.Contains(r.URL.Path, "/admin/users") {
 			json.NewEncoder(w).Encode(client.UsersResponse{
-				Users: []client.User{{ID: "user-1", Name: "admin", Role: "admin"}},
+				Users: []client.User{{ID: "user-1", Name: "admin", Capabilities: []string{"*"}}},
 			})
 			return
 		}
@@ -668,14 +684,14 @@ func TestHandleAdminCreateUser(t *testing.T) {
 		if r.Method == "POST" && strings.Contains(r.URL.Path, "/admin/users") {
 			w.WriteHeader(http.StatusCreated)
 			json.NewEncoder(w).Encode(client.CreateUserResponse{
-				User:   client.User{ID: "new-user", Name: "testuser", Role: "rw"},
+				User:   client.User{ID: "new-user", Name: "testuser", Capabilities: []string{"write:reindex"}},
 				APIKey: "mapi_newkey",
 			})
 			return
 		}
 		if r.Method == "GET" && strings.Contains(r.URL.Path, "/admin/users") {
 			json.NewEncoder(w).Encode(client.UsersResponse{
-				Users: []client.User{{ID: "new-user", Name: "testuser", Role: "rw"}},
+				Users: []client.User{{ID: "new-user", Name: "testuser", Capabilities: []string{"write:reindex"}}},
 			})
 			return
 		}
@@ -687,7 +703,7 @@ func TestHandleAdminCreateUser(t *testing.T) {
 
 	form := url.Values{}
 	form.Set("name", "testuser")
-	form.Set("role", "rw")
+	form.Set("preset", "contributor")
 	req := httptest.NewRequest("POST", "/admin/users", strings.NewReader(form.Encode()))
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 	w := httptest.NewRecorder()
@@ -706,7 +722,7 @@ func TestHandleAdminCreateUserMissingName(t *testing.T) {
 	s := testServer(t, apiServer)
 
 	form := url.Values{}
-	form.Set("role", "rw")
+	form.Set("preset", "contributor")
 	req := httptest.NewRequest("POST", "/admin/users", strings.NewReader(form.Encode()))
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 	w := httptest.NewRecorder()
@@ -832,7 +848,7 @@ func TestHandleAdminUpdateSetting(t *testing.T) {
 
 func TestHandleAdminReindex(t *testing.T) {
 	apiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if strings.Contains(r.URL.Path, "/admin/reindex/status") {
+		if strings.Contains(r.URL.Path, "/rw/reindex/status") {
 			json.NewEncoder(w).Encode(client.ReindexStatus{
 				Running:    false,
 				LastStatus: "completed",
@@ -857,11 +873,11 @@ func TestHandleAdminReindex(t *testing.T) {
 
 func TestHandleAdminTriggerReindex(t *testing.T) {
 	apiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.Method == "POST" && strings.HasSuffix(r.URL.Path, "/admin/reindex") {
+		if r.Method == "POST" && strings.HasSuffix(r.URL.Path, "/rw/reindex") {
 			w.WriteHeader(http.StatusOK)
 			return
 		}
-		if strings.Contains(r.URL.Path, "/admin/reindex/status") {
+		if strings.Contains(r.URL.Path, "/rw/reindex/status") {
 			json.NewEncoder(w).Encode(client.ReindexStatus{Running: true})
 			return
 		}
@@ -876,15 +892,14 @@ func TestHandleAdminTriggerReindex(t *testing.T) {
 
 	s.handleAdminTriggerReindex(w, req)
 
-	// Partial template rendering may fail in test context due to template naming
-	if w.Code != http.StatusOK && w.Code != http.StatusInternalServerError {
-		t.Errorf("expected status 200 or 500, got %d", w.Code)
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", w.Code)
 	}
 }
 
 func TestHandleAdminReindexStatus(t *testing.T) {
 	apiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if strings.Contains(r.URL.Path, "/admin/reindex/status") {
+		if strings.Contains(r.URL.Path, "/rw/reindex/status") {
 			json.NewEncoder(w).Encode(client.ReindexStatus{
 				Running:      false,
 				LastRun:      "2024-01-01T12:00:00Z",
@@ -905,9 +920,8 @@ func TestHandleAdminReindexStatus(t *testing.T) {
 
 	s.handleAdminReindexStatus(w, req)
 
-	// Partial template rendering may fail in test context due to template naming
-	if w.Code != http.StatusOK && w.Code != http.StatusInternalServerError {
-		t.Errorf("expected status 200 or 500, got %d", w.Code)
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", w.Code)
 	}
 }
 
@@ -1064,15 +1078,15 @@ func TestHandleAdminCreateUserError(t *testing.T) {
 
 	form := url.Values{}
 	form.Set("name", "testuser")
-	form.Set("role", "invalid")
+	form.Set("preset", "invalid")
 	req := httptest.NewRequest("POST", "/admin/users", strings.NewReader(form.Encode()))
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 	w := httptest.NewRecorder()
 
 	s.handleAdminCreateUser(w, req)
 
-	if w.Code != http.StatusInternalServerError {
-		t.Errorf("expected status 500, got %d", w.Code)
+	if w.Code != http.StatusBadGateway {
+		t.Errorf("expected status 502, got %d", w.Code)
 	}
 }
 
@@ -1098,15 +1112,15 @@ func TestHandleAdminCreateUserListError(t *testing.T) {
 
 	form := url.Values{}
 	form.Set("name", "testuser")
-	form.Set("role", "rw")
+	form.Set("preset", "contributor")
 	req := httptest.NewRequest("POST", "/admin/users", strings.NewReader(form.Encode()))
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 	w := httptest.NewRecorder()
 
 	s.handleAdminCreateUser(w, req)
 
-	if w.Code != http.StatusInternalServerError {
-		t.Errorf("expected status 500, got %d", w.Code)
+	if w.Code != http.StatusBadGateway {
+		t.Errorf("expected status 502, got %d", w.Code)
 	}
 }
 
@@ -1125,8 +1139,8 @@ func TestHandleAdminDeleteUserError(t *testing.T) {
 
 	s.handleAdminDeleteUser(w, req)
 
-	if w.Code != http.StatusInternalServerError {
-		t.Errorf("expected status 500, got %d", w.Code)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("expected status 403, got %d", w.Code)
 	}
 }
 
@@ -1151,8 +1165,8 @@ func TestHandleAdminDeleteUserListError(t *testing.T) {
 
 	s.handleAdminDeleteUser(w, req)
 
-	if w.Code != http.StatusInternalServerError {
-		t.Errorf("expected status 500, got %d", w.Code)
+	if w.Code != http.StatusBadGateway {
+		t.Errorf("expected status 502, got %d", w.Code)
 	}
 }
 
@@ -1171,8 +1185,8 @@ func TestHandleAdminRotateKeyError(t *testing.T) {
 
 	s.handleAdminRotateKey(w, req)
 
-	if w.Code != http.StatusInternalServerError {
-		t.Errorf("expected status 500, got %d", w.Code)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404, got %d", w.Code)
 	}
 }
 
@@ -1197,8 +1211,8 @@ func TestHandleAdminRotateKeyListError(t *testing.T) {
 
 	s.handleAdminRotateKey(w, req)
 
-	if w.Code != http.StatusInternalServerError {
-		t.Errorf("expected status 500, got %d", w.Code)
+	if w.Code != http.StatusBadGateway {
+		t.Errorf("expected status 502, got %d", w.Code)
 	}
 }
 
@@ -1235,8 +1249,8 @@ func TestHandleAdminUpdateSettingError(t *testing.T) {
 
 	s.handleAdminUpdateSetting(w, req)
 
-	if w.Code != http.StatusInternalServerError {
-		t.Errorf("expected status 500, got %d", w.Code)
+	if w.Code != http.StatusBadGateway {
+		t.Errorf("expected status 502, got %d", w.Code)
 	}
 }
 
@@ -1264,8 +1278,8 @@ func TestHandleAdminUpdateSettingListError(t *testing.T) {
 
 	s.handleAdminUpdateSetting(w, req)
 
-	if w.Code != http.StatusInternalServerError {
-		t.Errorf("expected status 500, got %d", w.Code)
+	if w.Code != http.StatusBadGateway {
+		t.Errorf("expected status 502, got %d", w.Code)
 	}
 }
 
@@ -1298,8 +1312,8 @@ func TestHandleAdminTriggerReindexError(t *testing.T) {
 
 	s.handleAdminTriggerReindex(w, req)
 
-	if w.Code != http.StatusInternalServerError {
-		t.Errorf("expected status 500, got %d", w.Code)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("expected status 403, got %d", w.Code)
 	}
 }
 
@@ -1323,8 +1337,8 @@ func TestHandleAdminTriggerReindexStatusError(t *testing.T) {
 
 	s.handleAdminTriggerReindex(w, req)
 
-	if w.Code != http.StatusInternalServerError {
-		t.Errorf("expected status 500, got %d", w.Code)
+	if w.Code != http.StatusBadGateway {
+		t.Errorf("expected status 502, got %d", w.Code)
 	}
 }
 
@@ -1342,8 +1356,8 @@ func TestHandleAdminReindexStatusError(t *testing.T) {
 
 	s.handleAdminReindexStatus(w, req)
 
-	if w.Code != http.StatusInternalServerError {
-		t.Errorf("expected status 500, got %d", w.Code)
+	if w.Code != http.StatusBadGateway {
+		t.Errorf("expected status 502, got %d", w.Code)
 	}
 }
 
@@ -0,0 +1,232 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rmrfslashbin/manuals-webui/internal/client"
+)
+
+const (
+	// sessionCookieName is the cookie that carries the browser's session ID.
+	sessionCookieName = "manuals_session"
+
+	// defaultSessionTTL is used when Config.SessionTTL is zero.
+	defaultSessionTTL = 12 * time.Hour
+)
+
+// session binds API credentials submitted through the setup flow to a
+// dedicated API client.
+type session struct {
+	client    *client.Client
+	apiURL    string
+	anonymous bool
+	expires   time.Time
+}
+
+// sessionStore keeps sessions in memory, keyed by a random session ID.
+type sessionStore struct {
+	mu       sync.Mutex
+	sessions map[string]*session
+	ttl      time.Duration
+}
+
+// newSessionStore creates an empty session store.
+func newSessionStore(ttl time.Duration) *sessionStore {
+	if ttl <= 0 {
+		ttl = defaultSessionTTL
+	}
+	return &sessionStore{
+		sessions: make(map[string]*session),
+		ttl:      ttl,
+	}
+}
+
+// create stores a new session and returns its ID.
+func (st *sessionStore) create(c *client.Client, apiURL string, anonymous bool) (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate session ID: %w", err)
+	}
+	id := hex.EncodeToString(buf)
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	// Drop expired sessions so abandoned browsers don't accumulate
+	now := time.Now()
+	for k, sess := range st.sessions {
+		if now.After(sess.expires) {
+			delete(st.sessions, k)
+		}
+	}
+
+	st.sessions[id] = &session{
+		client:    c,
+		apiURL:    apiURL,
+		anonymous: anonymous,
+		expires:   now.Add(st.ttl),
+	}
+	return id, nil
+}
+
+// get returns the session for id, or nil if it is unknown or expired.
+func (st *sessionStore) get(id string) *session {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	sess, ok := st.sessions[id]
+	if !ok {
+		return nil
+	}
+	if time.Now().After(sess.expires) {
+		delete(st.sessions, id)
+		return nil
+	}
+	return sess
+}
+
+// delete removes the session for id.
+func (st *sessionStore) delete(id string) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	delete(st.sessions, id)
+}
+
+// normalizeAPIURL reduces an API URL to scheme://host[/path] without a
+// trailing slash so allowlist comparisons are not fooled by formatting.
+func normalizeAPIURL(raw string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return "", fmt.Errorf("invalid API URL: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", fmt.Errorf("invalid API URL: scheme must be http or https")
+	}
+	if u.Host == "" {
+		return "", fmt.Errorf("invalid API URL: missing host")
+	}
+	if u.User != nil || u.RawQuery != "" || u.Fragment != "" {
+		return "", fmt.Errorf("invalid API URL: credentials, query and fragment are not allowed")
+	}
+	return strings.ToLower(u.Scheme) + "://" + strings.ToLower(u.Host) + strings.TrimRight(u.Path, "/"), nil
+}
+
+// apiURLAllowed reports whether the normalized URL is on the allowlist.
+func (s *Server) apiURLAllowed(normalized string) bool {
+	for _, allowed := range s.allowedAPIURLs {
+		if allowed == normalized {
+			return true
+		}
+	}
+	return false
+}
+
+// sessionFor returns the session bound to the request's cookie, if any.
+func (s *Server) sessionFor(r *http.Request) *session {
+	cookie, err := r.Cookie(sessionCookieName)
+	if err != nil || cookie.Value == "" {
+		return nil
+	}
+	return s.sessions.get(cookie.Value)
+}
+
+// apiClient returns the API client for the request: the one bound to the
+// browser's session if setup was completed, otherwise the server default.
+func (s *Server) apiClient(r *http.Request) *client.Client {
+	if sess := s.sessionFor(r); sess != nil {
+		return sess.client
+	}
+	return s.client
+}
+
+// handleSetupSubmit validates the submitted API credentials, tests them
+// against the API and binds them to a new session.
+func (s *Server) handleSetupSubmit(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Failed to parse form", http.StatusBadRequest)
+		return
+	}
+
+	rawURL := r.FormValue("api_url")
+	if rawURL == "" {
+		rawURL = s.client.BaseURL()
+	}
+	apiKey := strings.TrimSpace(r.FormValue("api_key"))
+
+	apiURL, err := normalizeAPIURL(rawURL)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !s.apiURLAllowed(apiURL) {
+		s.logger.Warn("rejected setup for API URL not on allowlist", "api_url", apiURL)
+		http.Error(w, "API URL is not permitted by this server", http.StatusForbidden)
+		return
+	}
+
+	apiClient := client.New(apiURL, apiKey)
+	status, err := apiClient.GetStatus()
+	if err != nil {
+		http.Error(w, "Connection failed: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	// Replace any existing session rather than stacking them
+	if old, err := r.Cookie(sessionCookieName); err == nil {
+		s.sessions.delete(old.Value)
+	}
+
+	anonymous := apiKey == ""
+	id, err := s.sessions.create(apiClient, apiURL, anonymous)
+	if err != nil {
+		s.logger.Error("failed to create session", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    id,
+		Path:     "/",
+		MaxAge:   int(s.sessions.ttl.Seconds()),
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	s.logger.Info("session configured", "api_url", apiURL, "anonymous", anonymous)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":      status.Status,
+		"api_version": status.APIVersion,
+		"anonymous":   anonymous,
+	})
+}
+
+// handleLogout discards the session bound to the request, falling back to
+// the server's default credentials.
+func (s *Server) handleLogout(w http.ResponseWriter, r *http.Request) {
+	if cookie, err := r.Cookie(sessionCookieName); err == nil {
+		s.sessions.delete(cookie.Value)
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
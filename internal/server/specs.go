@@ -0,0 +1,505 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// quantity is a spec value normalized to a base unit. Single values have
+// Min == Max; ranges such as "3.0-3.6V" keep both bounds.
+type quantity struct {
+	Min, Max float64
+	Unit     string // base unit, e.g. "V", "Hz", "B"; "" if unitless
+}
+
+func (q quantity) String() string {
+	if q.Min == q.Max {
+		return formatQuantity(q.Min, q.Unit)
+	}
+	return formatQuantity(q.Min, q.Unit) + " – " + formatQuantity(q.Max, q.Unit)
+}
+
+// specUnits maps unit spellings to their base unit.
+var specUnits = map[string]string{
+	"V": "V", "volt": "V", "volts": "V",
+	"A": "A", "amp": "A", "amps": "A",
+	"W": "W", "watt": "W", "watts": "W",
+	"Ah": "Ah", "Wh": "Wh",
+	"Hz": "Hz",
+	"B":  "B", "byte": "B", "bytes": "B",
+	"b": "bit", "bit": "bit", "bits": "bit",
+	"bps": "bit/s", "bit/s": "bit/s", "b/s": "bit/s",
+	"F": "F",
+	"Ω": "Ω", "ohm": "Ω", "ohms": "Ω",
+	"m": "m",
+	"s": "s", "sec": "s",
+	"g":  "g",
+	"°C": "°C", "C": "°C", "℃": "°C",
+	"%":  "%",
+	"dB": "dB", "dBm": "dBm",
+	// Binary prefixes: "KiB" is "K" + "iB"
+	"iB": "B", "ibit": "bit",
+}
+
+// siPrefixes are the multipliers for unit prefixes.
+var siPrefixes = map[rune]float64{
+	'p': 1e-12, 'n': 1e-9, 'u': 1e-6, 'µ': 1e-6, 'μ': 1e-6, 'm': 1e-3,
+	'k': 1e3, 'K': 1e3, 'M': 1e6, 'G': 1e9, 'T': 1e12,
+}
+
+// unprefixed are the base units that take no SI prefix.
+var unprefixed = map[string]bool{"°C": true, "%": true, "dB": true, "dBm": true}
+
+// parseUnit resolves a unit such as "MHz", "KB" or "mA" to its base unit
+// and multiplier. Memory sizes use binary multiples, as datasheets do. A
+// lone prefix such as the "M" of "4M" scales a unitless number. Unknown
+// units are kept, lowercased, so like can still compare with like.
+func parseUnit(s string) (string, float64) {
+	if s == "" {
+		return "", 1
+	}
+	if base, ok := lookupUnit(s); ok {
+		return base, 1
+	}
+
+	prefix, size := utf8.DecodeRuneInString(s)
+	scale, ok := siPrefixes[prefix]
+	rest := s[size:]
+	if !ok {
+		return strings.ToLower(s), 1
+	}
+	if rest == "" {
+		return "", scale
+	}
+	base, ok := lookupUnit(rest)
+	if !ok || unprefixed[base] {
+		return strings.ToLower(s), 1
+	}
+	// "Mb" is megabits, but all-lowercase "kb" and "mb" mean bytes
+	if rest == "b" && unicode.IsLower(prefix) {
+		base = "B"
+	}
+	// "mhz" and "mb" are common; there are no millihertz or millibytes
+	if prefix == 'm' && (base == "Hz" || base == "B") {
+		scale = 1e6
+	}
+	if strings.HasPrefix(rest, "i") || base == "B" && scale >= 1e3 {
+		scale = math.Pow(1024, math.Round(math.Log10(scale)/3))
+	}
+	return base, scale
+}
+
+// lookupUnit finds a unit spelling, ignoring case for anything longer
+// than a symbol so "Volts" and "hz" are understood.
+func lookupUnit(s string) (string, bool) {
+	if base, ok := specUnits[s]; ok {
+		return base, true
+	}
+	if len(s) < 2 {
+		return "", false
+	}
+	for spelling, base := range specUnits {
+		if len(spelling) >= 2 && strings.EqualFold(spelling, s) {
+			return base, true
+		}
+	}
+	return "", false
+}
+
+// parseQuantity reads the leading number, range and unit of a spec value
+// such as "3.3V", "240 MHz dual core", "1.8 - 3.6 V" or "-40 to 85°C".
+// Text after the unit is ignored.
+func parseQuantity(s string) (quantity, bool) {
+	min, rest, ok := leadingNumber(strings.TrimSpace(s))
+	if !ok {
+		return quantity{}, false
+	}
+	var unit1 string
+	after, found := cutRangeSeparator(rest)
+	if _, _, ok := leadingNumber(after); found && !ok {
+		// "32-bit" hyphenates the unit rather than starting a range
+		rest, found = after, false
+	}
+	if !found {
+		unit1, rest = leadingUnit(rest)
+		after, found = cutRangeSeparator(rest)
+	}
+
+	max, unit2 := min, unit1
+	if found {
+		if n, r, ok := leadingNumber(after); ok {
+			max = n
+			unit2, _ = leadingUnit(r)
+		}
+	}
+	// "3.0-3.6V" states the unit once
+	if unit1 == "" {
+		unit1 = unit2
+	}
+	if unit2 == "" {
+		unit2 = unit1
+	}
+
+	base1, scale1 := parseUnit(unit1)
+	base2, scale2 := parseUnit(unit2)
+	if base1 != base2 {
+		base2, scale2 = base1, scale1
+		max = min
+	}
+	q := quantity{Min: min * scale1, Max: max * scale2, Unit: base1}
+	if q.Min > q.Max {
+		q.Min, q.Max = q.Max, q.Min
+	}
+	return q, true
+}
+
+func leadingNumber(s string) (float64, string, bool) {
+	end := 0
+	for i, r := range s {
+		if unicode.IsDigit(r) || r == '.' || (i == 0 && (r == '-' || r == '+')) {
+			end = i + len(string(r))
+			continue
+		}
+		break
+	}
+	n, err := strconv.ParseFloat(s[:end], 64)
+	if err != nil {
+		return 0, s, false
+	}
+	return n, strings.TrimSpace(s[end:]), true
+}
+
+func leadingUnit(s string) (string, string) {
+	end := 0
+	for i, r := range s {
+		if unicode.IsLetter(r) || r == '°' || r == 'Ω' || r == '℃' || r == '%' || r == '/' {
+			end = i + len(string(r))
+			continue
+		}
+		break
+	}
+	return strings.TrimRight(s[:end], "/"), strings.TrimSpace(s[end:])
+}
+
+func cutRangeSeparator(s string) (string, bool) {
+	for _, sep := range []string{"-", "–", "~", "..", "to "} {
+		if after, ok := strings.CutPrefix(s, sep); ok {
+			return strings.TrimSpace(after), true
+		}
+	}
+	return "", false
+}
+
+// formatQuantity renders a normalized value with a readable prefix.
+func formatQuantity(v float64, unit string) string {
+	if unit == "" || unprefixed[unit] || v == 0 {
+		return strings.TrimSpace(strconv.FormatFloat(v, 'g', 6, 64) + " " + unit)
+	}
+	steps := []struct {
+		scale  float64
+		prefix string
+	}{{1e12, "T"}, {1e9, "G"}, {1e6, "M"}, {1e3, "k"}, {1, ""}, {1e-3, "m"}, {1e-6, "µ"}, {1e-9, "n"}, {1e-12, "p"}}
+	if unit == "B" {
+		steps = []struct {
+			scale  float64
+			prefix string
+		}{{1 << 40, "T"}, {1 << 30, "G"}, {1 << 20, "M"}, {1 << 10, "K"}, {1, ""}}
+	}
+	for _, st := range steps {
+		if math.Abs(v) >= st.scale {
+			return strconv.FormatFloat(v/st.scale, 'g', 4, 64) + " " + st.prefix + unit
+		}
+	}
+	last := steps[len(steps)-1]
+	return strconv.FormatFloat(v/last.scale, 'g', 4, 64) + " " + last.prefix + unit
+}
+
+// Spec queries
+
+// specOp is a comparison in a spec query.
+type specOp string
+
+const (
+	opHas specOp = "has"
+	opNo  specOp = "no"
+	opEq  specOp = "="
+	opNe  specOp = "!="
+	opGe  specOp = ">="
+	opLe  specOp = "<="
+	opGt  specOp = ">"
+	opLt  specOp = "<"
+)
+
+// specCriterion is one clause of a spec query, e.g. "flash >= 4MB".
+type specCriterion struct {
+	Text  string // the clause as written
+	Key   string
+	Op    specOp
+	Value string
+	Num   *quantity // nil for text comparisons and has/no
+}
+
+// specOperators are tried in order, so longer spellings come first.
+var specOperators = []struct {
+	token string
+	op    specOp
+}{
+	{">=", opGe}, {"≥", opGe}, {"<=", opLe}, {"≤", opLe},
+	{"!=", opNe}, {"≠", opNe}, {"==", opEq},
+	{">", opGt}, {"<", opLt}, {"=", opEq}, {":", opEq},
+}
+
+// parseSpecQuery splits a query such as "flash >= 4MB, voltage = 3.3V,
+// has wifi" into criteria. Clauses are separated by commas, semicolons or
+// "and"; a bare word means "has".
+func parseSpecQuery(query string) ([]specCriterion, error) {
+	clauses := strings.FieldsFunc(query, func(r rune) bool { return r == ',' || r == ';' || r == '\n' })
+	var criteria []specCriterion
+	var errs []error
+	for _, clause := range clauses {
+		for _, part := range strings.Split(clause, " and ") {
+			part = strings.TrimSpace(part)
+			if part == "" {
+				continue
+			}
+			c, err := parseSpecCriterion(part)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			criteria = append(criteria, c)
+		}
+	}
+	return criteria, errors.Join(errs...)
+}
+
+func parseSpecCriterion(text string) (specCriterion, error) {
+	c := specCriterion{Text: text}
+	lower := strings.ToLower(text)
+	for _, word := range []string{"has ", "with "} {
+		if rest, ok := strings.CutPrefix(lower, word); ok {
+			c.Key, c.Op = strings.TrimSpace(rest), opHas
+			return c, nil
+		}
+	}
+	for _, word := range []string{"no ", "without "} {
+		if rest, ok := strings.CutPrefix(lower, word); ok {
+			c.Key, c.Op = strings.TrimSpace(rest), opNo
+			return c, nil
+		}
+	}
+
+	for _, o := range specOperators {
+		key, value, ok := strings.Cut(text, o.token)
+		if !ok {
+			continue
+		}
+		c.Key, c.Op, c.Value = strings.TrimSpace(key), o.op, strings.TrimSpace(value)
+		if c.Key == "" || c.Value == "" {
+			return c, fmt.Errorf("%q: expected a spec name and a value", text)
+		}
+		if q, ok := parseQuantity(c.Value); ok {
+			c.Num = &q
+		} else if c.Op != opEq && c.Op != opNe {
+			return c, fmt.Errorf("%q: %s needs a number", text, o.token)
+		}
+		return c, nil
+	}
+
+	c.Key, c.Op = text, opHas
+	return c, nil
+}
+
+// specEntry is a device spec with its value parsed once.
+type specEntry struct {
+	Key   string
+	Value string
+	Num   *quantity
+	words []string // key split into lowercase words
+}
+
+func newSpecEntry(key, value string) specEntry {
+	e := specEntry{Key: key, Value: value, words: specWords(key)}
+	if q, ok := parseQuantity(value); ok {
+		e.Num = &q
+	}
+	return e
+}
+
+// specWords splits a spec name such as "CPU_Frequency" into words.
+func specWords(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// matchesKey reports whether every word of a query key starts a word of
+// the spec name, so "flash" finds "flash_size" and "cpu freq" finds
+// "cpu_frequency".
+func (e *specEntry) matchesKey(key []string) bool {
+	if len(key) == 0 {
+		return false
+	}
+	for _, k := range key {
+		found := false
+		for _, w := range e.words {
+			if strings.HasPrefix(w, k) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// truthy reports whether a spec value means the feature is present.
+func truthy(value string) bool {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", "no", "false", "none", "n/a", "na", "0", "-", "not supported", "unsupported":
+		return false
+	}
+	return true
+}
+
+// compactText lowercases s and drops everything but letters and digits,
+// so "Wi-Fi 802.11" contains "wifi".
+func compactText(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, s)
+}
+
+// specMatch is how well a device satisfies one criterion.
+type specMatch struct {
+	Criterion specCriterion
+	Spec      *specEntry // the spec that decided the result, if any
+	Matched   bool
+	Score     float64 // 1 when matched, partial credit for near misses
+}
+
+// equalTolerance is the relative difference still treated as equal, so
+// "3.3V" matches "3.30 V" and "4MB" matches "4096 KB".
+const equalTolerance = 0.01
+
+// match scores a device's specs against the criterion, using the best
+// of the specs whose names match.
+func (c *specCriterion) match(specs []specEntry) specMatch {
+	best := specMatch{Criterion: *c}
+	key := specWords(c.Key)
+
+	if c.Op == opHas || c.Op == opNo {
+		found := -1
+		for i := range specs {
+			if specs[i].matchesKey(key) && truthy(specs[i].Value) {
+				found = i
+				break
+			}
+		}
+		// Features are often listed in a value, e.g. "wireless: Wi-Fi, BLE"
+		if found < 0 {
+			needle := compactText(c.Key)
+			for i := range specs {
+				if needle != "" && strings.Contains(compactText(specs[i].Value), needle) {
+					found = i
+					break
+				}
+			}
+		}
+		if found >= 0 {
+			best.Spec = &specs[found]
+		}
+		best.Matched = (found >= 0) == (c.Op == opHas)
+		if best.Matched {
+			best.Score = 1
+		}
+		return best
+	}
+
+	for i := range specs {
+		e := &specs[i]
+		if !e.matchesKey(key) {
+			continue
+		}
+		m := specMatch{Criterion: *c, Spec: e}
+		m.Score = c.score(e)
+		m.Matched = m.Score == 1
+		if best.Spec == nil || m.Score > best.Score {
+			best = m
+		}
+	}
+	return best
+}
+
+// score compares one spec with the criterion. Ranges satisfy a bound if
+// any part of them does, and equality if they contain the value.
+func (c *specCriterion) score(e *specEntry) float64 {
+	if c.Num == nil {
+		equal := strings.EqualFold(strings.TrimSpace(e.Value), c.Value) ||
+			strings.Contains(compactText(e.Value), compactText(c.Value))
+		if equal == (c.Op == opEq) {
+			return 1
+		}
+		return 0
+	}
+	if e.Num == nil || (c.Num.Unit != "" && e.Num.Unit != c.Num.Unit) {
+		return 0
+	}
+
+	want := c.Num.Min
+	tol := math.Abs(want) * equalTolerance
+	var ok bool
+	var got float64 // the nearest value, for partial credit
+	switch c.Op {
+	case opEq:
+		ok = e.Num.Min-tol <= want && want <= e.Num.Max+tol
+		got = e.Num.Min
+		if math.Abs(e.Num.Max-want) < math.Abs(got-want) {
+			got = e.Num.Max
+		}
+	case opNe:
+		return boolScore(want < e.Num.Min-tol || want > e.Num.Max+tol)
+	case opGe:
+		ok, got = e.Num.Max >= want-tol, e.Num.Max
+	case opGt:
+		ok, got = e.Num.Max > want, e.Num.Max
+	case opLe:
+		ok, got = e.Num.Min <= want+tol, e.Num.Min
+	case opLt:
+		ok, got = e.Num.Min < want, e.Num.Min
+	}
+	if ok {
+		return 1
+	}
+	return nearness(got, want)
+}
+
+func boolScore(ok bool) float64 {
+	if ok {
+		return 1
+	}
+	return 0
+}
+
+// nearness gives a near miss up to half credit, falling off with the
+// ratio between the two values.
+func nearness(got, want float64) float64 {
+	if got == 0 || want == 0 || (got < 0) != (want < 0) {
+		return 0
+	}
+	ratio := math.Abs(got / want)
+	if ratio > 1 {
+		ratio = 1 / ratio
+	}
+	return ratio / 2
+}
@@ -0,0 +1,170 @@
+package server
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestParseQuantity(t *testing.T) {
+	tests := []struct {
+		input    string
+		min, max float64
+		unit     string
+		ok       bool
+	}{
+		{"3.3V", 3.3, 3.3, "V", true},
+		{"240 MHz", 240e6, 240e6, "Hz", true},
+		{"240mhz", 240e6, 240e6, "Hz", true},
+		{"2.4 GHz dual band", 2.4e9, 2.4e9, "Hz", true},
+		{"520 KB", 520 * 1024, 520 * 1024, "B", true},
+		{"4MB", 4 << 20, 4 << 20, "B", true},
+		{"4 MiB", 4 << 20, 4 << 20, "B", true},
+		{"32 Mb", 32e6, 32e6, "bit", true},
+		{"150 mA", 0.15, 0.15, "A", true},
+		{"10 µA", 10e-6, 10e-6, "A", true},
+		{"4.7kΩ", 4700, 4700, "Ω", true},
+		{"100 nF", 100e-9, 100e-9, "F", true},
+		{"1.8-3.6V", 1.8, 3.6, "V", true},
+		{"3.0 V - 3.6 V", 3.0, 3.6, "V", true},
+		{"-40 to 85°C", -40, 85, "°C", true},
+		{"115200 bps", 115200, 115200, "bit/s", true},
+		{"34", 34, 34, "", true},
+		{"34 GPIO", 34, 34, "gpio", true},
+		{"5 Volts", 5, 5, "V", true},
+		{"4M", 4e6, 4e6, "", true},
+		{"520K", 520e3, 520e3, "", true},
+		{"32-bit", 32, 32, "bit", true},
+		{"10 m", 10, 10, "m", true},
+		{"yes", 0, 0, "", false},
+		{"", 0, 0, "", false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.input, func(t *testing.T) {
+			q, ok := parseQuantity(tc.input)
+			if ok != tc.ok {
+				t.Fatalf("parseQuantity(%q) ok = %v, want %v", tc.input, ok, tc.ok)
+			}
+			if !ok {
+				return
+			}
+			if !approx(q.Min, tc.min) || !approx(q.Max, tc.max) || q.Unit != tc.unit {
+				t.Errorf("parseQuantity(%q) = %+v, want %v–%v %q", tc.input, q, tc.min, tc.max, tc.unit)
+			}
+		})
+	}
+}
+
+func approx(a, b float64) bool {
+	return math.Abs(a-b) <= 1e-9*math.Max(math.Abs(a), math.Abs(b))
+}
+
+func TestFormatQuantity(t *testing.T) {
+	tests := []struct {
+		q        quantity
+		expected string
+	}{
+		{quantity{Min: 240e6, Max: 240e6, Unit: "Hz"}, "240 MHz"},
+		{quantity{Min: 520 * 1024, Max: 520 * 1024, Unit: "B"}, "520 KB"},
+		{quantity{Min: 0.15, Max: 0.15, Unit: "A"}, "150 mA"},
+		{quantity{Min: 1.8, Max: 3.6, Unit: "V"}, "1.8 V – 3.6 V"},
+		{quantity{Min: 34, Max: 34}, "34"},
+	}
+	for _, tc := range tests {
+		if got := tc.q.String(); got != tc.expected {
+			t.Errorf("%+v = %q, want %q", tc.q, got, tc.expected)
+		}
+	}
+}
+
+func TestParseSpecQuery(t *testing.T) {
+	criteria, err := parseSpecQuery("flash >= 4MB, voltage = 3.3V; has wifi and no usb, package: QFN, bluetooth")
+	if err != nil {
+		t.Fatalf("parseSpecQuery failed: %v", err)
+	}
+
+	expected := []struct {
+		key   string
+		op    specOp
+		value string
+		num   bool
+	}{
+		{"flash", opGe, "4MB", true},
+		{"voltage", opEq, "3.3V", true},
+		{"wifi", opHas, "", false},
+		{"usb", opNo, "", false},
+		{"package", opEq, "QFN", false},
+		{"bluetooth", opHas, "", false},
+	}
+	if len(criteria) != len(expected) {
+		t.Fatalf("got %d criteria, want %d: %+v", len(criteria), len(expected), criteria)
+	}
+	for i, want := range expected {
+		c := criteria[i]
+		if c.Key != want.key || c.Op != want.op || c.Value != want.value || (c.Num != nil) != want.num {
+			t.Errorf("criterion %d = %+v, want %+v", i, c, want)
+		}
+	}
+
+	criteria, err = parseSpecQuery("flash >= lots, ram >= 8KB")
+	if err == nil || !strings.Contains(err.Error(), "flash >= lots") {
+		t.Errorf("expected an error naming the bad clause, got %v", err)
+	}
+	if len(criteria) != 1 || criteria[0].Key != "ram" {
+		t.Errorf("expected the valid clause to be kept, got %+v", criteria)
+	}
+}
+
+func TestSpecCriterionMatch(t *testing.T) {
+	specs := []specEntry{
+		newSpecEntry("flash_size", "4 MB"),
+		newSpecEntry("operating_voltage", "3.0-3.6V"),
+		newSpecEntry("cpu_frequency", "240 MHz"),
+		newSpecEntry("wireless", "Wi-Fi 802.11 b/g/n, Bluetooth 4.2"),
+		newSpecEntry("usb", "no"),
+		newSpecEntry("package", "QFN48"),
+	}
+
+	tests := []struct {
+		query   string
+		matched bool
+		partial bool // a near miss scores above zero
+	}{
+		{"flash >= 4MB", true, false},
+		{"flash >= 4096 KB", true, false},
+		{"flash > 4MB", false, true},
+		{"flash >= 8MB", false, true},
+		{"flash >= 4 GHz", false, false},
+		{"voltage = 3.3V", true, false},
+		{"voltage = 5V", false, true},
+		{"voltage <= 3.0V", true, false},
+		{"voltage != 5V", true, false},
+		{"cpu freq >= 160 MHz", true, false},
+		{"cpu freq < 100 MHz", false, true},
+		{"has wifi", true, false},
+		{"has bluetooth", true, false},
+		{"has usb", false, false},
+		{"no usb", true, false},
+		{"no wifi", false, false},
+		{"package = qfn", true, false},
+		{"package != qfn", false, false},
+		{"sram >= 1KB", false, false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.query, func(t *testing.T) {
+			criteria, err := parseSpecQuery(tc.query)
+			if err != nil || len(criteria) != 1 {
+				t.Fatalf("parseSpecQuery(%q) = %v, %v", tc.query, criteria, err)
+			}
+			m := criteria[0].match(specs)
+			if m.Matched != tc.matched {
+				t.Errorf("matched = %v, want %v (spec %+v)", m.Matched, tc.matched, m.Spec)
+			}
+			if !tc.matched && (m.Score > 0) != tc.partial {
+				t.Errorf("score = %v, want partial = %v", m.Score, tc.partial)
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+/**
+ * Clipboard - Copy buttons, section links and code blocks
+ * Any element with a data-copy attribute copies that attribute's value
+ * when clicked, including elements added later by HTMX.
+ */
+
+document.addEventListener('click', async (event) => {
+    const button = event.target.closest('[data-copy]');
+    if (!button) {
+        return;
+    }
+    event.preventDefault();
+    event.stopPropagation();
+
+    try {
+        await navigator.clipboard.writeText(button.dataset.copy);
+        notifications.success(button.dataset.copyMessage || 'Copied to clipboard');
+    } catch (error) {
+        console.error('[Clipboard] Copy failed:', error);
+        notifications.error('Could not copy to the clipboard');
+    }
+});
+
+// Heading anchors in rendered markdown copy a link to their section as
+// well as jumping to it.
+document.addEventListener('click', async (event) => {
+    const anchor = event.target.closest('a.heading-anchor');
+    if (!anchor) {
+        return;
+    }
+
+    const url = new URL(anchor.getAttribute('href'), window.location.href);
+    try {
+        await navigator.clipboard.writeText(url.href);
+        notifications.success('Link to section copied');
+    } catch (error) {
+        console.error('[Clipboard] Copy failed:', error);
+    }
+});
+
+// Code blocks in rendered markdown get a button copying their text.
+function addCodeCopyButtons(root) {
+    root.querySelectorAll('.prose pre').forEach((pre) => {
+        if (pre.parentElement.classList.contains('code-block')) {
+            return;
+        }
+        const wrapper = document.createElement('div');
+        wrapper.className = 'code-block';
+        pre.replaceWith(wrapper);
+        wrapper.appendChild(pre);
+
+        const button = document.createElement('button');
+        button.type = 'button';
+        button.className = 'code-copy';
+        button.textContent = 'Copy';
+        button.setAttribute('aria-label', 'Copy code to clipboard');
+        wrapper.appendChild(button);
+    });
+}
+
+document.addEventListener('click', async (event) => {
+    const button = event.target.closest('.code-block .code-copy');
+    if (!button) {
+        return;
+    }
+
+    const code = button.parentElement.querySelector('pre');
+    try {
+        await navigator.clipboard.writeText(code.innerText.replace(/\n$/, ''));
+        button.textContent = 'Copied';
+        setTimeout(() => { button.textContent = 'Copy'; }, 2000);
+    } catch (error) {
+        console.error('[Clipboard] Copy failed:', error);
+        notifications.error('Could not copy to the clipboard');
+    }
+});
+
+document.addEventListener('DOMContentLoaded', () => addCodeCopyButtons(document));
+document.addEventListener('htmx:afterSwap', (event) => addCodeCopyButtons(event.detail.target));
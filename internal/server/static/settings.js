@@ -18,6 +18,9 @@ function clearConfiguration() {
         sessionStorage.removeItem('manuals_api_key');
         sessionStorage.removeItem('manuals_configured');
 
+        // Drop the server-side session bound to this browser
+        fetch('/logout', { method: 'POST' }).catch(() => {});
+
         if (typeof notifications !== 'undefined') {
             notifications.warning('Configuration cleared. Redirecting to setup...');
         }
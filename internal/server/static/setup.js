@@ -1,6 +1,8 @@
 /**
  * Setup Page - API Configuration
- * Handles the initial setup flow for configuring API credentials
+ * Handles the initial setup flow for configuring API credentials.
+ * Credentials are posted to the server (see bindServerSession in base.html),
+ * which tests them and binds them to this browser's session.
  */
 
 function togglePassword() {
@@ -9,27 +11,6 @@ function togglePassword() {
     apiKeyInput.type = showKeyCheckbox.checked ? 'text' : 'password';
 }
 
-async function testConnection(apiUrl, apiKey) {
-    try {
-        const headers = {};
-        // Only add API key header if provided (allows anonymous access)
-        if (apiKey) {
-            headers['X-API-Key'] = apiKey;
-        }
-
-        const response = await fetch(`${apiUrl}/api/2025.12/status`, { headers });
-
-        if (!response.ok) {
-            throw new Error(`HTTP ${response.status}: ${response.statusText}`);
-        }
-
-        const data = await response.json();
-        return { success: true, data };
-    } catch (error) {
-        return { success: false, error: error.message };
-    }
-}
-
 // Initialize setup form
 document.addEventListener('DOMContentLoaded', () => {
     // Pre-fill form if values exist in sessionStorage (for reconfiguration)
@@ -62,8 +43,8 @@ document.addEventListener('DOMContentLoaded', () => {
         submitButton.disabled = true;
         submitButton.textContent = 'Testing Connection...';
 
-        // Test connection
-        const result = await testConnection(apiUrl, apiKey);
+        // Test connection and bind credentials to the server session
+        const result = await bindServerSession(apiUrl, apiKey);
 
         if (result.success) {
             // Save to sessionStorage (more secure - cleared when tab closes)
@@ -129,7 +110,7 @@ document.addEventListener('DOMContentLoaded', () => {
             guestBtn.textContent = 'Testing Connection...';
 
             // Test connection without API key (anonymous)
-            const result = await testConnection(apiUrl, '');
+            const result = await bindServerSession(apiUrl, '');
 
             if (result.success) {
                 // Save URL only (no API key for guest mode)
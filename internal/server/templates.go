@@ -0,0 +1,186 @@
+package server
+
+import (
+	"fmt"
+	"html/template"
+	"io/fs"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// templateRegistry holds every page and partial parsed once up front.
+// In dev mode it re-parses from disk whenever a template file changes.
+type templateRegistry struct {
+	fsys    fs.FS
+	funcMap template.FuncMap
+	dev     bool
+
+	mu       sync.RWMutex
+	pages    map[string]*template.Template
+	partials *template.Template
+	modTime  time.Time
+}
+
+// newTemplateRegistry parses all templates in fsys, which must contain
+// base.html, the page templates and a partials directory.
+func newTemplateRegistry(fsys fs.FS, funcMap template.FuncMap, dev bool) (*templateRegistry, error) {
+	tr := &templateRegistry{
+		fsys:    fsys,
+		funcMap: funcMap,
+		dev:     dev,
+	}
+	if err := tr.load(); err != nil {
+		return nil, err
+	}
+	return tr, nil
+}
+
+// load parses every template and swaps them in atomically.
+func (tr *templateRegistry) load() error {
+	modTime, err := tr.latestModTime()
+	if err != nil {
+		return err
+	}
+
+	partialFiles, err := fs.Glob(tr.fsys, "partials/*.html")
+	if err != nil {
+		return fmt.Errorf("failed to list partial templates: %w", err)
+	}
+
+	// Partials are named by their path so both plain files and files
+	// wrapped in {{define "partials/x.html"}} resolve the same way.
+	partials := template.New("").Funcs(tr.funcMap)
+	for _, file := range partialFiles {
+		if err := parseTemplateFile(partials, tr.fsys, file); err != nil {
+			return err
+		}
+	}
+
+	base, err := partials.Clone()
+	if err != nil {
+		return fmt.Errorf("failed to clone partial templates: %w", err)
+	}
+	if err := parseTemplateFile(base, tr.fsys, "base.html"); err != nil {
+		return err
+	}
+
+	pageFiles, err := fs.Glob(tr.fsys, "*.html")
+	if err != nil {
+		return fmt.Errorf("failed to list page templates: %w", err)
+	}
+
+	pages := make(map[string]*template.Template, len(pageFiles))
+	for _, file := range pageFiles {
+		if file == "base.html" {
+			continue
+		}
+		page, err := base.Clone()
+		if err != nil {
+			return fmt.Errorf("failed to clone base template: %w", err)
+		}
+		if err := parseTemplateFile(page, tr.fsys, file); err != nil {
+			return err
+		}
+		pages[file] = page
+	}
+
+	tr.mu.Lock()
+	tr.pages = pages
+	tr.partials = partials
+	tr.modTime = modTime
+	tr.mu.Unlock()
+
+	return nil
+}
+
+// parseTemplateFile adds the file to t as a template named by its path.
+func parseTemplateFile(t *template.Template, fsys fs.FS, file string) error {
+	content, err := fs.ReadFile(fsys, file)
+	if err != nil {
+		return fmt.Errorf("failed to read template %s: %w", file, err)
+	}
+	if _, err := t.New(file).Parse(string(content)); err != nil {
+		return fmt.Errorf("failed to parse template %s: %w", file, err)
+	}
+	return nil
+}
+
+// latestModTime returns the newest modification time of any template.
+func (tr *templateRegistry) latestModTime() (time.Time, error) {
+	var latest time.Time
+	err := fs.WalkDir(tr.fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(p, ".html") {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+		return nil
+	})
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to scan templates: %w", err)
+	}
+	return latest, nil
+}
+
+// refresh reloads the templates in dev mode if any file has changed.
+func (tr *templateRegistry) refresh() error {
+	if !tr.dev {
+		return nil
+	}
+
+	modTime, err := tr.latestModTime()
+	if err != nil {
+		return err
+	}
+
+	tr.mu.RLock()
+	stale := modTime.After(tr.modTime)
+	tr.mu.RUnlock()
+
+	if !stale {
+		return nil
+	}
+	return tr.load()
+}
+
+// page returns the template set for a page, e.g. "device.html".
+func (tr *templateRegistry) page(name string) (*template.Template, error) {
+	if err := tr.refresh(); err != nil {
+		return nil, err
+	}
+
+	tr.mu.RLock()
+	defer tr.mu.RUnlock()
+
+	tmpl, ok := tr.pages[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown page template %s", name)
+	}
+	return tmpl, nil
+}
+
+// partial returns the template set containing the named partial,
+// e.g. "partials/device-list.html".
+func (tr *templateRegistry) partial(name string) (*template.Template, error) {
+	if err := tr.refresh(); err != nil {
+		return nil, err
+	}
+
+	tr.mu.RLock()
+	defer tr.mu.RUnlock()
+
+	if tr.partials.Lookup(name) == nil || path.Dir(name) != "partials" {
+		return nil, fmt.Errorf("unknown partial template %s", name)
+	}
+	return tr.partials, nil
+}
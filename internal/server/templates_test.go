@@ -0,0 +1,99 @@
+package server
+
+import (
+	"bytes"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestTemplateRegistryEmbedded(t *testing.T) {
+	fsys, _ := fs.Sub(templatesFS, "templates")
+	tr, err := newTemplateRegistry(fsys, newFuncMap(newMarkdownRenderer(), Branding{SiteTitle: defaultSiteTitle}), false)
+	if err != nil {
+		t.Fatalf("newTemplateRegistry failed: %v", err)
+	}
+
+	for _, name := range []string{"home.html", "device.html", "error.html", "admin-reindex.html"} {
+		if _, err := tr.page(name); err != nil {
+			t.Errorf("page(%q) failed: %v", name, err)
+		}
+	}
+	if _, err := tr.page("base.html"); err == nil {
+		t.Error("expected base.html not to be a page")
+	}
+
+	// Partials with and without a define wrapper resolve by path
+	for _, name := range []string{"partials/device-list.html", "partials/user-list.html"} {
+		if _, err := tr.partial(name); err != nil {
+			t.Errorf("partial(%q) failed: %v", name, err)
+		}
+	}
+	if _, err := tr.partial("partials/missing.html"); err == nil {
+		t.Error("expected error for unknown partial")
+	}
+}
+
+func TestTemplateRegistryParseError(t *testing.T) {
+	fsys := fstest.MapFS{
+		"base.html":           {Data: []byte(`{{define "base"}}{{template "content" .}}{{end}}`)},
+		"broken.html":         {Data: []byte(`{{template "base" .}}{{define "content"}}{{if}}{{end}}`)},
+		"partials/empty.html": {Data: []byte(``)},
+	}
+
+	_, err := newTemplateRegistry(fsys, nil, false)
+	if err == nil {
+		t.Fatal("expected parse error")
+	}
+	if !strings.Contains(err.Error(), "broken.html") {
+		t.Errorf("expected error to name the broken template, got %v", err)
+	}
+}
+
+func TestTemplateRegistryDevReload(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string, mtime time.Time) {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0o755)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		os.Chtimes(path, mtime, mtime)
+	}
+
+	past := time.Now().Add(-time.Hour)
+	write("base.html", `{{define "base"}}[{{template "content" .}}]{{end}}`, past)
+	write("page.html", `{{template "base" .}}{{define "content"}}v1{{end}}`, past)
+	write("partials/item.html", `item`, past)
+
+	tr, err := newTemplateRegistry(os.DirFS(dir), nil, true)
+	if err != nil {
+		t.Fatalf("newTemplateRegistry failed: %v", err)
+	}
+
+	render := func() string {
+		tmpl, err := tr.page("page.html")
+		if err != nil {
+			t.Fatalf("page failed: %v", err)
+		}
+		var buf bytes.Buffer
+		if err := tmpl.ExecuteTemplate(&buf, "page.html", nil); err != nil {
+			t.Fatalf("execute failed: %v", err)
+		}
+		return buf.String()
+	}
+
+	if got := render(); got != "[v1]" {
+		t.Errorf("expected [v1], got %q", got)
+	}
+
+	write("page.html", `{{template "base" .}}{{define "content"}}v2{{end}}`, time.Now())
+
+	if got := render(); got != "[v2]" {
+		t.Errorf("expected reloaded template [v2], got %q", got)
+	}
+}
@@ -0,0 +1,23 @@
+/*
+ * ESP32 DevKit variant pins
+ *
+ * Generated by manuals-webui from device "esp32-devkit".
+ */
+
+#ifndef Pins_Arduino_h
+#define Pins_Arduino_h
+
+#include <stdint.h>
+
+#define NUM_DIGITAL_PINS  34
+#define NUM_ANALOG_INPUTS 1
+
+static const uint8_t TX = 1;
+static const uint8_t SDA = 21;
+/* SDA is also on GPIO 23 */
+static const uint8_t SCL = 22;
+static const uint8_t MOSI = 23;
+
+static const uint8_t A0 = 33;
+
+#endif /* Pins_Arduino_h */
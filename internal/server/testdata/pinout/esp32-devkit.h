@@ -0,0 +1,39 @@
+/*
+ * ESP32 DevKit pinout
+ *
+ * Generated by manuals-webui from device "esp32-devkit".
+ */
+
+#ifndef ESP32_DEVKIT_PINOUT_H
+#define ESP32_DEVKIT_PINOUT_H
+
+/* Physical pin numbers */
+#define ESP32_DEVKIT_PIN_3V3 1
+#define ESP32_DEVKIT_PIN_GND 2
+#define ESP32_DEVKIT_PIN_GPIO33 8
+#define ESP32_DEVKIT_PIN_GPIO21 33
+#define ESP32_DEVKIT_PIN_TX0 35
+#define ESP32_DEVKIT_PIN_GPIO22 36
+#define ESP32_DEVKIT_PIN_GPIO23 37
+#define ESP32_DEVKIT_PIN_GND_2 38
+
+/* GPIO numbers */
+#define ESP32_DEVKIT_GPIO33 33
+#define ESP32_DEVKIT_GPIO21 21
+#define ESP32_DEVKIT_TX0 1
+#define ESP32_DEVKIT_GPIO22 22
+#define ESP32_DEVKIT_GPIO23 23
+
+/* GPIO numbers by function */
+#define ESP32_DEVKIT_ADC1_CH5 33
+#define ESP32_DEVKIT_TOUCH8 33
+#define ESP32_DEVKIT_I2C0_SDA 21
+#define ESP32_DEVKIT_VSPI_HD 21
+#define ESP32_DEVKIT_UART0_TX 1
+#define ESP32_DEVKIT_CLK_OUT3 1
+#define ESP32_DEVKIT_I2C0_SCL 22
+#define ESP32_DEVKIT_U0RTS 22
+#define ESP32_DEVKIT_VSPI_MOSI 23
+/* ESP32_DEVKIT_I2C0_SDA is also on GPIO 23 */
+
+#endif /* ESP32_DEVKIT_PINOUT_H */
@@ -0,0 +1,152 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// defaultSiteTitle is shown when no branding title is configured.
+const defaultSiteTitle = "Manuals"
+
+// Branding holds site-specific values exposed to base.html through the
+// "brand" template function.
+type Branding struct {
+	// SiteTitle replaces "Manuals" in the navigation bar and page titles.
+	SiteTitle string
+
+	// LogoURL is an absolute http(s) URL or a site-relative path.
+	LogoURL string
+
+	// AccentColor is a hex color (#rgb or #rrggbb) used for the navigation bar.
+	AccentColor string
+
+	// FooterHTML is trusted operator-supplied markup rendered in the footer.
+	FooterHTML template.HTML
+}
+
+var hexColorPattern = regexp.MustCompile(`^#(?:[0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+// validate checks the branding values and fills in defaults.
+func (b *Branding) validate() error {
+	b.SiteTitle = strings.TrimSpace(b.SiteTitle)
+	if b.SiteTitle == "" {
+		b.SiteTitle = defaultSiteTitle
+	}
+
+	if b.AccentColor != "" && !hexColorPattern.MatchString(b.AccentColor) {
+		return fmt.Errorf("invalid accent color %q: must be #rgb or #rrggbb", b.AccentColor)
+	}
+
+	if b.LogoURL != "" {
+		u, err := url.Parse(b.LogoURL)
+		if err != nil {
+			return fmt.Errorf("invalid logo URL: %w", err)
+		}
+		absolute := (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+		relative := u.Scheme == "" && u.Host == "" && strings.HasPrefix(u.Path, "/")
+		if !absolute && !relative {
+			return fmt.Errorf("invalid logo URL %q: must be http(s) or start with /", b.LogoURL)
+		}
+	}
+
+	return nil
+}
+
+// overlayFS serves files from upper when present and from lower otherwise.
+// Directory listings are merged so globbing sees both layers.
+type overlayFS struct {
+	upper fs.FS
+	lower fs.FS
+}
+
+// Open implements fs.FS.
+func (o overlayFS) Open(name string) (fs.File, error) {
+	f, err := o.upper.Open(name)
+	if err == nil {
+		return f, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	return o.lower.Open(name)
+}
+
+// ReadDir implements fs.ReadDirFS.
+func (o overlayFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	lowerEntries, lowerErr := fs.ReadDir(o.lower, name)
+	upperEntries, upperErr := fs.ReadDir(o.upper, name)
+	if lowerErr != nil && upperErr != nil {
+		return nil, lowerErr
+	}
+
+	merged := make(map[string]fs.DirEntry, len(lowerEntries)+len(upperEntries))
+	for _, e := range lowerEntries {
+		merged[e.Name()] = e
+	}
+	for _, e := range upperEntries {
+		merged[e.Name()] = e
+	}
+
+	entries := make([]fs.DirEntry, 0, len(merged))
+	for _, e := range merged {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
+
+// validateTemplateOverrides checks an override directory against the
+// built-in templates and the parsed registry. Overrides must shadow an
+// existing page (new partials may be added), and overridden pages must
+// still define the blocks base.html relies on.
+func validateTemplateOverrides(overrides, builtin fs.FS, tr *templateRegistry) error {
+	var problems []string
+
+	err := fs.WalkDir(overrides, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(p, ".html") {
+			return nil
+		}
+
+		switch {
+		case path.Dir(p) == "partials":
+			// New partials are allowed so overridden pages can include them
+		case path.Dir(p) != ".":
+			problems = append(problems, fmt.Sprintf("%s: only top-level pages and partials/ can be overridden", p))
+		case p == "base.html":
+			tmpl, _ := tr.page("error.html")
+			for _, block := range []string{"base", "nav"} {
+				if tmpl == nil || tmpl.Lookup(block) == nil {
+					problems = append(problems, fmt.Sprintf("%s: must define %q", p, block))
+				}
+			}
+		default:
+			if _, err := fs.Stat(builtin, p); err != nil {
+				problems = append(problems, fmt.Sprintf("%s: does not override a built-in page", p))
+				return nil
+			}
+			tmpl, err := tr.page(p)
+			if err != nil || tmpl.Lookup("content") == nil {
+				problems = append(problems, fmt.Sprintf("%s: must define \"content\"", p))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to scan template overrides: %w", err)
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid template overrides:\n  %s", strings.Join(problems, "\n  "))
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package server
+
+import (
+	"html/template"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rmrfslashbin/manuals-webui/internal/client"
+)
+
+func TestBrandingValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		b       Branding
+		wantErr bool
+	}{
+		{"defaults", Branding{}, false},
+		{"short hex", Branding{AccentColor: "#0af"}, false},
+		{"long hex", Branding{AccentColor: "#00AAFF"}, false},
+		{"css injection", Branding{AccentColor: "red;}body{display:none"}, true},
+		{"absolute logo", Branding{LogoURL: "https://cdn.example.com/logo.svg"}, false},
+		{"relative logo", Branding{LogoURL: "/static/favicon.svg"}, false},
+		{"javascript logo", Branding{LogoURL: "javascript:alert(1)"}, true},
+		{"protocol-relative logo", Branding{LogoURL: "//evil.example.com/x.png"}, true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.b.validate()
+			if (err != nil) != tc.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tc.wantErr)
+			}
+		})
+	}
+
+	b := Branding{SiteTitle: "  "}
+	b.validate()
+	if b.SiteTitle != defaultSiteTitle {
+		t.Errorf("expected default site title, got %q", b.SiteTitle)
+	}
+}
+
+func TestOverlayFS(t *testing.T) {
+	upper := t.TempDir()
+	os.MkdirAll(filepath.Join(upper, "partials"), 0o755)
+	os.WriteFile(filepath.Join(upper, "home.html"), []byte("override"), 0o644)
+	os.WriteFile(filepath.Join(upper, "partials", "extra.html"), []byte("extra"), 0o644)
+
+	builtin, _ := fs.Sub(templatesFS, "templates")
+	o := overlayFS{upper: os.DirFS(upper), lower: builtin}
+
+	content, err := fs.ReadFile(o, "home.html")
+	if err != nil || string(content) != "override" {
+		t.Errorf("expected override content, got %q (%v)", content, err)
+	}
+	if _, err := fs.ReadFile(o, "device.html"); err != nil {
+		t.Errorf("expected built-in device.html, got %v", err)
+	}
+
+	partials, err := fs.Glob(o, "partials/*.html")
+	if err != nil {
+		t.Fatalf("Glob failed: %v", err)
+	}
+	joined := strings.Join(partials, ",")
+	if !strings.Contains(joined, "partials/extra.html") || !strings.Contains(joined, "partials/user-list.html") {
+		t.Errorf("expected merged partial listing, got %v", partials)
+	}
+}
+
+func brandedServer(t *testing.T, templatesDir string, branding Branding) (*Server, error) {
+	t.Helper()
+	apiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	t.Cleanup(apiServer.Close)
+	return New(Config{
+		Client:       client.New(apiServer.URL, ""),
+		Logger:       slog.New(slog.NewTextHandler(io.Discard, nil)),
+		TemplatesDir: templatesDir,
+		Branding:     branding,
+	})
+}
+
+func TestTemplateOverridesAndBranding(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "partials"), 0o755)
+	os.WriteFile(filepath.Join(dir, "setup.html"), []byte(`{{template "base" .}}{{define "content"}}{{template "partials/acme.html"}}{{end}}`), 0o644)
+	os.WriteFile(filepath.Join(dir, "partials", "acme.html"), []byte(`<p>Acme onboarding</p>`), 0o644)
+
+	s, err := brandedServer(t, dir, Branding{
+		SiteTitle:   "Acme Docs",
+		LogoURL:     "/static/favicon.svg",
+		AccentColor: "#123456",
+		FooterHTML:  template.HTML(`<a href="https://acme.example.com">Acme</a>`),
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	req := httptest.NewRequest("GET", "/setup", nil)
+	w := httptest.NewRecorder()
+	s.handleSetup(w, req)
+
+	body := w.Body.String()
+	for _, want := range []string{
+		"<title>Setup - Acme Docs</title>",
+		"Acme onboarding",
+		`src="/static/favicon.svg"`,
+		"#123456",
+		`<a href="https://acme.example.com">Acme</a>`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected body to contain %q", want)
+		}
+	}
+}
+
+func TestTemplateOverridesValidation(t *testing.T) {
+	tests := []struct {
+		name  string
+		file  string
+		body  string
+		match string
+	}{
+		{"unknown page", "nope.html", `{{template "base" .}}{{define "content"}}{{end}}`, "does not override"},
+		{"missing content", "home.html", `<p>no base</p>`, `must define "content"`},
+		{"parse error", "device.html", `{{if}}`, "device.html"},
+		{"nested dir", "admin/x.html", `x`, "only top-level"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, tc.file)
+			os.MkdirAll(filepath.Dir(path), 0o755)
+			os.WriteFile(path, []byte(tc.body), 0o644)
+
+			_, err := brandedServer(t, dir, Branding{})
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			if !strings.Contains(err.Error(), tc.match) {
+				t.Errorf("expected error containing %q, got %v", tc.match, err)
+			}
+		})
+	}
+
+	if _, err := brandedServer(t, filepath.Join(t.TempDir(), "missing"), Branding{}); err == nil {
+		t.Error("expected error for missing templates dir")
+	}
+}
@@ -0,0 +1,253 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	_ "image/jpeg"
+	"image/png"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/rmrfslashbin/manuals-webui/internal/client"
+)
+
+const (
+	// maxThumbnailSourceBytes caps how much of an image is downloaded.
+	maxThumbnailSourceBytes = 20 << 20
+
+	// maxThumbnailPixels caps the decoded size of an image, checked from
+	// its header before decoding so small files can't expand to gigabytes.
+	maxThumbnailPixels = 24_000_000
+
+	// thumbnailConcurrency bounds how many images are decoded at once.
+	thumbnailConcurrency = 2
+)
+
+// thumbnailSizes are the bounding boxes offered by the thumbnail endpoint.
+var thumbnailSizes = map[string]int{
+	"small":  96,
+	"medium": 240,
+	"large":  480,
+}
+
+// thumbnailMimeTypes are the image types the standard library can decode.
+var thumbnailMimeTypes = map[string]bool{
+	"image/png":  true,
+	"image/jpeg": true,
+	"image/gif":  true,
+}
+
+// errImageTooLarge is returned for images over the pixel or byte limits.
+var errImageTooLarge = errors.New("image is too large to thumbnail")
+
+// hasThumbnail reports whether thumbnails can be generated for a MIME type.
+func hasThumbnail(mimeType string) bool {
+	mediaType, _, err := mime.ParseMediaType(mimeType)
+	return err == nil && thumbnailMimeTypes[mediaType]
+}
+
+// thumbnailer generates thumbnails and keeps them on disk, keyed by the
+// source checksum so a changed document never serves a stale thumbnail.
+type thumbnailer struct {
+	dir string // empty disables the disk cache
+	sem chan struct{}
+}
+
+func newThumbnailer(dir string) (*thumbnailer, error) {
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return nil, fmt.Errorf("failed to create thumbnail directory: %w", err)
+		}
+	}
+	return &thumbnailer{dir: dir, sem: make(chan struct{}, thumbnailConcurrency)}, nil
+}
+
+func (t *thumbnailer) path(digest, size string) string {
+	return filepath.Join(t.dir, digest+"-"+size+".png")
+}
+
+// get returns a cached thumbnail.
+func (t *thumbnailer) get(digest, size string) ([]byte, bool) {
+	if t.dir == "" || digest == "" {
+		return nil, false
+	}
+	data, err := os.ReadFile(t.path(digest, size))
+	return data, err == nil
+}
+
+// put stores a thumbnail, writing to a temporary file first so readers
+// never see a partial image.
+func (t *thumbnailer) put(digest, size string, data []byte) error {
+	if t.dir == "" || digest == "" {
+		return nil
+	}
+	tmp, err := os.CreateTemp(t.dir, "thumb-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), t.path(digest, size))
+}
+
+// generate decodes an image and scales it to fit within a size x size box.
+func (t *thumbnailer) generate(data []byte, size int) ([]byte, error) {
+	t.sem <- struct{}{}
+	defer func() { <-t.sem }()
+	return makeThumbnail(data, size)
+}
+
+// makeThumbnail decodes a PNG, JPEG or GIF (first frame) and returns a PNG
+// no larger than size x size. Images are never scaled up.
+func makeThumbnail(data []byte, size int) ([]byte, error) {
+	if len(data) > maxThumbnailSourceBytes {
+		return nil, errImageTooLarge
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image: %w", err)
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || int64(cfg.Width)*int64(cfg.Height) > maxThumbnailPixels {
+		return nil, errImageTooLarge
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, scaleDown(img, size)); err != nil {
+		return nil, fmt.Errorf("failed to encode thumbnail: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// scaleDown fits src within a limit x limit box, keeping its aspect ratio,
+// by averaging the source pixels covered by each destination pixel.
+func scaleDown(src image.Image, limit int) image.Image {
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= limit && h <= limit {
+		return src
+	}
+
+	dw, dh := limit, limit
+	if w > h {
+		dh = max(1, h*limit/w)
+	} else {
+		dw = max(1, w*limit/h)
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		sy0, sy1 := b.Min.Y+y*h/dh, b.Min.Y+(y+1)*h/dh
+		for x := 0; x < dw; x++ {
+			sx0, sx1 := b.Min.X+x*w/dw, b.Min.X+(x+1)*w/dw
+
+			var r, g, bl, a, n uint64
+			for sy := sy0; sy < sy1; sy++ {
+				for sx := sx0; sx < sx1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r, g, bl, a = r+uint64(cr), g+uint64(cg), bl+uint64(cb), a+uint64(ca)
+					n++
+				}
+			}
+			dst.SetRGBA64(x, y, color.RGBA64{R: uint16(r / n), G: uint16(g / n), B: uint16(bl / n), A: uint16(a / n)})
+		}
+	}
+	return dst
+}
+
+// handleThumbnail serves a PNG thumbnail of an image document. The size
+// query parameter selects one of thumbnailSizes and defaults to medium.
+func (s *Server) handleThumbnail(w http.ResponseWriter, r *http.Request) {
+	size := r.URL.Query().Get("size")
+	if size == "" {
+		size = "medium"
+	}
+	pixels, ok := thumbnailSizes[size]
+	if !ok {
+		s.renderErrorStatus(w, r, http.StatusBadRequest, "Unknown thumbnail size")
+		return
+	}
+
+	apiClient := s.apiClient(r)
+	doc, err := apiClient.GetDocument(r.PathValue("id"))
+	if err != nil {
+		s.renderError(w, r, "Failed to get document", err)
+		return
+	}
+	if !hasThumbnail(doc.MimeType) {
+		s.renderErrorStatus(w, r, http.StatusUnsupportedMediaType, "Thumbnails are only available for PNG, JPEG and GIF images")
+		return
+	}
+
+	// Without a checksum there is no stable cache key
+	_, digest, _ := parseChecksum(doc.Checksum)
+	if data, ok := s.thumbs.get(digest, size); ok {
+		serveThumbnail(w, r, digest, size, data)
+		return
+	}
+
+	content, err := s.readDocument(r.Context(), apiClient, doc, maxThumbnailSourceBytes)
+	if errors.Is(err, errPreviewTooLarge) {
+		s.renderErrorStatus(w, r, http.StatusRequestEntityTooLarge, errImageTooLarge.Error())
+		return
+	} else if err != nil {
+		s.renderError(w, r, "Failed to download image", err)
+		return
+	}
+	if err := verifyContent(doc, content); err != nil {
+		s.logger.Error("document failed verification", "id", doc.ID, "path", doc.Path, "error", err)
+		s.integrity.recordMismatch(*doc, err)
+		s.renderErrorStatus(w, r, http.StatusBadGateway, "Document failed checksum verification")
+		return
+	}
+
+	data, err := s.thumbs.generate(content, pixels)
+	switch {
+	case errors.Is(err, errImageTooLarge):
+		s.renderErrorStatus(w, r, http.StatusRequestEntityTooLarge, err.Error())
+		return
+	case err != nil:
+		s.logger.Warn("failed to generate thumbnail", "id", doc.ID, "error", err)
+		s.renderErrorStatus(w, r, http.StatusUnprocessableEntity, "Failed to generate thumbnail")
+		return
+	}
+
+	if err := s.thumbs.put(digest, size, data); err != nil {
+		s.logger.Warn("failed to cache thumbnail", "id", doc.ID, "error", err)
+	}
+	serveThumbnail(w, r, digest, size, data)
+}
+
+// verifyContent checks a fully read document against its metadata.
+func verifyContent(doc *client.Document, content []byte) error {
+	verifier := newChecksumVerifier(doc)
+	verifier.Write(content)
+	return verifier.verify()
+}
+
+func serveThumbnail(w http.ResponseWriter, r *http.Request, digest, size string, data []byte) {
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "private, max-age=86400")
+	if digest != "" {
+		w.Header().Set("ETag", `"`+digest+"-"+size+`"`)
+	}
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(data))
+}
@@ -0,0 +1,203 @@
+package server
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rmrfslashbin/manuals-webui/internal/client"
+)
+
+func testPNG(t *testing.T, w, h int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{R: 200, G: 50, B: 50, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("png.Encode failed: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestMakeThumbnail(t *testing.T) {
+	tests := []struct {
+		name             string
+		width, height    int
+		size             int
+		expectW, expectH int
+	}{
+		{"landscape", 800, 400, 240, 240, 120},
+		{"portrait", 300, 600, 96, 48, 96},
+		{"not upscaled", 50, 20, 240, 50, 20},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			data, err := makeThumbnail(testPNG(t, tc.width, tc.height), tc.size)
+			if err != nil {
+				t.Fatalf("makeThumbnail failed: %v", err)
+			}
+			img, err := png.Decode(bytes.NewReader(data))
+			if err != nil {
+				t.Fatalf("thumbnail is not a PNG: %v", err)
+			}
+			if b := img.Bounds(); b.Dx() != tc.expectW || b.Dy() != tc.expectH {
+				t.Errorf("expected %dx%d, got %dx%d", tc.expectW, tc.expectH, b.Dx(), b.Dy())
+			}
+			if r, _, _, a := img.At(0, 0).RGBA(); r>>8 != 200 || a>>8 != 255 {
+				t.Errorf("unexpected pixel color %v", img.At(0, 0))
+			}
+		})
+	}
+}
+
+func TestMakeThumbnailRejectsBombs(t *testing.T) {
+	// A 1x1 GIF whose header claims a 65535x65535 logical screen
+	var buf bytes.Buffer
+	img := image.NewPaletted(image.Rect(0, 0, 1, 1), color.Palette{color.Black, color.White})
+	if err := gif.Encode(&buf, img, nil); err != nil {
+		t.Fatalf("gif.Encode failed: %v", err)
+	}
+	data := buf.Bytes()
+	binary.LittleEndian.PutUint16(data[6:], 65535)
+	binary.LittleEndian.PutUint16(data[8:], 65535)
+
+	if _, err := makeThumbnail(data, 96); !errors.Is(err, errImageTooLarge) {
+		t.Errorf("expected errImageTooLarge, got %v", err)
+	}
+	if _, err := makeThumbnail([]byte("not an image"), 96); err == nil || errors.Is(err, errImageTooLarge) {
+		t.Errorf("expected a decode error, got %v", err)
+	}
+}
+
+func TestHandleThumbnail(t *testing.T) {
+	content := string(testPNG(t, 640, 480))
+	doc := testDocument("img-1", content)
+	doc.Filename = "board.png"
+	doc.MimeType = "image/png"
+
+	var downloads int
+	apiServer := downloadAPIServer(t, *doc, content, &downloads)
+	defer apiServer.Close()
+
+	cacheDir := t.TempDir()
+	s, err := New(Config{
+		Client:        client.New(apiServer.URL, "test-key"),
+		Logger:        slog.New(slog.NewTextHandler(io.Discard, nil)),
+		CacheDir:      cacheDir,
+		CacheMaxBytes: 1 << 20,
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	get := func(target string, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", target, nil)
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		w := httptest.NewRecorder()
+		s.Handler().ServeHTTP(w, req)
+		return w
+	}
+
+	w := get("/thumbnails/img-1?size=small", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "image/png" {
+		t.Errorf("expected image/png, got %q", ct)
+	}
+	img, err := png.Decode(w.Body)
+	if err != nil {
+		t.Fatalf("response is not a PNG: %v", err)
+	}
+	if b := img.Bounds(); b.Dx() != 96 || b.Dy() != 72 {
+		t.Errorf("expected 96x72 thumbnail, got %dx%d", b.Dx(), b.Dy())
+	}
+
+	_, digest, _ := parseChecksum(doc.Checksum)
+	if _, err := os.Stat(filepath.Join(cacheDir, "thumbnails", digest+"-small.png")); err != nil {
+		t.Errorf("expected thumbnail on disk: %v", err)
+	}
+
+	etag := w.Header().Get("ETag")
+	if w := get("/thumbnails/img-1?size=small", http.Header{"If-None-Match": {etag}}); w.Code != http.StatusNotModified {
+		t.Errorf("expected status 304, got %d", w.Code)
+	}
+	if w := get("/thumbnails/img-1?size=small", nil); w.Code != http.StatusOK {
+		t.Errorf("expected cached thumbnail, got %d", w.Code)
+	}
+	if downloads != 1 {
+		t.Errorf("expected one download, got %d", downloads)
+	}
+
+	if w := get("/thumbnails/img-1?size=huge", nil); w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for unknown size, got %d", w.Code)
+	}
+}
+
+func TestHandleThumbnailUnsupported(t *testing.T) {
+	var downloads int
+	doc := client.Document{ID: "doc-1", Filename: "manual.pdf", MimeType: "application/pdf", SizeBytes: 4}
+	apiServer := downloadAPIServer(t, doc, "%PDF", &downloads)
+	defer apiServer.Close()
+
+	s := testServer(t, apiServer)
+	req := httptest.NewRequest("GET", "/thumbnails/doc-1", nil)
+	w := httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, req)
+
+	if w.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("expected status 415, got %d", w.Code)
+	}
+	if downloads != 0 {
+		t.Errorf("expected no download, got %d", downloads)
+	}
+}
+
+func TestHandleDocumentsGallery(t *testing.T) {
+	apiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(client.DocumentsResponse{
+			Data: []client.Document{
+				{ID: "img-1", Filename: "board.jpg", MimeType: "image/jpeg"},
+				{ID: "doc-1", Filename: "manual.pdf", MimeType: "application/pdf"},
+			},
+			Total: 2,
+		})
+	}))
+	defer apiServer.Close()
+
+	s := testServer(t, apiServer)
+	req := httptest.NewRequest("GET", "/documents?view=gallery", nil)
+	w := httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	body := w.Body.String()
+	if !strings.Contains(body, `src="/thumbnails/img-1?size=medium"`) {
+		t.Error("expected a thumbnail for the image document")
+	}
+	if strings.Contains(body, "/thumbnails/doc-1") {
+		t.Error("expected no thumbnail for the PDF")
+	}
+}
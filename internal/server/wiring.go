@@ -0,0 +1,593 @@
+package server
+
+import (
+	"encoding/csv"
+	"fmt"
+	"html/template"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rmrfslashbin/manuals-webui/internal/client"
+)
+
+// wiringBus describes how a bus is wired between a controller and a
+// peripheral.
+type wiringBus struct {
+	Name    string
+	Label   string
+	markers []string // tokens naming the bus, generic name first; instance numbers are ignored
+	// roles maps signal tokens to their role. Roles are bus signals such as
+	// "SDA", or "OUT"/"IN" for names relative to the device, like "TX".
+	roles map[string]string
+	// bare are the signal tokens trusted without a bus marker, e.g. a
+	// sensor pin named just "SDA".
+	bare []string
+	// signals are the connections to make, in table order: the
+	// controller's role and the peripheral's role, and whether the
+	// signal can be left out.
+	signals []wiringSignal
+}
+
+type wiringSignal struct {
+	Name     string
+	From, To string
+	Optional bool
+}
+
+// wiringBuses are the buses the planner can wire, in display order.
+var wiringBuses = []*wiringBus{
+	{
+		Name: "i2c", Label: "I²C",
+		markers: []string{"I2C", "IIC", "TWI"},
+		roles:   map[string]string{"SDA": "SDA", "SCL": "SCL"},
+		bare:    []string{"SDA", "SCL"},
+		signals: []wiringSignal{
+			{Name: "SDA", From: "SDA", To: "SDA"},
+			{Name: "SCL", From: "SCL", To: "SCL"},
+		},
+	},
+	{
+		Name: "spi", Label: "SPI",
+		markers: []string{"SPI", "HSPI", "VSPI", "FSPI", "QSPI"},
+		roles: map[string]string{
+			"MOSI": "MOSI", "COPI": "MOSI", "MISO": "MISO", "CIPO": "MISO",
+			"SCK": "SCK", "SCLK": "SCK", "CLK": "SCK",
+			"CS": "CS", "CSN": "CS", "SS": "CS", "NSS": "CS", "CE": "CS",
+			"SDO": "OUT", "DOUT": "OUT", "TX": "OUT",
+			"SDI": "IN", "DIN": "IN", "RX": "IN",
+		},
+		bare: []string{"MOSI", "COPI", "MISO", "CIPO", "SCK", "SCLK", "CS", "CSN", "SS", "NSS", "SDO", "SDI"},
+		signals: []wiringSignal{
+			{Name: "MOSI", From: "MOSI", To: "MOSI"},
+			{Name: "MISO", From: "MISO", To: "MISO", Optional: true},
+			{Name: "SCK", From: "SCK", To: "SCK"},
+			{Name: "CS", From: "CS", To: "CS", Optional: true},
+		},
+	},
+	{
+		Name: "uart", Label: "UART",
+		markers: []string{"UART", "USART", "SERIAL"},
+		roles:   map[string]string{"TX": "TX", "TXD": "TX", "RX": "RX", "RXD": "RX", "RTS": "RTS", "CTS": "CTS"},
+		bare:    []string{"TX", "TXD", "RX", "RXD", "RTS", "CTS"},
+		signals: []wiringSignal{
+			{Name: "TX → RX", From: "TX", To: "RX"},
+			{Name: "RX ← TX", From: "RX", To: "TX"},
+			{Name: "RTS → CTS", From: "RTS", To: "CTS", Optional: true},
+			{Name: "CTS ← RTS", From: "CTS", To: "RTS", Optional: true},
+		},
+	},
+}
+
+func lookupWiringBus(name string) *wiringBus {
+	for _, b := range wiringBuses {
+		if b.Name == name {
+			return b
+		}
+	}
+	return nil
+}
+
+// wiringPin is one end of a connection.
+type wiringPin struct {
+	Physical int
+	Name     string
+	GPIO     *int
+	Function string // the function used, e.g. "I2C0_SDA"
+}
+
+func (p *wiringPin) String() string {
+	if p == nil {
+		return ""
+	}
+	s := fmt.Sprintf("%d %s", p.Physical, p.Name)
+	if p.Function != "" && p.Function != p.Name {
+		s += " (" + p.Function + ")"
+	}
+	return s
+}
+
+// wire is one suggested connection.
+type wire struct {
+	Bus      string
+	Signal   string
+	From, To *wiringPin // nil if the device lacks the pin
+	Note     string
+}
+
+// wiringPlan is a suggested connection table between two devices.
+type wiringPlan struct {
+	From, To *client.Device
+	Buses    []string
+	Wires    []wire
+	Warnings []string
+}
+
+// busCandidate is a pin offering one role of a bus instance.
+type busCandidate struct {
+	instance string // e.g. "I2C0"; "" when the name gives none
+	role     string
+	pin      *client.PinoutPin
+	function string
+}
+
+// busCandidates finds the pins of a pinout that can take part in a bus.
+// A function counts if it names the bus, like "I2C0_SDA", or is only a
+// signal name the bus owns, like "SDA".
+func busCandidates(bus *wiringBus, pinout *client.PinoutResponse) []busCandidate {
+	var candidates []busCandidate
+	for i := range pinout.Pins {
+		pin := &pinout.Pins[i]
+		for _, f := range append([]string{pin.Name}, pin.AltFunctions...) {
+			tokens := functionTokens(f)
+			instance, role, signal, marked := "", "", "", false
+			for _, t := range tokens {
+				// Chip selects are numbered, as in "VSPI_CS0"
+				if r, ok := bus.roles[trimIndex(t)]; ok && role == "" {
+					role, signal = r, trimIndex(t)
+					continue
+				}
+				for _, m := range bus.markers {
+					if trimIndex(t) == m && !marked {
+						instance, marked = t, true
+					}
+				}
+			}
+			// A plain "SPI" or "I2C" names no particular instance
+			if instance == bus.markers[0] {
+				instance = ""
+			}
+			if role == "" {
+				continue
+			}
+			if !marked && (len(tokens) > 1 || !bus.isBare(signal)) {
+				continue
+			}
+			candidates = append(candidates, busCandidate{instance: instance, role: role, pin: pin, function: f})
+		}
+	}
+	return candidates
+}
+
+func (b *wiringBus) isBare(token string) bool {
+	for _, t := range b.bare {
+		if t == token {
+			return true
+		}
+	}
+	return false
+}
+
+// pickInstance chooses the bus instance covering the most roles, so SDA
+// and SCL come from the same controller, preferring pins no other signal
+// needs yet. It returns the pin for each role.
+func pickInstance(bus *wiringBus, candidates []busCandidate, controller bool, used map[int]string) map[string]busCandidate {
+	byInstance := make(map[string]map[string]busCandidate)
+	for _, c := range candidates {
+		role := resolveRole(c.role, controller)
+		if byInstance[c.instance] == nil {
+			byInstance[c.instance] = make(map[string]busCandidate)
+		}
+		prev, ok := byInstance[c.instance][role]
+		if !ok || (used[prev.pin.PhysicalPin] != "" && used[c.pin.PhysicalPin] == "") {
+			byInstance[c.instance][role] = c
+		}
+	}
+
+	busy := func(roles map[string]busCandidate) int {
+		n := 0
+		for _, c := range roles {
+			if used[c.pin.PhysicalPin] != "" {
+				n++
+			}
+		}
+		return n
+	}
+	var instances []string
+	for name := range byInstance {
+		instances = append(instances, name)
+	}
+	sort.Slice(instances, func(i, j int) bool {
+		a, b := byInstance[instances[i]], byInstance[instances[j]]
+		if len(a) != len(b) {
+			return len(a) > len(b)
+		}
+		if busy(a) != busy(b) {
+			return busy(a) < busy(b)
+		}
+		return instances[i] < instances[j]
+	})
+	if len(instances) == 0 {
+		return nil
+	}
+	return byInstance[instances[0]]
+}
+
+// resolveRole turns device-relative roles into bus roles: a controller's
+// output is MOSI, a peripheral's is MISO.
+func resolveRole(role string, controller bool) string {
+	switch {
+	case role == "OUT" && controller, role == "IN" && !controller:
+		return "MOSI"
+	case role == "OUT", role == "IN":
+		return "MISO"
+	}
+	return role
+}
+
+func newWiringPin(c busCandidate) *wiringPin {
+	return &wiringPin{Physical: c.pin.PhysicalPin, Name: c.pin.Name, GPIO: c.pin.GPIONum, Function: c.function}
+}
+
+// planWiring suggests how to wire a controller (from) to a peripheral
+// (to) over the given buses, with ground and power first.
+func planWiring(from, to *client.Device, fromPins, toPins *client.PinoutResponse, buses []*wiringBus) *wiringPlan {
+	plan := &wiringPlan{From: from, To: to}
+	usedFrom := make(map[int]string)
+	usedTo := make(map[int]string)
+
+	plan.wirePower(fromPins, toPins, usedFrom, usedTo)
+
+	for _, bus := range buses {
+		plan.Buses = append(plan.Buses, bus.Name)
+		fromSide := pickInstance(bus, busCandidates(bus, fromPins), true, usedFrom)
+		toSide := pickInstance(bus, busCandidates(bus, toPins), false, usedTo)
+		if fromSide == nil {
+			plan.warn("%s has no %s pins", from.Name, bus.Label)
+		}
+		if toSide == nil {
+			plan.warn("%s has no %s pins", to.Name, bus.Label)
+		}
+		if fromSide == nil || toSide == nil {
+			continue
+		}
+
+		first := len(plan.Wires)
+		for _, sig := range bus.signals {
+			w := wire{Bus: bus.Label, Signal: sig.Name}
+			if c, ok := fromSide[sig.From]; ok {
+				w.From = newWiringPin(c)
+			}
+			if c, ok := toSide[sig.To]; ok {
+				w.To = newWiringPin(c)
+			}
+			if w.From == nil && w.To == nil && sig.Optional {
+				continue
+			}
+			switch {
+			case w.From == nil && w.To == nil:
+				plan.warn("Neither device has a %s %s pin", bus.Label, sig.Name)
+				continue
+			case w.From == nil:
+				w.Note = "no matching pin on " + from.Name
+				if !sig.Optional {
+					plan.warn("%s has no %s %s pin", from.Name, bus.Label, sig.From)
+				}
+			case w.To == nil:
+				w.Note = "no matching pin on " + to.Name
+				if !sig.Optional {
+					plan.warn("%s has no %s %s pin", to.Name, bus.Label, sig.To)
+				}
+			}
+			if w.From != nil {
+				plan.use(usedFrom, from, w.From, bus.Label+" "+sig.From)
+			}
+			if w.To != nil {
+				plan.use(usedTo, to, w.To, bus.Label+" "+sig.To)
+			}
+			plan.Wires = append(plan.Wires, w)
+		}
+		if bus.Name == "i2c" && len(plan.Wires) > first && plan.Wires[first].Note == "" {
+			plan.Wires[first].Note = "add pull-ups on SDA and SCL if neither board has them"
+		}
+	}
+	return plan
+}
+
+// wirePower connects ground and, where the voltages can be matched, the
+// peripheral's supply.
+func (plan *wiringPlan) wirePower(fromPins, toPins *client.PinoutResponse, usedFrom, usedTo map[int]string) {
+	firstOf := func(p *client.PinoutResponse, class pinClass) *client.PinoutPin {
+		for i := range p.Pins {
+			if classifyPin(&p.Pins[i]) == class {
+				return &p.Pins[i]
+			}
+		}
+		return nil
+	}
+	powerNamed := func(p *client.PinoutResponse, name string) *client.PinoutPin {
+		for i := range p.Pins {
+			if classifyPin(&p.Pins[i]) == classPower && compactPinName(p.Pins[i].Name) == name {
+				return &p.Pins[i]
+			}
+		}
+		return nil
+	}
+	pinOf := func(p *client.PinoutPin) *wiringPin {
+		if p == nil {
+			return nil
+		}
+		return &wiringPin{Physical: p.PhysicalPin, Name: p.Name, GPIO: p.GPIONum}
+	}
+
+	gFrom, gTo := firstOf(fromPins, classGround), firstOf(toPins, classGround)
+	ground := wire{Bus: "Power", Signal: "GND", From: pinOf(gFrom), To: pinOf(gTo)}
+	if gFrom == nil || gTo == nil {
+		plan.warn("No ground pin found on %s; the devices must share a ground", plan.missing(gFrom, gTo))
+	}
+	plan.Wires = append(plan.Wires, ground)
+
+	supply := firstOf(toPins, classPower)
+	if supply == nil {
+		plan.warn("No supply pin found on %s", plan.To.Name)
+		return
+	}
+	name := compactPinName(supply.Name)
+	w := wire{Bus: "Power", Signal: supply.Name, To: pinOf(supply)}
+	switch name {
+	case "3V3", "5V":
+		w.From = pinOf(powerNamed(fromPins, name))
+		if w.From == nil {
+			plan.warn("%s has no %s output for %s's %s pin", plan.From.Name, name, plan.To.Name, supply.Name)
+		}
+	default:
+		// VCC, VDD and VIN don't say which voltage they take
+		w.From = pinOf(powerNamed(fromPins, "3V3"))
+		if w.From == nil {
+			w.From = pinOf(powerNamed(fromPins, "5V"))
+		}
+		w.Note = "check " + plan.To.Name + "'s supply voltage"
+		if w.From == nil {
+			plan.warn("%s has no 3V3 or 5V output for %s's %s pin", plan.From.Name, plan.To.Name, supply.Name)
+		}
+	}
+	if w.From != nil {
+		usedFrom[w.From.Physical] = supply.Name
+	}
+	usedTo[w.To.Physical] = supply.Name
+	plan.Wires = append(plan.Wires, w)
+}
+
+func (plan *wiringPlan) missing(from, to *client.PinoutPin) string {
+	switch {
+	case from == nil && to == nil:
+		return "either device"
+	case from == nil:
+		return plan.From.Name
+	default:
+		return plan.To.Name
+	}
+}
+
+// use records that a pin carries a signal and warns if another signal
+// already needs it.
+func (plan *wiringPlan) use(used map[int]string, device *client.Device, p *wiringPin, signal string) {
+	if other, ok := used[p.Physical]; ok && other != signal {
+		plan.warn("%s pin %d (%s) is needed for both %s and %s", device.Name, p.Physical, p.Name, other, signal)
+		return
+	}
+	used[p.Physical] = signal
+}
+
+func (plan *wiringPlan) warn(format string, args ...any) {
+	plan.Warnings = append(plan.Warnings, fmt.Sprintf(format, args...))
+}
+
+// wiringQuery is the state of the wiring planner, kept in the URL.
+type wiringQuery struct {
+	From  string
+	To    string
+	Buses []string
+}
+
+func parseWiringQuery(v url.Values) wiringQuery {
+	q := wiringQuery{From: v.Get("from"), To: v.Get("to")}
+	seen := make(map[string]bool)
+	for _, value := range v["bus"] {
+		for _, name := range strings.Split(value, ",") {
+			name = strings.ToLower(strings.TrimSpace(name))
+			if lookupWiringBus(name) != nil && !seen[name] {
+				seen[name] = true
+				q.Buses = append(q.Buses, name)
+			}
+		}
+	}
+	return q
+}
+
+func (q wiringQuery) url(path string) template.URL {
+	v := url.Values{}
+	v.Set("from", q.From)
+	v.Set("to", q.To)
+	if len(q.Buses) > 0 {
+		v.Set("bus", strings.Join(q.Buses, ","))
+	}
+	return template.URL(path + "?" + v.Encode())
+}
+
+// CSVURL and MarkdownURL download the plan for this query.
+func (q wiringQuery) CSVURL() template.URL      { return q.url("/wiring.csv") }
+func (q wiringQuery) MarkdownURL() template.URL { return q.url("/wiring.md") }
+
+// HasBus reports whether the bus is selected, for the form.
+func (q wiringQuery) HasBus(name string) bool {
+	for _, b := range q.Buses {
+		if b == name {
+			return true
+		}
+	}
+	return false
+}
+
+// wiringData is passed to wiring.html.
+type wiringData struct {
+	Query   wiringQuery
+	Devices []client.Device
+	Buses   []*wiringBus
+	Problem string
+	Plan    *wiringPlan
+}
+
+// plan loads both devices and pinouts and plans the wiring. A device
+// without a pinout is reported as a problem rather than an error, since
+// the user picked it from the form.
+func (s *Server) plan(r *http.Request, q wiringQuery) (*wiringPlan, string, error) {
+	apiClient := s.apiClient(r)
+	var devices [2]*client.Device
+	var pinouts [2]*client.PinoutResponse
+	for i, id := range []string{q.From, q.To} {
+		device, err := apiClient.GetDevice(id, false)
+		if err != nil {
+			return nil, "", err
+		}
+		pinout, err := apiClient.GetDevicePinout(id)
+		if isNotFound(err) {
+			return nil, device.Name + " has no pinout", nil
+		} else if err != nil {
+			return nil, "", err
+		}
+		devices[i], pinouts[i] = device, pinout
+	}
+
+	var buses []*wiringBus
+	for _, name := range q.Buses {
+		buses = append(buses, lookupWiringBus(name))
+	}
+	return planWiring(devices[0], devices[1], pinouts[0], pinouts[1], buses), "", nil
+}
+
+func (s *Server) handleWiring(w http.ResponseWriter, r *http.Request) {
+	q := parseWiringQuery(r.URL.Query())
+	if len(q.Buses) == 0 && r.URL.Query().Get("bus") == "" {
+		q.Buses = []string{"i2c"}
+	}
+	data := wiringData{Query: q, Buses: wiringBuses}
+
+	devices, err := s.devices.get(r.Context(), s.apiClient(r))
+	if err != nil {
+		s.renderError(w, r, "Failed to list devices", err)
+		return
+	}
+	data.Devices = devices.Devices
+
+	if q.From != "" && q.To != "" {
+		plan, problem, err := s.plan(r, q)
+		if err != nil {
+			s.renderError(w, r, "Failed to plan wiring", err)
+			return
+		}
+		data.Plan, data.Problem = plan, problem
+	}
+
+	s.render(w, "wiring.html", pageData{
+		Title:   "Wiring",
+		Content: data,
+	})
+}
+
+// handleWiringDownload exports the plan as CSV or markdown, depending on
+// the extension in the route.
+func (s *Server) handleWiringDownload(format string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := parseWiringQuery(r.URL.Query())
+		if q.From == "" || q.To == "" {
+			s.renderErrorStatus(w, r, http.StatusBadRequest, "Choose two devices to wire")
+			return
+		}
+		plan, problem, err := s.plan(r, q)
+		if err != nil {
+			s.renderError(w, r, "Failed to plan wiring", err)
+			return
+		}
+		if problem != "" {
+			s.renderErrorStatus(w, r, http.StatusNotFound, problem)
+			return
+		}
+
+		filename := "wiring-" + q.From + "-" + q.To + "." + format
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+		switch format {
+		case "csv":
+			w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+			err = plan.writeCSV(w)
+		default:
+			w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+			err = plan.writeMarkdown(w)
+		}
+		if err != nil {
+			s.logger.Error("failed to write wiring plan", "format", format, "error", err)
+		}
+	}
+}
+
+func pinColumns(p *wiringPin) (string, string, string) {
+	if p == nil {
+		return "", "", ""
+	}
+	gpio := ""
+	if p.GPIO != nil {
+		gpio = strconv.Itoa(*p.GPIO)
+	}
+	return strconv.Itoa(p.Physical), p.Name, gpio
+}
+
+func (plan *wiringPlan) writeCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"bus", "signal", "from_device", "from_pin", "from_name", "from_gpio", "to_device", "to_pin", "to_name", "to_gpio", "note"})
+	for _, wire := range plan.Wires {
+		fromPin, fromName, fromGPIO := pinColumns(wire.From)
+		toPin, toName, toGPIO := pinColumns(wire.To)
+		cw.Write([]string{wire.Bus, wire.Signal, plan.From.Name, fromPin, fromName, fromGPIO, plan.To.Name, toPin, toName, toGPIO, wire.Note})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// markdownCell escapes a table cell.
+func markdownCell(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "|", `\|`), "\n", " ")
+}
+
+func (plan *wiringPlan) writeMarkdown(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Wiring: %s → %s\n\n", plan.From.Name, plan.To.Name)
+	fmt.Fprintf(&b, "| Bus | Signal | %s | %s | Note |\n", markdownCell(plan.From.Name), markdownCell(plan.To.Name))
+	b.WriteString("|-----|--------|------|------|------|\n")
+	for _, wire := range plan.Wires {
+		fmt.Fprintf(&b, "| %s | %s | %s | %s | %s |\n",
+			markdownCell(wire.Bus), markdownCell(wire.Signal),
+			markdownCell(wire.From.String()), markdownCell(wire.To.String()), markdownCell(wire.Note))
+	}
+	if len(plan.Warnings) > 0 {
+		b.WriteString("\n## Warnings\n\n")
+		for _, warning := range plan.Warnings {
+			fmt.Fprintf(&b, "- %s\n", warning)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
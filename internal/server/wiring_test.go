@@ -0,0 +1,239 @@
+package server
+
+import (
+	"encoding/csv"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rmrfslashbin/manuals-webui/internal/client"
+)
+
+func wiringFixture() ([]client.Device, map[string][]client.PinoutPin) {
+	devices := []client.Device{
+		{ID: "esp32", Name: "ESP32"},
+		{ID: "bme280", Name: "BME280"},
+		{ID: "gps", Name: "GPS"},
+		{ID: "mqtt", Name: "MQTT"},
+	}
+	pinouts := map[string][]client.PinoutPin{
+		"esp32": {
+			{PhysicalPin: 1, Name: "3V3"},
+			{PhysicalPin: 2, Name: "GND"},
+			{PhysicalPin: 30, GPIONum: gpio(18), Name: "GPIO18", AltFunctions: []string{"VSPI_CLK"}},
+			{PhysicalPin: 31, GPIONum: gpio(19), Name: "GPIO19", AltFunctions: []string{"VSPI_MISO", "U0CTS"}},
+			{PhysicalPin: 29, GPIONum: gpio(5), Name: "GPIO5", AltFunctions: []string{"VSPI_CS0"}},
+			{PhysicalPin: 33, GPIONum: gpio(21), Name: "GPIO21", AltFunctions: []string{"I2C1_SDA"}},
+			{PhysicalPin: 34, GPIONum: gpio(3), Name: "GPIO3", AltFunctions: []string{"UART0_RX"}},
+			{PhysicalPin: 35, GPIONum: gpio(1), Name: "GPIO1", AltFunctions: []string{"UART0_TX"}},
+			{PhysicalPin: 36, GPIONum: gpio(22), Name: "GPIO22", AltFunctions: []string{"I2C1_SCL"}},
+			{PhysicalPin: 37, GPIONum: gpio(23), Name: "GPIO23", AltFunctions: []string{"VSPI_MOSI", "I2C0_SDA"}},
+			{PhysicalPin: 38, GPIONum: gpio(25), Name: "GPIO25", AltFunctions: []string{"I2C0_SCL"}},
+		},
+		"bme280": {
+			{PhysicalPin: 1, Name: "VDD"},
+			{PhysicalPin: 2, Name: "GND"},
+			{PhysicalPin: 3, Name: "SCK", AltFunctions: []string{"I2C_SCL"}},
+			{PhysicalPin: 4, Name: "SDI", AltFunctions: []string{"I2C_SDA"}},
+			{PhysicalPin: 5, Name: "SDO"},
+			{PhysicalPin: 6, Name: "CSB", AltFunctions: []string{"SPI_CS"}},
+		},
+		"gps": {
+			{PhysicalPin: 1, Name: "5V"},
+			{PhysicalPin: 2, Name: "GND"},
+			{PhysicalPin: 3, Name: "TX"},
+			{PhysicalPin: 4, Name: "RX"},
+		},
+	}
+	return devices, pinouts
+}
+
+func planFixture(from, to string, buses ...string) *wiringPlan {
+	devices, pinouts := wiringFixture()
+	byID := make(map[string]*client.Device)
+	for i := range devices {
+		byID[devices[i].ID] = &devices[i]
+	}
+	var selected []*wiringBus
+	for _, name := range buses {
+		selected = append(selected, lookupWiringBus(name))
+	}
+	return planWiring(byID[from], byID[to],
+		&client.PinoutResponse{DeviceID: from, Pins: pinouts[from]},
+		&client.PinoutResponse{DeviceID: to, Pins: pinouts[to]},
+		selected)
+}
+
+// wireTable renders a plan as "signal:from-to" for comparison, with 0 for
+// a missing pin.
+func wireTable(plan *wiringPlan) []string {
+	var table []string
+	for _, w := range plan.Wires {
+		from, to := "0", "0"
+		if w.From != nil {
+			from = w.From.Name
+		}
+		if w.To != nil {
+			to = w.To.Name
+		}
+		table = append(table, w.Signal+":"+from+"-"+to)
+	}
+	return table
+}
+
+func TestPlanWiring(t *testing.T) {
+	tests := []struct {
+		name     string
+		from, to string
+		buses    []string
+		wires    []string
+		warnings []string
+	}{
+		{
+			name: "i2c uses one controller",
+			from: "esp32", to: "bme280", buses: []string{"i2c"},
+			wires:    []string{"GND:GND-GND", "VDD:3V3-VDD", "SDA:GPIO23-SDI", "SCL:GPIO25-SCK"},
+			warnings: nil,
+		},
+		{
+			name: "spi resolves device-relative names",
+			from: "esp32", to: "bme280", buses: []string{"spi"},
+			wires: []string{"GND:GND-GND", "VDD:3V3-VDD", "MOSI:GPIO23-SDI", "MISO:GPIO19-SDO", "SCK:GPIO18-SCK", "CS:GPIO5-CSB"},
+		},
+		{
+			name: "buses avoid sharing pins",
+			from: "esp32", to: "bme280", buses: []string{"spi", "i2c"},
+			wires: []string{"GND:GND-GND", "VDD:3V3-VDD", "MOSI:GPIO23-SDI", "MISO:GPIO19-SDO", "SCK:GPIO18-SCK", "CS:GPIO5-CSB", "SDA:GPIO21-SDI", "SCL:GPIO22-SCK"},
+			warnings: []string{
+				"BME280 pin 4 (SDI) is needed for both SPI MOSI and I²C SDA",
+				"BME280 pin 3 (SCK) is needed for both SPI SCK and I²C SCL",
+			},
+		},
+		{
+			name: "uart crosses over",
+			from: "esp32", to: "gps", buses: []string{"uart"},
+			wires:    []string{"GND:GND-GND", "5V:0-5V", "TX → RX:GPIO1-RX", "RX ← TX:GPIO3-TX"},
+			warnings: []string{"ESP32 has no 5V output for GPS's 5V pin"},
+		},
+		{
+			name: "missing bus",
+			from: "esp32", to: "gps", buses: []string{"i2c"},
+			wires:    []string{"GND:GND-GND", "5V:0-5V"},
+			warnings: []string{"ESP32 has no 5V output for GPS's 5V pin", "GPS has no I²C pins"},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			plan := planFixture(tc.from, tc.to, tc.buses...)
+			if got := strings.Join(wireTable(plan), " "); got != strings.Join(tc.wires, " ") {
+				t.Errorf("expected wires\n  %s\ngot\n  %s", strings.Join(tc.wires, " "), got)
+			}
+			if got := strings.Join(plan.Warnings, "; "); got != strings.Join(tc.warnings, "; ") {
+				t.Errorf("expected warnings %q, got %q", tc.warnings, plan.Warnings)
+			}
+		})
+	}
+}
+
+func TestParseWiringQuery(t *testing.T) {
+	q := parseWiringQuery(map[string][]string{"from": {"a"}, "to": {"b"}, "bus": {"I2C,spi", "can", "i2c"}})
+	if q.From != "a" || q.To != "b" || strings.Join(q.Buses, ",") != "i2c,spi" {
+		t.Errorf("unexpected query %+v", q)
+	}
+	if got := string(q.CSVURL()); got != "/wiring.csv?bus=i2c%2Cspi&from=a&to=b" {
+		t.Errorf("unexpected CSV URL %q", got)
+	}
+}
+
+func wiringAPIServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	devices, pinouts := wiringFixture()
+	api, _ := deviceAPIServer(t, devices, map[string]map[string]any{"pinout": pinoutResponses(pinouts)})
+	return api
+}
+
+func TestHandleWiring(t *testing.T) {
+	api := wiringAPIServer(t)
+	defer api.Close()
+	s := testServer(t, api)
+
+	tests := []struct {
+		name   string
+		url    string
+		status int
+		want   []string
+	}{
+		{
+			name:   "form",
+			url:    "/wiring",
+			status: http.StatusOK,
+			want:   []string{`<option value="esp32"`, `value="i2c" checked`},
+		},
+		{
+			name:   "plan",
+			url:    "/wiring?from=esp32&to=gps&bus=uart",
+			status: http.StatusOK,
+			want:   []string{"TX → RX", "UART0_TX", "no 5V output", "/wiring.csv?bus=uart&amp;from=esp32&amp;to=gps"},
+		},
+		{
+			name:   "no pinout",
+			url:    "/wiring?from=esp32&to=mqtt",
+			status: http.StatusOK,
+			want:   []string{"MQTT has no pinout"},
+		},
+		{
+			name:   "markdown",
+			url:    "/wiring.md?from=esp32&to=gps&bus=uart",
+			status: http.StatusOK,
+			want:   []string{"# Wiring: ESP32 → GPS", "| UART | TX → RX | 35 GPIO1 (UART0_TX) | 4 RX |", "## Warnings"},
+		},
+		{
+			name:   "download needs devices",
+			url:    "/wiring.csv?from=esp32",
+			status: http.StatusBadRequest,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tc.url, nil)
+			w := httptest.NewRecorder()
+			s.Handler().ServeHTTP(w, req)
+			if w.Code != tc.status {
+				t.Fatalf("expected status %d, got %d", tc.status, w.Code)
+			}
+			body := w.Body.String()
+			for _, want := range tc.want {
+				if !strings.Contains(body, want) {
+					t.Errorf("expected body to contain %q", want)
+				}
+			}
+		})
+	}
+}
+
+func TestHandleWiringCSV(t *testing.T) {
+	api := wiringAPIServer(t)
+	defer api.Close()
+	s := testServer(t, api)
+
+	req := httptest.NewRequest("GET", "/wiring.csv?from=esp32&to=bme280&bus=i2c", nil)
+	w := httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	if got := w.Header().Get("Content-Disposition"); got != "attachment; filename=wiring-esp32-bme280.csv" {
+		t.Errorf("unexpected Content-Disposition %q", got)
+	}
+	rows, err := csv.NewReader(w.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 5 {
+		t.Fatalf("expected header and 4 rows, got %d", len(rows))
+	}
+	if got := strings.Join(rows[3], ","); got != "I²C,SDA,ESP32,37,GPIO23,23,BME280,4,SDI,,add pull-ups on SDA and SCL if neither board has them" {
+		t.Errorf("unexpected SDA row %q", got)
+	}
+}
@@ -0,0 +1,239 @@
+package server
+
+import (
+	"fmt"
+	"html/template"
+	"io/fs"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/rmrfslashbin/manuals-webui/internal/client"
+)
+
+// WizardResult is the configuration collected by the first-run wizard.
+type WizardResult struct {
+	APIURL string
+	APIKey string
+	Host   string
+	Port   int
+	Status *client.StatusResponse
+}
+
+// WizardConfig holds the first-run wizard configuration.
+type WizardConfig struct {
+	Logger *slog.Logger
+
+	// Defaults pre-fills the form.
+	Defaults WizardResult
+
+	// ConfigPath is shown to the user as the file that will be written.
+	ConfigPath string
+
+	// Addr is the loopback host:port the wizard listens on. Requests must
+	// address it by this port, and form posts must come from a page served
+	// by it.
+	Addr string
+
+	// Save persists the collected configuration. It is called once the
+	// API connection has been verified.
+	Save func(WizardResult) error
+}
+
+// Wizard is a local-only web UI that collects the API connection and
+// listen address when the server is started without a configuration.
+type Wizard struct {
+	logger     *slog.Logger
+	defaults   WizardResult
+	configPath string
+	hosts      map[string]bool
+	save       func(WizardResult) error
+	tmpl       *template.Template
+	done       chan WizardResult
+	once       sync.Once
+}
+
+type wizardData struct {
+	Form       WizardResult
+	ConfigPath string
+	Error      string
+	Done       bool
+	NextURL    string
+}
+
+// NewWizard creates a first-run setup wizard.
+func NewWizard(cfg WizardConfig) *Wizard {
+	tmpl := template.Must(template.New("").ParseFS(templatesFS, "templates/standalone/wizard.html"))
+
+	// The wizard may be opened through any loopback name for its port
+	hosts := map[string]bool{cfg.Addr: true}
+	if _, port, err := net.SplitHostPort(cfg.Addr); err == nil {
+		for _, host := range []string{"127.0.0.1", "localhost", "::1"} {
+			hosts[net.JoinHostPort(host, port)] = true
+		}
+	}
+
+	return &Wizard{
+		logger:     cfg.Logger,
+		defaults:   cfg.Defaults,
+		configPath: cfg.ConfigPath,
+		hosts:      hosts,
+		save:       cfg.Save,
+		tmpl:       tmpl,
+		done:       make(chan WizardResult, 1),
+	}
+}
+
+// Done returns a channel that receives the saved configuration once the
+// wizard completes.
+func (wz *Wizard) Done() <-chan WizardResult {
+	return wz.done
+}
+
+// Handler returns the HTTP handler for the wizard.
+func (wz *Wizard) Handler() http.Handler {
+	mux := http.NewServeMux()
+
+	staticContent, _ := fs.Sub(staticFS, "static")
+	mux.Handle("GET /static/", http.StripPrefix("/static/", http.FileServer(http.FS(staticContent))))
+
+	mux.HandleFunc("GET /{$}", wz.handleForm)
+	mux.HandleFunc("POST /{$}", wz.handleSubmit)
+
+	return wz.localOnly(wz.sameOrigin(mux))
+}
+
+// localOnly rejects requests that do not originate from the loopback
+// interface, in case the wizard is ever bound to a public address.
+func (wz *Wizard) localOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			host = r.RemoteAddr
+		}
+		if ip := net.ParseIP(host); ip == nil || !ip.IsLoopback() {
+			wz.logger.Warn("rejected non-local wizard request", "remote_addr", r.RemoteAddr)
+			http.Error(w, "Setup wizard is only available from localhost", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// sameOrigin rejects requests whose Host is not the wizard's own address,
+// which stops DNS rebinding, and form posts whose Origin (or Referer when
+// Origin is missing) is not a wizard page. Loopback binding alone does not
+// stop another site from posting a form to the wizard.
+func (wz *Wizard) sameOrigin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !wz.hosts[r.Host] {
+			wz.logger.Warn("rejected wizard request for unknown host", "host", r.Host)
+			http.Error(w, "Setup wizard is only available from localhost", http.StatusForbidden)
+			return
+		}
+
+		if r.Method == http.MethodPost {
+			source := r.Header.Get("Origin")
+			if source == "" {
+				source = r.Header.Get("Referer")
+			}
+			u, err := url.Parse(source)
+			if source == "" || err != nil || u.Scheme != "http" || !wz.hosts[u.Host] {
+				wz.logger.Warn("rejected cross-origin wizard request", "origin", r.Header.Get("Origin"), "referer", r.Header.Get("Referer"))
+				http.Error(w, "Cross-origin requests are not allowed", http.StatusForbidden)
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (wz *Wizard) handleForm(w http.ResponseWriter, r *http.Request) {
+	wz.render(w, http.StatusOK, wizardData{
+		Form:       wz.defaults,
+		ConfigPath: wz.configPath,
+	})
+}
+
+func (wz *Wizard) handleSubmit(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Failed to parse form", http.StatusBadRequest)
+		return
+	}
+
+	form := WizardResult{
+		APIURL: strings.TrimRight(strings.TrimSpace(r.FormValue("api_url")), "/"),
+		APIKey: strings.TrimSpace(r.FormValue("api_key")),
+		Host:   strings.TrimSpace(r.FormValue("host")),
+	}
+	port, portErr := strconv.Atoi(r.FormValue("port"))
+	form.Port = port
+
+	fail := func(message string) {
+		wz.render(w, http.StatusUnprocessableEntity, wizardData{
+			Form:       form,
+			ConfigPath: wz.configPath,
+			Error:      message,
+		})
+	}
+
+	if _, err := normalizeAPIURL(form.APIURL); err != nil {
+		fail(err.Error())
+		return
+	}
+	if form.Host == "" {
+		fail("Host is required")
+		return
+	}
+	if portErr != nil || port < 1 || port > 65535 {
+		fail("Port must be a number between 1 and 65535")
+		return
+	}
+
+	status, err := client.New(form.APIURL, form.APIKey).GetStatus()
+	if err != nil {
+		fail("Connection failed: " + err.Error())
+		return
+	}
+	form.Status = status
+
+	if err := wz.save(form); err != nil {
+		wz.logger.Error("failed to save configuration", "path", wz.configPath, "error", err)
+		fail("Failed to save configuration: " + err.Error())
+		return
+	}
+
+	wz.logger.Info("first-run configuration saved", "path", wz.configPath, "api_url", form.APIURL)
+
+	wz.render(w, http.StatusOK, wizardData{
+		Form:       form,
+		ConfigPath: wz.configPath,
+		Done:       true,
+		NextURL:    wizardNextURL(form.Host, form.Port),
+	})
+
+	wz.once.Do(func() {
+		wz.done <- form
+	})
+}
+
+// wizardNextURL is where the browser should go once normal serving starts.
+func wizardNextURL(host string, port int) string {
+	if host == "0.0.0.0" || host == "::" {
+		host = "localhost"
+	}
+	return fmt.Sprintf("http://%s/", net.JoinHostPort(host, strconv.Itoa(port)))
+}
+
+func (wz *Wizard) render(w http.ResponseWriter, status int, data wizardData) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	if err := wz.tmpl.ExecuteTemplate(w, "wizard.html", data); err != nil {
+		wz.logger.Error("template execute error", "template", "wizard.html", "error", err)
+	}
+}
@@ -0,0 +1,223 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/rmrfslashbin/manuals-webui/internal/client"
+)
+
+func testWizard(t *testing.T, save func(WizardResult) error) *Wizard {
+	t.Helper()
+	return NewWizard(WizardConfig{
+		Logger:     slog.New(slog.NewTextHandler(io.Discard, nil)),
+		Defaults:   WizardResult{APIURL: "http://localhost:8080", Host: "0.0.0.0", Port: 3000},
+		ConfigPath: "/tmp/.manuals-webui.yaml",
+		Addr:       "127.0.0.1:3000",
+		Save:       save,
+	})
+}
+
+func wizardForm(apiURL, port string) *http.Request {
+	form := url.Values{}
+	form.Set("api_url", apiURL)
+	form.Set("api_key", "mapi_test")
+	form.Set("host", "0.0.0.0")
+	form.Set("port", port)
+	req := httptest.NewRequest("POST", "/", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Origin", "http://localhost:3000")
+	req.Host = "localhost:3000"
+	req.RemoteAddr = "127.0.0.1:50000"
+	return req
+}
+
+func TestWizardForm(t *testing.T) {
+	wz := testWizard(t, nil)
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Host = "127.0.0.1:3000"
+	req.RemoteAddr = "127.0.0.1:50000"
+	w := httptest.NewRecorder()
+
+	wz.Handler().ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), "http://localhost:8080") {
+		t.Error("expected form to be pre-filled with default API URL")
+	}
+}
+
+func TestWizardRejectsRemote(t *testing.T) {
+	wz := testWizard(t, nil)
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "192.0.2.10:50000"
+	w := httptest.NewRecorder()
+
+	wz.Handler().ServeHTTP(w, req)
+
+	if w.Code != http.StatusForbidden {
+		t.Errorf("expected status 403, got %d", w.Code)
+	}
+}
+
+func TestWizardSubmit(t *testing.T) {
+	apiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-API-Key") != "mapi_test" {
+			t.Errorf("expected API key mapi_test, got %s", r.Header.Get("X-API-Key"))
+		}
+		json.NewEncoder(w).Encode(client.StatusResponse{Status: "ok", APIVersion: client.APIVersion})
+	}))
+	defer apiServer.Close()
+
+	var saved WizardResult
+	wz := testWizard(t, func(result WizardResult) error {
+		saved = result
+		return nil
+	})
+
+	w := httptest.NewRecorder()
+	wz.Handler().ServeHTTP(w, wizardForm(apiServer.URL+"/", "3001"))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if saved.APIURL != apiServer.URL || saved.Port != 3001 {
+		t.Errorf("unexpected saved config: %+v", saved)
+	}
+	if !strings.Contains(w.Body.String(), "http://localhost:3001/") {
+		t.Error("expected confirmation to link to the new server address")
+	}
+
+	select {
+	case result := <-wz.Done():
+		if result.Status == nil || result.Status.Status != "ok" {
+			t.Errorf("expected status in result, got %+v", result.Status)
+		}
+	default:
+		t.Error("expected wizard to signal completion")
+	}
+}
+
+func TestWizardSubmitValidation(t *testing.T) {
+	saveCalled := false
+	wz := testWizard(t, func(WizardResult) error {
+		saveCalled = true
+		return nil
+	})
+
+	tests := []struct {
+		name   string
+		apiURL string
+		port   string
+	}{
+		{"invalid url", "not-a-url", "3000"},
+		{"invalid port", "http://localhost:8080", "70000"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			wz.Handler().ServeHTTP(w, wizardForm(tc.apiURL, tc.port))
+
+			if w.Code != http.StatusUnprocessableEntity {
+				t.Errorf("expected status 422, got %d", w.Code)
+			}
+		})
+	}
+
+	if saveCalled {
+		t.Error("expected save not to be called")
+	}
+}
+
+func TestWizardSubmitSaveError(t *testing.T) {
+	apiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(client.StatusResponse{Status: "ok"})
+	}))
+	defer apiServer.Close()
+
+	wz := testWizard(t, func(WizardResult) error {
+		return errors.New("permission denied")
+	})
+
+	w := httptest.NewRecorder()
+	wz.Handler().ServeHTTP(w, wizardForm(apiServer.URL, "3000"))
+
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected status 422, got %d", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), "permission denied") {
+		t.Error("expected save error in response")
+	}
+
+	select {
+	case <-wz.Done():
+		t.Error("expected wizard not to complete")
+	default:
+	}
+}
+
+func TestWizardRejectsCrossOrigin(t *testing.T) {
+	saveCalled := false
+	wz := testWizard(t, func(WizardResult) error {
+		saveCalled = true
+		return nil
+	})
+
+	tests := []struct {
+		name    string
+		host    string
+		origin  string
+		referer string
+	}{
+		{"foreign origin", "localhost:3000", "https://evil.example", ""},
+		{"foreign referer", "localhost:3000", "", "https://evil.example/form.html"},
+		{"other port", "localhost:3000", "http://localhost:8080", ""},
+		{"no origin or referer", "localhost:3000", "", ""},
+		{"rebound host", "evil.example:3000", "http://evil.example:3000", ""},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := wizardForm("http://localhost:8080", "3000")
+			req.Host = tc.host
+			req.Header.Del("Origin")
+			if tc.origin != "" {
+				req.Header.Set("Origin", tc.origin)
+			}
+			if tc.referer != "" {
+				req.Header.Set("Referer", tc.referer)
+			}
+			w := httptest.NewRecorder()
+			wz.Handler().ServeHTTP(w, req)
+
+			if w.Code != http.StatusForbidden {
+				t.Errorf("expected status 403, got %d", w.Code)
+			}
+		})
+	}
+
+	if saveCalled {
+		t.Error("expected save not to be called")
+	}
+
+	// A post from the wizard's own page, identified by Referer, gets through
+	req := wizardForm("not-a-url", "3000")
+	req.Header.Del("Origin")
+	req.Header.Set("Referer", "http://127.0.0.1:3000/")
+	w := httptest.NewRecorder()
+	wz.Handler().ServeHTTP(w, req)
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected the form to be validated, got %d", w.Code)
+	}
+}